@@ -0,0 +1,145 @@
+// Package bus publishes configuration, deployment and server health changes
+// to every connected dashboard and script.
+package bus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+)
+
+// SubscriberBuffer is the number of events buffered for each subscriber.
+// A subscriber that falls a full buffer behind is disconnected.
+var SubscriberBuffer = 256
+
+// Event types
+const (
+	SSHCreated         = "ssh.created"
+	SSHUpdated         = "ssh.updated"
+	SSHDeleted         = "ssh.deleted"
+	ProjectCreated     = "project.created"
+	ProjectUpdated     = "project.updated"
+	ProjectDeleted     = "project.deleted"
+	DeploymentQueued   = "deployment.queued"
+	DeploymentStarted  = "deployment.started"
+	DeploymentFinished = "deployment.finished"
+	ServerHealth       = "server.health"
+)
+
+// Event is a change published on the bus
+type Event struct {
+	Seq       uint64      `json:"seq"`
+	Type      string      `json:"type"`
+	Name      string      `json:"name"` // name of the SSH config, project, deployment ID or server
+	Data      interface{} `json:"data,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// Health is the data of server health events
+type Health struct {
+	Healthy bool   `json:"healthy"`
+	Message string `json:"message,omitempty"`
+}
+
+// Bus fans events out to subscribers. A nil Bus discards published events.
+type Bus struct {
+	subscribers []chan Event
+	seq         uint64
+	health      map[string]bool // last known health of each server
+	mu          sync.Mutex
+}
+
+// New creates a bus publishing the lifecycle events of an engine's deployments
+// and the health of the servers it connects to
+func New(engine *deploy.Engine) *Bus {
+	b := &Bus{}
+	engine.AddHook(b.handle)
+	engine.AddHealthHook(b.ReportHealth)
+	return b
+}
+
+// handle publishes a deployment lifecycle event
+func (b *Bus) handle(event string, status deploy.DeploymentStatus) {
+	switch event {
+	case deploy.EventQueued:
+		b.Publish(DeploymentQueued, status.ID, status)
+	case deploy.EventStarted:
+		b.Publish(DeploymentStarted, status.ID, status)
+	default:
+		b.Publish(DeploymentFinished, status.ID, status)
+	}
+}
+
+// ReportHealth publishes the health of a server when it changes
+func (b *Bus) ReportHealth(name string, healthy bool, message string) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	last, known := b.health[name]
+	if b.health == nil {
+		b.health = make(map[string]bool)
+	}
+	b.health[name] = healthy
+	b.mu.Unlock()
+
+	if !known || last != healthy {
+		b.Publish(ServerHealth, name, Health{Healthy: healthy, Message: message})
+	}
+}
+
+// Publish sends an event to all subscribers without blocking, disconnecting
+// subscribers whose buffer is full
+func (b *Bus) Publish(eventType string, name string, data interface{}) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	event := Event{
+		Seq:       b.seq,
+		Type:      eventType,
+		Name:      name,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	for i := len(b.subscribers) - 1; i >= 0; i-- {
+		select {
+		case b.subscribers[i] <- event:
+		default:
+			close(b.subscribers[i])
+			b.subscribers = append(b.subscribers[:i], b.subscribers[i+1:]...)
+		}
+	}
+}
+
+// Subscribe returns a channel receiving all published events, and a function
+// that cancels the subscription. The channel is closed when the subscription
+// is cancelled or when the subscriber falls SubscriberBuffer events behind.
+func (b *Bus) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, SubscriberBuffer)
+
+	b.mu.Lock()
+	b.subscribers = append(b.subscribers, ch)
+	b.mu.Unlock()
+
+	cancel := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		for i, sub := range b.subscribers {
+			if sub == ch {
+				close(ch)
+				b.subscribers = append(b.subscribers[:i], b.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+	return ch, cancel
+}
@@ -0,0 +1,115 @@
+package bus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+)
+
+func TestPublishSubscribe(t *testing.T) {
+	b := &Bus{}
+
+	events, cancel := b.Subscribe()
+	b.Publish(SSHCreated, "server1", nil)
+	b.Publish(ProjectDeleted, "project1", nil)
+
+	first, second := <-events, <-events
+	if first.Type != SSHCreated || first.Name != "server1" || first.Seq != 1 {
+		t.Errorf("Unexpected first event %+v", first)
+	}
+	if second.Type != ProjectDeleted || second.Seq != 2 {
+		t.Errorf("Unexpected second event %+v", second)
+	}
+
+	cancel()
+	if _, ok := <-events; ok {
+		t.Error("Expected channel to be closed after cancel")
+	}
+	cancel()
+
+	// A nil bus discards events
+	var nilBus *Bus
+	nilBus.Publish(SSHCreated, "server1", nil)
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	buffer := SubscriberBuffer
+	SubscriberBuffer = 1
+	defer func() { SubscriberBuffer = buffer }()
+
+	b := &Bus{}
+	events, cancel := b.Subscribe()
+	defer cancel()
+
+	b.Publish(SSHCreated, "server1", nil)
+	b.Publish(SSHCreated, "server2", nil)
+
+	count := 0
+	for range events {
+		count++
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 buffered event, got %d", count)
+	}
+	if len(b.subscribers) != 0 {
+		t.Error("Expected slow subscriber to be disconnected")
+	}
+}
+
+func TestDeploymentEvents(t *testing.T) {
+	engine := deploy.NewEngine()
+	b := New(engine)
+
+	events, cancel := b.Subscribe()
+	defer cancel()
+
+	engine.Enqueue("deploy-1", &deploy.Project{Name: "project1"}, nil, nil)
+
+	var types []string
+	timeout := time.After(5 * time.Second)
+	for len(types) < 3 {
+		select {
+		case event := <-events:
+			if event.Name != "deploy-1" {
+				t.Errorf("Unexpected event %+v", event)
+			}
+			types = append(types, event.Type)
+		case <-timeout:
+			t.Fatalf("Timed out waiting for deployment events, got %v", types)
+		}
+	}
+
+	expected := []string{DeploymentQueued, DeploymentStarted, DeploymentFinished}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Errorf("Expected events %v, got %v", expected, types)
+			break
+		}
+	}
+}
+
+func TestReportHealth(t *testing.T) {
+	b := &Bus{}
+	received, cancel := b.Subscribe()
+	defer cancel()
+
+	// Only changes are published
+	b.ReportHealth("server1", true, "")
+	b.ReportHealth("server1", true, "")
+	b.ReportHealth("server1", false, "connection refused")
+
+	first, second := <-received, <-received
+	if first.Data.(Health).Healthy != true || second.Data.(Health).Healthy != false {
+		t.Errorf("Unexpected health events %+v, %+v", first, second)
+	}
+	select {
+	case event := <-received:
+		t.Errorf("Unexpected event %+v", event)
+	default:
+	}
+
+	// A nil bus discards reports
+	var nilBus *Bus
+	nilBus.ReportHealth("server1", true, "")
+}
@@ -0,0 +1,134 @@
+package deploy
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultApprovalTimeout is how long a deployment waits for approval before it expires.
+var DefaultApprovalTimeout = time.Hour
+
+// Approval records the approval gate of a deployment
+type Approval struct {
+	RequestedAt time.Time  `json:"requestedAt"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+	Decision    string     `json:"decision,omitempty"` // "approved", "rejected", "expired"
+	DecidedBy   string     `json:"decidedBy,omitempty"`
+	DecidedAt   *time.Time `json:"decidedAt,omitempty"`
+	Comment     string     `json:"comment,omitempty"`
+}
+
+// pendingDeployment holds everything needed to start a deployment once approved
+type pendingDeployment struct {
+	project    *Project
+	sshConfigs map[string]*SSHConfig
+	trigger    *Trigger
+	timer      *time.Timer
+}
+
+// RequestApproval registers a deployment that only starts after it is approved.
+// The request expires after timeout, or DefaultApprovalTimeout if timeout is zero.
+func (e *Engine) RequestApproval(deploymentID string, project *Project, sshConfigs map[string]*SSHConfig, trigger *Trigger, timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultApprovalTimeout
+	}
+
+	now := time.Now()
+
+	e.mu.Lock()
+	e.deployments[deploymentID] = &DeploymentStatus{
+		ID:          deploymentID,
+		ProjectName: project.Name,
+		Environment: project.Environment,
+		Version:     project.Version,
+		Status:      "awaiting_approval",
+		StartedAt:   now,
+		Trigger:     trigger,
+		Approval: &Approval{
+			RequestedAt: now,
+			ExpiresAt:   now.Add(timeout),
+		},
+	}
+	e.pending[deploymentID] = &pendingDeployment{
+		project:    project,
+		sshConfigs: sshConfigs,
+		trigger:    trigger,
+		timer: time.AfterFunc(timeout, func() {
+			e.expireApproval(deploymentID)
+		}),
+	}
+	e.mu.Unlock()
+
+	e.broadcastLog(deploymentID, LogTypeStatus, "Deployment awaiting approval")
+}
+
+// Approve approves a pending deployment and queues it
+func (e *Engine) Approve(deploymentID string, user string, comment string) error {
+	pending, err := e.decide(deploymentID, "approved", user, comment)
+	if err != nil {
+		return err
+	}
+
+	e.broadcastLog(deploymentID, LogTypeStatus, fmt.Sprintf("Deployment approved by %s", user))
+
+	e.schedule(deploymentID, pending.project, pending.sshConfigs, pending.trigger)
+
+	return nil
+}
+
+// Reject rejects a pending deployment so it never runs. Hooks see it cancelled
+// with the status "rejected".
+func (e *Engine) Reject(deploymentID string, user string, comment string) error {
+	if _, err := e.decide(deploymentID, "rejected", user, comment); err != nil {
+		return err
+	}
+
+	e.broadcastLog(deploymentID, LogTypeStatus, fmt.Sprintf("Deployment rejected by %s", user))
+	e.emit(deploymentID, EventCancelled)
+	return nil
+}
+
+// expireApproval marks a deployment as expired if it is still awaiting
+// approval. Hooks see it cancelled with the status "expired".
+func (e *Engine) expireApproval(deploymentID string) {
+	if _, err := e.decide(deploymentID, "expired", "", ""); err != nil {
+		return
+	}
+
+	e.broadcastLog(deploymentID, LogTypeStatus, "Deployment approval expired")
+	e.emit(deploymentID, EventCancelled)
+}
+
+// decide records the decision on a pending deployment and removes it from the pending set
+func (e *Engine) decide(deploymentID string, decision string, user string, comment string) (*pendingDeployment, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status, exists := e.deployments[deploymentID]
+	if !exists {
+		return nil, fmt.Errorf("deployment not found")
+	}
+
+	pending, exists := e.pending[deploymentID]
+	if !exists || status.Status != "awaiting_approval" {
+		return nil, fmt.Errorf("deployment is not awaiting approval")
+	}
+
+	pending.timer.Stop()
+	delete(e.pending, deploymentID)
+
+	now := time.Now()
+	status.Approval.Decision = decision
+	status.Approval.DecidedBy = user
+	status.Approval.DecidedAt = &now
+	status.Approval.Comment = comment
+	if decision == "approved" {
+		status.Status = "queued"
+		status.StartedAt = now
+	} else {
+		status.Status = decision
+		status.CompletedAt = &now
+	}
+
+	return pending, nil
+}
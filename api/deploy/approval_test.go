@@ -0,0 +1,172 @@
+package deploy
+
+import (
+	"testing"
+	"time"
+)
+
+func newApprovalTestProject() *Project {
+	return &Project{
+		Name:          "test-project",
+		DeployServers: []string{},
+	}
+}
+
+func TestRequestApproval(t *testing.T) {
+	engine := NewEngine()
+	deploymentID := "test-deployment-1"
+
+	engine.RequestApproval(deploymentID, newApprovalTestProject(), map[string]*SSHConfig{}, &Trigger{Source: "manual"}, time.Minute)
+
+	status, exists := engine.GetStatus(deploymentID)
+	if !exists {
+		t.Fatal("Deployment not found")
+	}
+	if status.Status != "awaiting_approval" {
+		t.Errorf("Expected status 'awaiting_approval', got %s", status.Status)
+	}
+	if status.Approval == nil {
+		t.Fatal("Expected approval to be recorded")
+	}
+	if !status.Approval.ExpiresAt.After(status.Approval.RequestedAt) {
+		t.Error("Expected ExpiresAt to be after RequestedAt")
+	}
+	if _, exists := engine.pending[deploymentID]; !exists {
+		t.Error("Expected deployment to be pending")
+	}
+}
+
+func TestApprove(t *testing.T) {
+	engine := NewEngine()
+	deploymentID := "test-deployment-1"
+
+	// Approving an unknown deployment fails
+	if err := engine.Approve(deploymentID, "alice", ""); err == nil {
+		t.Error("Expected error when approving non-existent deployment")
+	}
+
+	engine.RequestApproval(deploymentID, newApprovalTestProject(), map[string]*SSHConfig{}, nil, time.Minute)
+
+	if err := engine.Approve(deploymentID, "alice", "ship it"); err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+
+	status, _ := engine.GetStatus(deploymentID)
+	if status.Approval.Decision != "approved" {
+		t.Errorf("Expected decision 'approved', got %s", status.Approval.Decision)
+	}
+	if status.Approval.DecidedBy != "alice" {
+		t.Errorf("Expected decidedBy 'alice', got %s", status.Approval.DecidedBy)
+	}
+	if status.Approval.DecidedAt == nil {
+		t.Error("Expected DecidedAt to be set")
+	}
+	if status.Approval.Comment != "ship it" {
+		t.Errorf("Expected comment 'ship it', got %s", status.Approval.Comment)
+	}
+
+	// Wait for the approved deployment to run
+	time.Sleep(100 * time.Millisecond)
+
+	status, _ = engine.GetStatus(deploymentID)
+	if status.Status != "success" {
+		t.Errorf("Expected status 'success', got %s", status.Status)
+	}
+
+	// A deployment can only be approved once
+	if err := engine.Approve(deploymentID, "bob", ""); err == nil {
+		t.Error("Expected error when approving a deployment twice")
+	}
+}
+
+// recordHooks returns a channel receiving the lifecycle events of engine
+func recordHooks(engine *Engine) <-chan string {
+	events := make(chan string, 16)
+	engine.AddHook(func(event string, status DeploymentStatus) {
+		events <- event + ":" + status.Status
+	})
+	return events
+}
+
+func TestReject(t *testing.T) {
+	engine := NewEngine()
+	events := recordHooks(engine)
+	deploymentID := "test-deployment-1"
+
+	engine.RequestApproval(deploymentID, newApprovalTestProject(), map[string]*SSHConfig{}, nil, time.Minute)
+
+	if err := engine.Reject(deploymentID, "bob", "not today"); err != nil {
+		t.Fatalf("Reject failed: %v", err)
+	}
+
+	status, _ := engine.GetStatus(deploymentID)
+	if status.Status != "rejected" {
+		t.Errorf("Expected status 'rejected', got %s", status.Status)
+	}
+	if status.Approval.DecidedBy != "bob" {
+		t.Errorf("Expected decidedBy 'bob', got %s", status.Approval.DecidedBy)
+	}
+	if status.CompletedAt == nil {
+		t.Error("Expected CompletedAt to be set")
+	}
+	if event := <-events; event != EventCancelled+":rejected" {
+		t.Errorf("Expected hooks to see the deployment rejected, got %s", event)
+	}
+
+	// A rejected deployment can no longer be approved
+	if err := engine.Approve(deploymentID, "alice", ""); err == nil {
+		t.Error("Expected error when approving a rejected deployment")
+	}
+}
+
+func TestApprovalExpires(t *testing.T) {
+	engine := NewEngine()
+	events := recordHooks(engine)
+	deploymentID := "test-deployment-1"
+
+	engine.RequestApproval(deploymentID, newApprovalTestProject(), map[string]*SSHConfig{}, nil, 50*time.Millisecond)
+
+	time.Sleep(150 * time.Millisecond)
+
+	status, _ := engine.GetStatus(deploymentID)
+	if status.Status != "expired" {
+		t.Errorf("Expected status 'expired', got %s", status.Status)
+	}
+	if status.Approval.Decision != "expired" {
+		t.Errorf("Expected decision 'expired', got %s", status.Approval.Decision)
+	}
+	select {
+	case event := <-events:
+		if event != EventCancelled+":expired" {
+			t.Errorf("Expected hooks to see the deployment expired, got %s", event)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected hooks to see the deployment expired")
+	}
+
+	if err := engine.Approve(deploymentID, "alice", ""); err == nil {
+		t.Error("Expected error when approving an expired deployment")
+	}
+}
+
+func TestListDeployments(t *testing.T) {
+	engine := NewEngine()
+	now := time.Now()
+
+	engine.deployments["a-1"] = &DeploymentStatus{ID: "a-1", ProjectName: "a", Status: "success", StartedAt: now.Add(-2 * time.Minute)}
+	engine.deployments["a-2"] = &DeploymentStatus{ID: "a-2", ProjectName: "a", Status: "running", StartedAt: now}
+	engine.deployments["b-1"] = &DeploymentStatus{ID: "b-1", ProjectName: "b", Status: "failed", StartedAt: now.Add(-time.Minute)}
+
+	all := engine.ListDeployments("")
+	if len(all) != 3 {
+		t.Fatalf("Expected 3 deployments, got %d", len(all))
+	}
+	if all[0].ID != "a-2" || all[1].ID != "b-1" || all[2].ID != "a-1" {
+		t.Errorf("Expected deployments newest first, got %s, %s, %s", all[0].ID, all[1].ID, all[2].ID)
+	}
+
+	filtered := engine.ListDeployments("a")
+	if len(filtered) != 2 {
+		t.Errorf("Expected 2 deployments for project 'a', got %d", len(filtered))
+	}
+}
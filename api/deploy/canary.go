@@ -0,0 +1,263 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CanaryHealthInterval is how often the health check runs on canary servers while they bake.
+var CanaryHealthInterval = 30 * time.Second
+
+// Canary configures the canary stage of a deployment. The canary servers are
+// deployed first, then the deployment pauses until it is promoted or aborted.
+type Canary struct {
+	Servers         []string      `json:"servers"`                   // subset of DeployServers deployed first
+	BakeTime        time.Duration `json:"bakeTime,omitempty"`        // promote automatically after this long; zero waits for a manual promotion
+	HealthCheck     string        `json:"healthCheck,omitempty"`     // command that must keep succeeding on the canary servers
+	RollbackScript  string        `json:"rollbackScript,omitempty"`  // run on the canary servers when aborted, defaults to the deploy script
+	RollbackVersion string        `json:"rollbackVersion,omitempty"` // version the canary servers are rolled back to
+}
+
+// CanaryStatus records the canary stage of a deployment
+type CanaryStatus struct {
+	Servers    []string   `json:"servers"`
+	PausedAt   *time.Time `json:"pausedAt,omitempty"`
+	PromoteAt  *time.Time `json:"promoteAt,omitempty"` // automatic promotion time if a bake time is set
+	Decision   string     `json:"decision,omitempty"`  // "promoted", "aborted"
+	DecidedBy  string     `json:"decidedBy,omitempty"` // empty for automatic decisions
+	DecidedAt  *time.Time `json:"decidedAt,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+	RolledBack bool       `json:"rolledBack,omitempty"`
+}
+
+// canaryDecision ends the pause of a canary deployment
+type canaryDecision struct {
+	promote bool
+	user    string
+	reason  string
+	failed  bool // aborted because the canary is unhealthy
+}
+
+// Promote promotes a paused canary deployment to the rest of its servers
+func (e *Engine) Promote(deploymentID string, user string) error {
+	return e.decideCanary(deploymentID, canaryDecision{
+		promote: true,
+		user:    user,
+		reason:  fmt.Sprintf("promoted by %s", user),
+	})
+}
+
+// Abort aborts a paused canary deployment and rolls back its canary servers
+func (e *Engine) Abort(deploymentID string, user string) error {
+	return e.decideCanary(deploymentID, canaryDecision{
+		user:   user,
+		reason: fmt.Sprintf("aborted by %s", user),
+	})
+}
+
+// decideCanary records the decision on a paused canary deployment and resumes it
+func (e *Engine) decideCanary(deploymentID string, decision canaryDecision) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	status, exists := e.deployments[deploymentID]
+	if !exists {
+		return fmt.Errorf("deployment not found")
+	}
+
+	decisions, exists := e.canaries[deploymentID]
+	if !exists || status.Status != "canary" {
+		return fmt.Errorf("deployment is not awaiting canary promotion")
+	}
+	delete(e.canaries, deploymentID)
+
+	now := time.Now()
+	status.Canary.Decision = "aborted"
+	if decision.promote {
+		status.Canary.Decision = "promoted"
+		status.Status = "running"
+	}
+	status.Canary.DecidedBy = decision.user
+	status.Canary.DecidedAt = &now
+	status.Canary.Reason = decision.reason
+
+	decisions <- decision
+	return nil
+}
+
+// runCanary deploys to the canary servers and waits until the canary is promoted
+// or aborted. An aborted canary is rolled back and ends the deployment with an error.
+func (e *Engine) runCanary(ctx context.Context, deploymentID string, project *Project, sshConfigs map[string]*SSHConfig) error {
+	canary := project.Canary
+
+	e.mu.Lock()
+	if status, exists := e.deployments[deploymentID]; exists {
+		status.Canary = &CanaryStatus{Servers: canary.Servers}
+	}
+	e.mu.Unlock()
+
+	e.broadcastLog(deploymentID, LogTypeStatus, fmt.Sprintf("Deploying canary to %s", strings.Join(canary.Servers, ", ")))
+	if err := e.deployServers(ctx, deploymentID, project, sshConfigs, canary.Servers); err != nil {
+		return err
+	}
+
+	decisions := make(chan canaryDecision, 1)
+	now := time.Now()
+
+	e.mu.Lock()
+	if status, exists := e.deployments[deploymentID]; exists {
+		status.Status = "canary"
+		status.Canary.PausedAt = &now
+		if canary.BakeTime > 0 {
+			promoteAt := now.Add(canary.BakeTime)
+			status.Canary.PromoteAt = &promoteAt
+		}
+	}
+	e.canaries[deploymentID] = decisions
+	e.mu.Unlock()
+
+	if canary.BakeTime > 0 {
+		e.broadcastLog(deploymentID, LogTypeStatus, fmt.Sprintf("Canary deployed, promoting automatically in %s", canary.BakeTime))
+	} else {
+		e.broadcastLog(deploymentID, LogTypeStatus, "Canary deployed, waiting for promotion")
+	}
+
+	resume := e.releaseWorker(deploymentID)
+	decision := e.waitCanary(ctx, deploymentID, project, sshConfigs, decisions)
+	resume()
+	if decision.promote {
+		e.broadcastLog(deploymentID, LogTypeStatus, fmt.Sprintf("Canary %s", decision.reason))
+		return nil
+	}
+
+	e.broadcastLog(deploymentID, LogTypeStatus, fmt.Sprintf("Canary %s, rolling back canary servers", decision.reason))
+	rolledBack := e.rollbackCanary(context.WithoutCancel(ctx), deploymentID, project, sshConfigs)
+
+	e.mu.Lock()
+	if status, exists := e.deployments[deploymentID]; exists {
+		status.Canary.RolledBack = rolledBack
+	}
+	e.mu.Unlock()
+
+	if decision.failed {
+		e.failDeployment(deploymentID, fmt.Sprintf("Canary %s", decision.reason))
+	} else {
+		e.abortDeployment(deploymentID, fmt.Sprintf("Canary %s", decision.reason))
+	}
+	return fmt.Errorf("canary %s", decision.reason)
+}
+
+// waitCanary waits for a decision on a paused canary. While waiting it runs the
+// health check and promotes the canary once the bake time has elapsed.
+func (e *Engine) waitCanary(ctx context.Context, deploymentID string, project *Project, sshConfigs map[string]*SSHConfig, decisions <-chan canaryDecision) canaryDecision {
+	canary := project.Canary
+
+	var bake <-chan time.Time
+	if canary.BakeTime > 0 {
+		timer := time.NewTimer(canary.BakeTime)
+		defer timer.Stop()
+		bake = timer.C
+	}
+
+	var health <-chan time.Time
+	if canary.HealthCheck != "" {
+		ticker := time.NewTicker(CanaryHealthInterval)
+		defer ticker.Stop()
+		health = ticker.C
+	}
+
+	// Automatic decisions lose against a concurrent manual one, which is then
+	// received from decisions on the next iteration
+	for {
+		select {
+		case decision := <-decisions:
+			return decision
+		case <-ctx.Done():
+			_ = e.decideCanary(deploymentID, canaryDecision{reason: ctx.Err().Error(), failed: true})
+		case <-health:
+			if err := e.checkCanaryHealth(ctx, deploymentID, project, sshConfigs); err != nil {
+				_ = e.decideCanary(deploymentID, canaryDecision{reason: fmt.Sprintf("health check failed on %v", err), failed: true})
+			}
+		case <-bake:
+			bake = nil
+			if canary.HealthCheck != "" {
+				if err := e.checkCanaryHealth(ctx, deploymentID, project, sshConfigs); err != nil {
+					_ = e.decideCanary(deploymentID, canaryDecision{reason: fmt.Sprintf("health check failed on %v", err), failed: true})
+					continue
+				}
+			}
+			_ = e.decideCanary(deploymentID, canaryDecision{promote: true, reason: "promoted after bake time"})
+		}
+	}
+}
+
+// checkCanaryHealth runs the health check on every canary server
+func (e *Engine) checkCanaryHealth(ctx context.Context, deploymentID string, project *Project, sshConfigs map[string]*SSHConfig) error {
+	for _, serverName := range project.Canary.Servers {
+		client, release, err := e.connect(sshConfigs[serverName])
+		if err != nil {
+			return fmt.Errorf("%s: %w", serverName, err)
+		}
+
+		cmd, err := client.CommandContext(ctx, "bash", "-c", shellQuote(commandEnv(project)+project.Canary.HealthCheck))
+		if err != nil {
+			release()
+			return fmt.Errorf("%s: %w", serverName, err)
+		}
+
+		output, err := cmd.CombinedOutput()
+		cmd.Close()
+		release()
+		if err != nil {
+			return fmt.Errorf("%s: %w: %s", serverName, err, strings.TrimSpace(string(output)))
+		}
+	}
+
+	e.broadcastLog(deploymentID, LogTypeLog, "Canary health check passed")
+	return nil
+}
+
+// rollbackCanary redeploys the rollback version, or runs the rollback script, on
+// the canary servers. It reports whether all canary servers were rolled back.
+func (e *Engine) rollbackCanary(ctx context.Context, deploymentID string, project *Project, sshConfigs map[string]*SSHConfig) bool {
+	canary := project.Canary
+	if canary.RollbackScript == "" && canary.RollbackVersion == "" {
+		e.broadcastLog(deploymentID, LogTypeError, "No previous version to roll back the canary servers to")
+		return false
+	}
+
+	rollback := *project
+	rollback.Version = canary.RollbackVersion
+	if canary.RollbackScript != "" {
+		rollback.DeployScript = canary.RollbackScript
+	}
+
+	rolledBack := true
+	for _, serverName := range canary.Servers {
+		e.broadcastLog(deploymentID, LogTypeLog, fmt.Sprintf("Rolling back server: %s", serverName))
+		if err := e.deployToServer(ctx, deploymentID, &rollback, sshConfigs[serverName]); err != nil {
+			e.broadcastLog(deploymentID, LogTypeError, fmt.Sprintf("Rollback of %s failed: %v", serverName, err))
+			rolledBack = false
+			continue
+		}
+		e.broadcastLog(deploymentID, LogTypeLog, fmt.Sprintf("Successfully rolled back %s", serverName))
+	}
+	return rolledBack
+}
+
+// abortDeployment marks a deployment as aborted
+func (e *Engine) abortDeployment(deploymentID string, reason string) {
+	e.mu.Lock()
+	if status, exists := e.deployments[deploymentID]; exists {
+		now := time.Now()
+		status.Status = "aborted"
+		status.CompletedAt = &now
+		status.Error = reason
+	}
+	e.mu.Unlock()
+
+	e.broadcastLog(deploymentID, LogTypeStatus, "Deployment aborted")
+	e.emit(deploymentID, EventCancelled)
+}
@@ -0,0 +1,204 @@
+package deploy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCanaryTestFleet starts a test SSH server per host, each in its own directory
+func newCanaryTestFleet(t *testing.T, names ...string) (map[string]*SSHConfig, map[string]string) {
+	sshConfigs := make(map[string]*SSHConfig)
+	dirs := make(map[string]string)
+	for _, name := range names {
+		dirs[name] = t.TempDir()
+		sshConfigs[name] = newTestSSHD(t, name, dirs[name])
+	}
+	return sshConfigs, dirs
+}
+
+func newCanaryTestProject(canary *Canary) *Project {
+	return &Project{
+		Name:          "test-project",
+		DeployScript:  `echo "$ED_VERSION" > version`,
+		DeployServers: []string{"web-1", "web-2", "web-3"},
+		Version:       "v2",
+		Canary:        canary,
+	}
+}
+
+// waitForDeploymentStatus waits until a deployment reaches status and returns a copy of it
+func waitForDeploymentStatus(t *testing.T, engine *Engine, deploymentID string, want string) DeploymentStatus {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		var status DeploymentStatus
+		engine.mu.RLock()
+		if current, exists := engine.deployments[deploymentID]; exists {
+			status = *current
+		}
+		engine.mu.RUnlock()
+
+		if status.Status == want {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for status %s, got %s (%s)", want, status.Status, status.Error)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// deployedVersion returns the version deployed into a test host directory
+func deployedVersion(dir string) string {
+	data, err := os.ReadFile(filepath.Join(dir, "version"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func TestCanaryPromote(t *testing.T) {
+	engine := NewEngine()
+	sshConfigs, dirs := newCanaryTestFleet(t, "web-1", "web-2", "web-3")
+	project := newCanaryTestProject(&Canary{Servers: []string{"web-1"}})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- engine.DeployWithTrigger(context.Background(), "canary-1", project, sshConfigs, nil)
+	}()
+
+	status := waitForDeploymentStatus(t, engine, "canary-1", "canary")
+	if status.Canary == nil || status.Canary.PausedAt == nil {
+		t.Fatalf("Expected canary to be paused, got %+v", status.Canary)
+	}
+	if status.Canary.PromoteAt != nil {
+		t.Error("Expected no automatic promotion without a bake time")
+	}
+	if got := deployedVersion(dirs["web-1"]); got != "v2" {
+		t.Errorf("Expected canary to run v2, got %q", got)
+	}
+	if got := deployedVersion(dirs["web-2"]); got != "" {
+		t.Errorf("Expected web-2 to wait for promotion, got %q", got)
+	}
+
+	if err := engine.Promote("canary-1", "alice"); err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Deployment failed: %v", err)
+	}
+
+	status = waitForDeploymentStatus(t, engine, "canary-1", "success")
+	if status.Canary.Decision != "promoted" || status.Canary.DecidedBy != "alice" {
+		t.Errorf("Expected promotion by alice, got %+v", status.Canary)
+	}
+	for _, name := range []string{"web-2", "web-3"} {
+		if got := deployedVersion(dirs[name]); got != "v2" {
+			t.Errorf("Expected %s to run v2, got %q", name, got)
+		}
+	}
+
+	// A canary can only be decided once
+	if err := engine.Promote("canary-1", "bob"); err == nil {
+		t.Error("Expected error when promoting a finished deployment")
+	}
+}
+
+func TestCanaryAbortRollsBack(t *testing.T) {
+	engine := NewEngine()
+	sshConfigs, dirs := newCanaryTestFleet(t, "web-1", "web-2", "web-3")
+	project := newCanaryTestProject(&Canary{Servers: []string{"web-1", "web-2"}, RollbackVersion: "v1"})
+
+	go engine.DeployWithTrigger(context.Background(), "canary-1", project, sshConfigs, nil)
+
+	waitForDeploymentStatus(t, engine, "canary-1", "canary")
+	if err := engine.Abort("canary-1", "alice"); err != nil {
+		t.Fatalf("Abort failed: %v", err)
+	}
+
+	status := waitForDeploymentStatus(t, engine, "canary-1", "aborted")
+	if !status.Canary.RolledBack {
+		t.Error("Expected canary servers to be rolled back")
+	}
+	if status.Canary.Decision != "aborted" {
+		t.Errorf("Expected decision 'aborted', got %s", status.Canary.Decision)
+	}
+	for _, name := range []string{"web-1", "web-2"} {
+		if got := deployedVersion(dirs[name]); got != "v1" {
+			t.Errorf("Expected %s to be rolled back to v1, got %q", name, got)
+		}
+	}
+	if got := deployedVersion(dirs["web-3"]); got != "" {
+		t.Errorf("Expected web-3 to be untouched, got %q", got)
+	}
+}
+
+func TestCanaryPromotesAfterBakeTime(t *testing.T) {
+	interval := CanaryHealthInterval
+	CanaryHealthInterval = 50 * time.Millisecond
+	defer func() { CanaryHealthInterval = interval }()
+
+	engine := NewEngine()
+	sshConfigs, dirs := newCanaryTestFleet(t, "web-1", "web-2", "web-3")
+	project := newCanaryTestProject(&Canary{
+		Servers:     []string{"web-1"},
+		BakeTime:    300 * time.Millisecond,
+		HealthCheck: `test "$(cat version)" = "$ED_VERSION"`,
+	})
+
+	go engine.DeployWithTrigger(context.Background(), "canary-1", project, sshConfigs, nil)
+
+	status := waitForDeploymentStatus(t, engine, "canary-1", "success")
+	if status.Canary.Decision != "promoted" || status.Canary.DecidedBy != "" {
+		t.Errorf("Expected automatic promotion, got %+v", status.Canary)
+	}
+	if got := deployedVersion(dirs["web-3"]); got != "v2" {
+		t.Errorf("Expected web-3 to run v2, got %q", got)
+	}
+}
+
+func TestCanaryHealthCheckFailure(t *testing.T) {
+	interval := CanaryHealthInterval
+	CanaryHealthInterval = 50 * time.Millisecond
+	defer func() { CanaryHealthInterval = interval }()
+
+	engine := NewEngine()
+	sshConfigs, dirs := newCanaryTestFleet(t, "web-1", "web-2", "web-3")
+	project := newCanaryTestProject(&Canary{
+		Servers:        []string{"web-1"},
+		BakeTime:       time.Minute,
+		HealthCheck:    "exit 3",
+		RollbackScript: "rm version",
+	})
+
+	go engine.DeployWithTrigger(context.Background(), "canary-1", project, sshConfigs, nil)
+
+	status := waitForDeploymentStatus(t, engine, "canary-1", "failed")
+	if !strings.Contains(status.Error, "health check failed on web-1") {
+		t.Errorf("Expected health check error, got %q", status.Error)
+	}
+	if !status.Canary.RolledBack {
+		t.Error("Expected canary servers to be rolled back")
+	}
+	if _, err := os.Stat(filepath.Join(dirs["web-1"], "version")); !os.IsNotExist(err) {
+		t.Error("Expected rollback script to run on web-1")
+	}
+}
+
+func TestPromoteErrors(t *testing.T) {
+	engine := NewEngine()
+
+	if err := engine.Promote("missing", "alice"); err == nil {
+		t.Error("Expected error when promoting non-existent deployment")
+	}
+
+	engine.deployments["running-1"] = &DeploymentStatus{ID: "running-1", Status: "running"}
+	if err := engine.Abort("running-1", "alice"); err == nil {
+		t.Error("Expected error when aborting a deployment without a paused canary")
+	}
+}
@@ -0,0 +1,137 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	edssh "github.com/diiyw/ed/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// writeTestUserCertificate writes a key pair and its user certificate, signed
+// by ca for the deploy user, and returns the private key file
+func writeTestUserCertificate(t *testing.T, ca ssh.Signer, validAfter time.Time, validBefore time.Time) string {
+	t.Helper()
+
+	keyFile, authorizedKey := writeTestKey(t, t.TempDir(), "id_ed25519")
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := newTestCertificate(t, key, ca, ssh.UserCert, []string{"deploy"}, validAfter, validBefore)
+	writeTestFile(t, keyFile+"-cert.pub", string(ssh.MarshalAuthorizedKey(cert)), 0644)
+	return keyFile
+}
+
+func TestCertificateAuth(t *testing.T) {
+	server := newTestSSHD(t, "web-1", t.TempDir())
+	engine := NewEngine()
+
+	sshConfig := *server
+	sshConfig.AuthType = "certificate"
+	sshConfig.Password = ""
+	sshConfig.KeyFile = writeTestUserCertificate(t, testUserCA, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+
+	client, release, err := engine.connect(&sshConfig)
+	if err != nil {
+		t.Fatalf("Failed to connect with certificate: %v", err)
+	}
+	defer release()
+	if output, err := client.Run("echo ok"); err != nil || string(output) != "ok\n" {
+		t.Errorf("Expected the command to run, got %q: %v", output, err)
+	}
+
+	// A certificate of another CA is rejected by the server
+	otherCA, err := newTestSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshConfig.KeyFile = writeTestUserCertificate(t, otherCA, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if _, _, err := engine.connect(&sshConfig); err == nil {
+		t.Error("Expected a certificate of an unknown CA to be rejected")
+	}
+}
+
+func TestCertificateAuth_Invalid(t *testing.T) {
+	expired := writeTestUserCertificate(t, testUserCA, time.Now().Add(-2*time.Hour), time.Now().Add(-time.Hour))
+	future := writeTestUserCertificate(t, testUserCA, time.Now().Add(time.Hour), time.Now().Add(2*time.Hour))
+	valid := writeTestUserCertificate(t, testUserCA, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	otherKey, _ := writeTestKey(t, t.TempDir(), "other")
+
+	tests := []struct {
+		name   string
+		config SSHConfig
+		want   string
+	}{
+		{"expired", SSHConfig{AuthType: "certificate", KeyFile: expired}, "expired at"},
+		{"not yet valid", SSHConfig{AuthType: "certificate", KeyFile: future}, "not valid before"},
+		{"certificate of another key", SSHConfig{AuthType: "certificate", KeyFile: otherKey, CertFile: valid + "-cert.pub"}, "does not belong"},
+		{"missing certificate", SSHConfig{AuthType: "certificate", KeyFile: otherKey}, "no such file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Fails before dialling, the config points nowhere
+			tt.config.Host = "192.0.2.1"
+			_, _, err := NewEngine().connect(&tt.config)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestHostCertificateAuthority(t *testing.T) {
+	hostCA, err := newTestSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostKey, err := newTestSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := newTestCertificate(t, hostKey.PublicKey(), hostCA, ssh.HostCert, []string{"127.0.0.1"}, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	certSigner, err := ssh.NewCertSigner(cert, hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := startTestSSHD(t, "web-1", t.TempDir(), certSigner)
+
+	// Without a CA the host is unknown, and reported by its plain key
+	engine := NewEngine()
+	engine.SetKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+	_, _, err = engine.connect(server)
+	var hostErr *edssh.HostKeyError
+	if !errors.As(err, &hostErr) || !hostErr.Unknown() || edssh.Fingerprint(hostErr.Key) != edssh.Fingerprint(hostKey.PublicKey()) {
+		t.Fatalf("Expected the plain host key to be unknown, got %v", err)
+	}
+
+	// A trusted CA vouches for the host, CA lines match the port too
+	address := net.JoinHostPort(server.Host, fmt.Sprint(server.Port))
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	writeTestFile(t, knownHosts, "@cert-authority "+knownhosts.Normalize(address)+" "+string(ssh.MarshalAuthorizedKey(hostCA.PublicKey())), 0600)
+	engine.SetKnownHosts(knownHosts)
+	if _, release, err := engine.connect(server); err != nil {
+		t.Fatalf("Expected the host certificate to be trusted, got %v", err)
+	} else {
+		release()
+	}
+
+	// A pinned plain key is accepted whatever CA signed the certificate
+	pinned := filepath.Join(t.TempDir(), "known_hosts")
+	if err := edssh.PinHostKey(address, cert, pinned); err != nil {
+		t.Fatal(err)
+	}
+	engine.SetKnownHosts(pinned)
+	if _, release, err := engine.connect(server); err != nil {
+		t.Fatalf("Expected the pinned host key to be trusted, got %v", err)
+	} else {
+		release()
+	}
+}
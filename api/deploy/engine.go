@@ -2,14 +2,18 @@ package deploy
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/diiyw/ed/ssh"
-	"github.com/gorilla/websocket"
 	xssh "golang.org/x/crypto/ssh"
 )
 
@@ -31,133 +35,341 @@ type DeploymentLog struct {
 
 // DeploymentStatus represents the status of a deployment
 type DeploymentStatus struct {
-	ID          string     `json:"id"`
-	ProjectName string     `json:"projectName"`
-	Status      string     `json:"status"` // "pending", "running", "success", "failed"
-	StartedAt   time.Time  `json:"startedAt"`
-	CompletedAt *time.Time `json:"completedAt,omitempty"`
+	ID          string        `json:"id"`
+	ProjectName string        `json:"projectName"`
+	Environment string        `json:"environment,omitempty"`
+	Version     string        `json:"version,omitempty"`
+	Status      string        `json:"status"` // "awaiting_approval", "queued", "running", "canary", "success", "failed", "aborted", "removed", "rejected", "expired"
+	StartedAt   time.Time     `json:"startedAt"`
+	CompletedAt *time.Time    `json:"completedAt,omitempty"`
+	Trigger     *Trigger      `json:"trigger,omitempty"`
+	Approval    *Approval     `json:"approval,omitempty"`
+	Canary      *CanaryStatus `json:"canary,omitempty"`
+	Error       string        `json:"error,omitempty"`
+
+	Notifications []NotificationResult `json:"notifications,omitempty"`
+}
+
+// Trigger describes what started a deployment
+type Trigger struct {
+	Source string `json:"source"` // "manual", "webhook", "promotion"
+	Event  string `json:"event,omitempty"`
+	Ref    string `json:"ref,omitempty"`
+	Commit string `json:"commit,omitempty"`
+	From   string `json:"from,omitempty"` // deployment ID a promotion was taken from
+
+	Override *FreezeOverride `json:"override,omitempty"`
+}
+
+// FreezeOverride records a deployment forced through a freeze window
+type FreezeOverride struct {
+	User   string `json:"user"`
+	Reason string `json:"reason,omitempty"`
+	Window string `json:"window"` // name of the freeze window that was overridden
+	Token  string `json:"-"`      // proves the user is permitted, never recorded
 }
 
 // SSHConfig represents an SSH server configuration
 type SSHConfig struct {
-	Name     string `json:"name"`
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	User     string `json:"user"`
-	AuthType string `json:"auth_type"`
-	Password string `json:"password,omitempty"`
-	KeyFile  string `json:"key_file,omitempty"`
-	KeyPass  string `json:"key_pass,omitempty"`
+	Name     string     `json:"name"`
+	Host     string     `json:"host"`
+	Port     int        `json:"port"`
+	User     string     `json:"user"`
+	AuthType string     `json:"auth_type"`
+	Password string     `json:"password,omitempty"`
+	KeyFile  string     `json:"key_file,omitempty"`
+	KeyPass  string     `json:"key_pass,omitempty"`
+	Sudo     *Sudo      `json:"sudo,omitempty"`
+	Jump     *SSHConfig `json:"jump,omitempty"` // jump host to connect through, which may have its own
+
+	PrivateKey  string   `json:"private_key,omitempty"`  // PEM private key of the inline-key auth type
+	CertFile    string   `json:"cert_file,omitempty"`    // certificate of the certificate auth type, KeyFile-cert.pub if empty
+	AuthMethods []string `json:"auth_methods,omitempty"` // auth types tried in order, overrides AuthType
 }
 
 // Project represents a deployable project
 type Project struct {
-	Name              string    `json:"name"`
-	BuildInstructions string    `json:"build_instructions"`
-	DeployScript      string    `json:"deploy_script"`
-	DeployServers     []string  `json:"deploy_servers"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	Name              string            `json:"name"`
+	BuildInstructions string            `json:"build_instructions"`
+	DeployScript      string            `json:"deploy_script"`
+	DeployServers     []string          `json:"deploy_servers"`
+	Environment       string            `json:"environment,omitempty"`
+	Version           string            `json:"version,omitempty"`
+	Variables         map[string]string `json:"variables,omitempty"`
+	Canary            *Canary           `json:"canary,omitempty"`
+	TTY               *TTY              `json:"tty,omitempty"`
+	Steps             []Step            `json:"steps,omitempty"`
+	Templates         []Template        `json:"templates,omitempty"`
+	Handlers          map[string]string `json:"handlers,omitempty"` // scripts run by changed templates, by name
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
 }
 
 // Engine manages deployment operations
 type Engine struct {
 	deployments map[string]*DeploymentStatus
-	clients     map[string][]*websocket.Conn
+	subscribers map[string][]chan Event
+	seq         map[string]uint64
+	history     map[string][]Event
+	logs        *LogStore
+	logLimit    int64
+	logBytes    map[string]int64 // log output bytes published per deployment
+	pending     map[string]*pendingDeployment
+	canaries    map[string]chan canaryDecision
+	hooks       []Hook
+	healthHooks []HealthHook
+	queue       []*job
+	busy        map[string]bool // projects with a running queued deployment
+	working     map[string]bool // queued deployments holding a worker
+	workers     int
+	running     int
+	knownHosts  string
+	pool        *ssh.Pool // connections shared by the operations on a server
 	mu          sync.RWMutex
 }
 
 // NewEngine creates a new deployment engine
 func NewEngine() *Engine {
-	return &Engine{
+	e := &Engine{
 		deployments: make(map[string]*DeploymentStatus),
-		clients:     make(map[string][]*websocket.Conn),
+		subscribers: make(map[string][]chan Event),
+		seq:         make(map[string]uint64),
+		history:     make(map[string][]Event),
+		logLimit:    DefaultLogLimit,
+		logBytes:    make(map[string]int64),
+		pending:     make(map[string]*pendingDeployment),
+		canaries:    make(map[string]chan canaryDecision),
+		busy:        make(map[string]bool),
+		working:     make(map[string]bool),
+		workers:     DefaultWorkers,
+		knownHosts:  DefaultKnownHostsFile,
+		pool:        ssh.NewPool(ssh.DefaultKeepAlive, ssh.DefaultIdleTimeout),
+	}
+	e.pool.OnDrop(e.connectionLost)
+	return e
+}
+
+// Auth types of SSH configs
+const (
+	AuthPassword            = "password"
+	AuthKeyboardInteractive = "keyboard-interactive"
+	AuthKey                 = "key"         // private key file at KeyFile
+	AuthInlineKey           = "inline-key"  // PEM private key stored in PrivateKey
+	AuthCertificate         = "certificate" // private key file at KeyFile and its OpenSSH certificate
+	AuthAgent               = "agent"
+)
+
+// authTypes returns the auth types tried in order, AuthMethods overriding AuthType
+func (sc *SSHConfig) authTypes() []string {
+	if len(sc.AuthMethods) > 0 {
+		return sc.AuthMethods
 	}
+	return []string{sc.AuthType}
 }
 
-// GetAuthMethod returns the SSH auth method for this config
+// CertificateFile returns the OpenSSH certificate of the certificate auth type
+func (sc *SSHConfig) CertificateFile() string {
+	if sc.CertFile == "" {
+		return sc.KeyFile + "-cert.pub"
+	}
+	return sc.CertFile
+}
+
+// UsesAuth reports whether an auth type is one of the auth types of the config
+func (sc *SSHConfig) UsesAuth(authType string) bool {
+	return slices.Contains(sc.authTypes(), authType)
+}
+
+// GetAuthMethod returns the SSH auth methods for this config, in the order
+// they are tried
 func (sc *SSHConfig) GetAuthMethod() (ssh.Auth, error) {
-	switch sc.AuthType {
-	case "password":
+	var auth ssh.Auth
+	for _, authType := range sc.authTypes() {
+		methods, err := sc.authMethod(authType)
+		if err != nil {
+			return nil, err
+		}
+		auth = append(auth, methods...)
+	}
+	return auth, nil
+}
+
+// authMethod returns the SSH auth methods of a single auth type
+func (sc *SSHConfig) authMethod(authType string) (ssh.Auth, error) {
+	switch authType {
+	case AuthPassword:
 		return ssh.Password(sc.Password), nil
-	case "key":
+	case AuthKeyboardInteractive:
+		return ssh.KeyboardInteractive(sc.Password), nil
+	case AuthKey:
 		return ssh.Key(sc.KeyFile, sc.KeyPass)
-	case "agent":
+	case AuthInlineKey:
+		return ssh.RawKey(sc.PrivateKey, sc.KeyPass)
+	case AuthCertificate:
+		return ssh.Certificate(sc.KeyFile, sc.CertificateFile(), sc.KeyPass)
+	case AuthAgent:
 		return ssh.UseAgent()
+	case "":
+		return nil, fmt.Errorf("no auth type set")
 	default:
-		return nil, fmt.Errorf("unknown auth type: %s", sc.AuthType)
+		return nil, fmt.Errorf("unknown auth type: %s", authType)
 	}
 }
 
-// RegisterClient registers a WebSocket client for a deployment
-func (e *Engine) RegisterClient(deploymentID string, conn *websocket.Conn) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
-
-	e.clients[deploymentID] = append(e.clients[deploymentID], conn)
-}
-
-// UnregisterClient removes a WebSocket client
-func (e *Engine) UnregisterClient(deploymentID string, conn *websocket.Conn) {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+// ValidateAuth checks that the auth types of a config are known and have
+// their credentials. Inline keys must parse with the key passphrase. A config
+// without auth type is valid until it is connected to.
+func (sc *SSHConfig) ValidateAuth() error {
+	if sc.AuthType == "" && len(sc.AuthMethods) == 0 {
+		return nil
+	}
 
-	clients := e.clients[deploymentID]
-	for i, c := range clients {
-		if c == conn {
-			e.clients[deploymentID] = append(clients[:i], clients[i+1:]...)
-			break
+	seen := make(map[string]bool)
+	for _, authType := range sc.authTypes() {
+		if seen[authType] {
+			return fmt.Errorf("auth type %s is listed twice", authType)
 		}
-	}
+		seen[authType] = true
 
-	if len(e.clients[deploymentID]) == 0 {
-		delete(e.clients, deploymentID)
+		switch authType {
+		case AuthPassword, AuthKeyboardInteractive, AuthAgent:
+		case AuthKey, AuthCertificate:
+			if sc.KeyFile == "" {
+				return fmt.Errorf("auth type %s requires key_file", authType)
+			}
+		case AuthInlineKey:
+			if sc.PrivateKey == "" {
+				return fmt.Errorf("auth type %s requires private_key", authType)
+			}
+			if _, err := ssh.RawKey(sc.PrivateKey, sc.KeyPass); err != nil {
+				return fmt.Errorf("invalid private_key: %w", err)
+			}
+		default:
+			return fmt.Errorf("unknown auth type: %q", authType)
+		}
 	}
+	return nil
 }
 
-// broadcastLog sends a log message to all connected clients
+// broadcastLog publishes a log message to all subscribers of a deployment
 func (e *Engine) broadcastLog(deploymentID string, logType LogType, message string) {
+	e.publish(deploymentID, Event{Type: string(logType), Data: message})
+}
+
+// serverLog publishes a log message about a server to all subscribers of a deployment
+func (e *Engine) serverLog(deploymentID string, server string, logType LogType, message string) {
+	e.publish(deploymentID, Event{Type: string(logType), Data: message, Server: server})
+}
+
+// GetStatus returns a copy of the status of a deployment
+func (e *Engine) GetStatus(deploymentID string) (*DeploymentStatus, bool) {
 	e.mu.RLock()
-	clients := e.clients[deploymentID]
-	e.mu.RUnlock()
+	defer e.mu.RUnlock()
+
+	status, exists := e.deployments[deploymentID]
+	if !exists {
+		return nil, false
+	}
+	snapshot := status.snapshot()
+	return &snapshot, true
+}
 
-	log := DeploymentLog{
-		Type:      string(logType),
-		Data:      message,
-		Timestamp: time.Now(),
+// snapshot returns a copy of a deployment status that is safe to read without
+// holding the engine lock. The caller must hold e.mu.
+func (s *DeploymentStatus) snapshot() DeploymentStatus {
+	result := *s
+	if s.Approval != nil {
+		approval := *s.Approval
+		result.Approval = &approval
+	}
+	if s.Canary != nil {
+		canary := *s.Canary
+		result.Canary = &canary
 	}
+	result.Notifications = slices.Clone(s.Notifications)
+	return result
+}
+
+// ListDeployments returns copies of all known deployments, newest first.
+// An empty projectName returns the deployments of all projects.
+func (e *Engine) ListDeployments(projectName string) []DeploymentStatus {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 
-	for _, conn := range clients {
-		if err := conn.WriteJSON(log); err != nil {
-			// Client disconnected, will be cleaned up later
+	result := make([]DeploymentStatus, 0, len(e.deployments))
+	for _, status := range e.deployments {
+		if projectName != "" && status.ProjectName != projectName {
 			continue
 		}
+		result = append(result, status.snapshot())
 	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartedAt.After(result[j].StartedAt)
+	})
+	return result
 }
 
-// GetStatus returns the status of a deployment
-func (e *Engine) GetStatus(deploymentID string) (*DeploymentStatus, bool) {
+// LastSuccessful returns a copy of the most recent successful deployment
+// of a project environment. An empty environment matches deployments without one.
+func (e *Engine) LastSuccessful(projectName string, environment string) (*DeploymentStatus, bool) {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
-	status, exists := e.deployments[deploymentID]
-	return status, exists
+	var last *DeploymentStatus
+	for _, status := range e.deployments {
+		if status.ProjectName != projectName || status.Environment != environment || status.Status != "success" {
+			continue
+		}
+		if last == nil || status.CompletedAt.After(*last.CompletedAt) {
+			last = status
+		}
+	}
+
+	if last == nil {
+		return nil, false
+	}
+	result := last.snapshot()
+	return &result, true
 }
 
 // Deploy executes a deployment
 func (e *Engine) Deploy(ctx context.Context, deploymentID string, project *Project, sshConfigs map[string]*SSHConfig) error {
+	return e.DeployWithTrigger(ctx, deploymentID, project, sshConfigs, nil)
+}
+
+// DeployWithTrigger executes a deployment and records what triggered it
+func (e *Engine) DeployWithTrigger(ctx context.Context, deploymentID string, project *Project, sshConfigs map[string]*SSHConfig, trigger *Trigger) error {
 	// Initialize deployment status
 	e.mu.Lock()
 	e.deployments[deploymentID] = &DeploymentStatus{
 		ID:          deploymentID,
 		ProjectName: project.Name,
+		Environment: project.Environment,
+		Version:     project.Version,
 		Status:      "running",
 		StartedAt:   time.Now(),
+		Trigger:     trigger,
 	}
 	e.mu.Unlock()
 
+	return e.run(ctx, deploymentID, project, sshConfigs, trigger)
+}
+
+// run executes the build and deploy steps of a registered deployment
+func (e *Engine) run(ctx context.Context, deploymentID string, project *Project, sshConfigs map[string]*SSHConfig, trigger *Trigger) error {
+	e.emit(deploymentID, EventStarted)
 	e.broadcastLog(deploymentID, LogTypeStatus, "Deployment started")
 	e.broadcastLog(deploymentID, LogTypeLog, fmt.Sprintf("Project: %s", project.Name))
+	if project.Environment != "" {
+		e.broadcastLog(deploymentID, LogTypeLog, fmt.Sprintf("Environment: %s", project.Environment))
+	}
+	if project.Version != "" {
+		e.broadcastLog(deploymentID, LogTypeLog, fmt.Sprintf("Version: %s", project.Version))
+	}
+	if trigger != nil && trigger.Ref != "" {
+		e.broadcastLog(deploymentID, LogTypeLog, fmt.Sprintf("Triggered by %s: %s @ %s", trigger.Source, trigger.Ref, trigger.Commit))
+	}
 
 	// Execute build instructions if provided
 	if project.BuildInstructions != "" {
@@ -169,8 +381,29 @@ func (e *Engine) Deploy(ctx context.Context, deploymentID string, project *Proje
 		e.broadcastLog(deploymentID, LogTypeLog, "Build completed successfully")
 	}
 
-	// Deploy to each server
-	for _, serverName := range project.DeployServers {
+	servers := project.DeployServers
+	if project.Canary != nil && len(project.Canary.Servers) > 0 {
+		if err := e.runCanary(ctx, deploymentID, project, sshConfigs); err != nil {
+			return err
+		}
+		servers = slices.DeleteFunc(slices.Clone(servers), func(name string) bool {
+			return slices.Contains(project.Canary.Servers, name)
+		})
+	}
+
+	if err := e.deployServers(ctx, deploymentID, project, sshConfigs, servers); err != nil {
+		return err
+	}
+
+	// Mark deployment as successful
+	e.completeDeployment(deploymentID)
+	return nil
+}
+
+// deployServers deploys to each of the given servers in order and fails the
+// deployment on the first error
+func (e *Engine) deployServers(ctx context.Context, deploymentID string, project *Project, sshConfigs map[string]*SSHConfig, servers []string) error {
+	for _, serverName := range servers {
 		sshConfig, exists := sshConfigs[serverName]
 		if !exists {
 			err := fmt.Errorf("SSH config not found: %s", serverName)
@@ -185,9 +418,6 @@ func (e *Engine) Deploy(ctx context.Context, deploymentID string, project *Proje
 		}
 		e.broadcastLog(deploymentID, LogTypeLog, fmt.Sprintf("Successfully deployed to %s", serverName))
 	}
-
-	// Mark deployment as successful
-	e.completeDeployment(deploymentID)
 	return nil
 }
 
@@ -218,94 +448,293 @@ func (e *Engine) executeBuild(ctx context.Context, deploymentID string, buildIns
 
 // deployToServer deploys to a single SSH server
 func (e *Engine) deployToServer(ctx context.Context, deploymentID string, project *Project, sshConfig *SSHConfig) error {
-	// Get SSH auth method
-	auth, err := sshConfig.GetAuthMethod()
+	client, release, err := e.connect(sshConfig)
 	if err != nil {
-		return fmt.Errorf("failed to get auth method: %w", err)
+		return err
 	}
+	defer release()
 
-	// Create SSH client
-	client, err := ssh.NewConn(&ssh.Config{
-		User:     sshConfig.User,
-		Addr:     sshConfig.Host,
-		Port:     uint(sshConfig.Port),
-		Auth:     auth,
-		Timeout:  30 * time.Second,
-		Callback: xssh.InsecureIgnoreHostKey(),
-	})
+	e.serverLog(deploymentID, sshConfig.Name, LogTypeLog, fmt.Sprintf("Connected to %s@%s:%d", sshConfig.User, sshConfig.Host, sshConfig.Port))
+
+	// Upload config templates, execute the deploy script and steps, then run the
+	// handlers of changed templates
+	handlers, err := e.runTemplates(ctx, deploymentID, project, sshConfig, client)
 	if err != nil {
-		return fmt.Errorf("failed to connect to SSH server: %w", err)
+		return err
+	}
+	if err := e.runScript(ctx, deploymentID, project, sshConfig, client, project.DeployScript); err != nil {
+		return err
+	}
+	if err := e.runSteps(ctx, deploymentID, project, sshConfig, client); err != nil {
+		return err
+	}
+	return e.runHandlers(ctx, deploymentID, project, sshConfig, client, handlers)
+}
+
+// runScript runs the lines of a deploy script on a server, streaming their output
+func (e *Engine) runScript(ctx context.Context, deploymentID string, project *Project, sshConfig *SSHConfig, client *ssh.Client, script string) error {
+	lines := strings.Split(script, "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Check for context cancellation
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		e.serverLog(deploymentID, sshConfig.Name, LogTypeLog, fmt.Sprintf("  $ %s", line))
+		if err := e.runLine(ctx, deploymentID, project, sshConfig, client, line); err != nil {
+			return err
+		}
 	}
-	defer client.Close()
+	return nil
+}
 
-	e.broadcastLog(deploymentID, LogTypeLog, fmt.Sprintf("Connected to %s@%s:%d", sshConfig.User, sshConfig.Host, sshConfig.Port))
+// runLine runs a single deploy script line in its own session, which is closed
+// afterwards so pooled connections do not run out of sessions
+func (e *Engine) runLine(ctx context.Context, deploymentID string, project *Project, sshConfig *SSHConfig, client *ssh.Client, line string) error {
+	// Execute command
+	name, args, password, err := remoteCommand(sshConfig, commandEnv(project), line)
+	if err != nil {
+		return err
+	}
 
-	// Execute deploy script
-	if project.DeployScript != "" {
-		lines := strings.Split(project.DeployScript, "\n")
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line == "" || strings.HasPrefix(line, "#") {
-				continue
-			}
+	// Only feed the sudo password if sudo asks for it, so it never ends up in
+	// the input of the privileged command
+	if password != "" && sudoWithoutPassword(ctx, client) {
+		args, password = withoutPassword(args), ""
+	}
+	cmd, err := client.CommandContext(ctx, name, args...)
+	if err != nil {
+		return fmt.Errorf("failed to create command: %w", err)
+	}
+	defer cmd.Close()
+
+	// Capture output, a terminal merges stderr into stdout
+	tty := project.TTY.matches(line)
+	if tty {
+		term, cols, rows := project.TTY.size()
+		if err := cmd.Pty(term, cols, rows); err != nil {
+			return fmt.Errorf("failed to allocate terminal: %w", err)
+		}
+	}
 
-			// Check for context cancellation
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			default:
-			}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stdout pipe: %w", err)
+	}
 
-			e.broadcastLog(deploymentID, LogTypeLog, fmt.Sprintf("  $ %s", line))
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to get stderr pipe: %w", err)
+	}
 
-			// Execute command
-			cmd, err := client.CommandContext(ctx, "bash", "-c", line)
-			if err != nil {
-				return fmt.Errorf("failed to create command: %w", err)
-			}
+	var stdin io.WriteCloser
+	if password != "" {
+		if stdin, err = cmd.StdinPipe(); err != nil {
+			return fmt.Errorf("failed to get stdin pipe: %w", err)
+		}
+	}
 
-			// Capture output
-			stdout, err := cmd.StdoutPipe()
-			if err != nil {
-				return fmt.Errorf("failed to get stdout pipe: %w", err)
-			}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start command: %w", err)
+	}
 
-			stderr, err := cmd.StderrPipe()
-			if err != nil {
-				return fmt.Errorf("failed to get stderr pipe: %w", err)
-			}
+	// Feed the sudo password
+	if stdin != nil {
+		io.WriteString(stdin, password+"\n")
+		stdin.Close()
+	}
 
-			if err := cmd.Start(); err != nil {
-				return fmt.Errorf("failed to start command: %w", err)
-			}
+	// Stream output until both streams are drained
+	stream := "stdout"
+	if tty {
+		stream = "tty"
+	}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		e.streamOutput(deploymentID, sshConfig.Name, stream, stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		e.streamOutput(deploymentID, sshConfig.Name, "stderr", stderr)
+	}()
+
+	err = cmd.Wait()
+	wg.Wait()
+	if err != nil {
+		return fmt.Errorf("command failed: %w", err)
+	}
+	return nil
+}
 
-			// Stream output
-			go e.streamOutput(deploymentID, stdout, LogTypeLog)
-			go e.streamOutput(deploymentID, stderr, LogTypeError)
+// MaxJumpHosts limits the length of a jump host chain
+const MaxJumpHosts = 8
 
-			if err := cmd.Wait(); err != nil {
-				return fmt.Errorf("command failed: %w", err)
-			}
+// ClientConfig returns the ssh client config of a server, with its chain of
+// jump hosts in dialing order. Every hop is verified with callback.
+func (sc *SSHConfig) ClientConfig(callback xssh.HostKeyCallback) (*ssh.Config, error) {
+	var jump []*ssh.Config
+	for hop := sc.Jump; hop != nil; hop = hop.Jump {
+		if len(jump) == MaxJumpHosts {
+			return nil, fmt.Errorf("more than %d jump hosts for %s", MaxJumpHosts, sc.Name)
 		}
+		auth, err := hop.GetAuthMethod()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get auth method of jump host %s: %w", hop.Name, err)
+		}
+		jump = append([]*ssh.Config{hop.config(auth, callback)}, jump...)
 	}
 
-	return nil
+	auth, err := sc.GetAuthMethod()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get auth method: %w", err)
+	}
+	config := sc.config(auth, callback)
+	config.Jump = jump
+	return config, nil
+}
+
+// config returns the ssh client config of a single hop
+func (sc *SSHConfig) config(auth ssh.Auth, callback xssh.HostKeyCallback) *ssh.Config {
+	port := sc.Port
+	if port == 0 {
+		port = ssh.DefaultPort
+	}
+	return &ssh.Config{
+		User:     sc.User,
+		Addr:     sc.Host,
+		Port:     uint(port),
+		Auth:     auth,
+		Timeout:  30 * time.Second,
+		Callback: callback,
+	}
+}
+
+// connect returns the pooled SSH connection to a server like Connect, with
+// errors worded for the deployment log
+func (e *Engine) connect(sshConfig *SSHConfig) (*ssh.Client, func(), error) {
+	client, release, err := e.Connect(sshConfig)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to SSH server: %w", err)
+	}
+	return client, release, nil
+}
+
+// Connect returns the pooled SSH connection to a server, dialing it if there
+// is none. The server and its jump hosts must present their pinned host keys.
+// release must be called once done with the client instead of closing it.
+func (e *Engine) Connect(sshConfig *SSHConfig) (*ssh.Client, func(), error) {
+	knownHosts := e.KnownHosts()
+	callback, err := ssh.HostKeyPins(knownHosts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load pinned host keys: %w", err)
+	}
+	config, err := sshConfig.ClientConfig(callback)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, release, err := e.pool.Get(poolKey(sshConfig, knownHosts), config)
+	if err != nil {
+		e.reportHealth(sshConfig.Name, false, err.Error())
+		return nil, nil, err
+	}
+	e.reportHealth(sshConfig.Name, true, "")
+	return client, release, nil
 }
 
-// streamOutput streams command output to WebSocket clients
-func (e *Engine) streamOutput(deploymentID string, reader io.Reader, logType LogType) {
+// connectionLost reports the server of a pooled connection that died
+func (e *Engine) connectionLost(key string) {
+	name := key[:strings.LastIndex(key, "/")]
+	e.reportHealth(name, false, "connection lost")
+}
+
+// poolKey identifies the pooled connection of a server by its name and a hash.
+// Any change to the server, its credentials, jump hosts or pinned keys file
+// dials a new one.
+func poolKey(sshConfig *SSHConfig, knownHosts string) string {
+	data, _ := json.Marshal(sshConfig)
+	sum := sha256.Sum256(append(data, knownHosts...))
+	return sshConfig.Name + "/" + hex.EncodeToString(sum[:])
+}
+
+// PoolStats reports the SSH connections pooled by the engine
+func (e *Engine) PoolStats() ssh.PoolStats {
+	return e.pool.Stats()
+}
+
+// commandEnv returns shell exports for the environment, version and variables of a project.
+// Variables are exported inline since most sshd configs reject session env vars.
+func commandEnv(project *Project) string {
+	vars := map[string]string{}
+	for name, value := range project.Variables {
+		vars[name] = value
+	}
+	if project.Environment != "" {
+		vars["ED_ENVIRONMENT"] = project.Environment
+	}
+	if project.Version != "" {
+		vars["ED_VERSION"] = project.Version
+	}
+
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "export %s=%s; ", name, shellQuote(vars[name]))
+	}
+	return b.String()
+}
+
+// shellQuote quotes s as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// streamOutput streams the "stdout", "stderr" or "tty" stream of a command on a
+// server to subscribers. Sudo password prompts are removed, otherwise terminal
+// output is passed on unmodified.
+func (e *Engine) streamOutput(deploymentID string, server string, stream string, reader io.Reader) {
+	logType := LogTypeLog
+	if stream == "stderr" {
+		logType = LogTypeError
+	}
+
+	// The start of a prompt at the end of a read is held back until the next
+	// one, so a prompt split across reads is removed too
+	var held string
+
 	buf := make([]byte, 1024)
 	for {
 		n, err := reader.Read(buf)
-		if n > 0 {
-			output := strings.TrimSpace(string(buf[:n]))
+		if n > 0 || (err != nil && held != "") {
+			output := strings.ReplaceAll(held+string(buf[:n]), sudoPrompt, "")
+			held = ""
+			if err == nil {
+				held = output[len(output)-partialPrompt(output):]
+				output = output[:len(output)-len(held)]
+			}
+			if stream != "tty" {
+				output = strings.TrimSpace(output)
+			}
 			if output != "" {
-				e.broadcastLog(deploymentID, logType, output)
+				e.publish(deploymentID, Event{Type: string(logType), Data: output, Server: server, Stream: stream})
 			}
 		}
 		if err != nil {
 			if err != io.EOF {
-				e.broadcastLog(deploymentID, LogTypeError, fmt.Sprintf("Error reading output: %v", err))
+				e.serverLog(deploymentID, server, LogTypeError, fmt.Sprintf("Error reading output: %v", err))
 			}
 			break
 		}
@@ -323,6 +752,7 @@ func (e *Engine) completeDeployment(deploymentID string) {
 	e.mu.Unlock()
 
 	e.broadcastLog(deploymentID, LogTypeStatus, "Deployment completed successfully")
+	e.emit(deploymentID, EventSucceeded)
 }
 
 // failDeployment marks a deployment as failed
@@ -332,11 +762,13 @@ func (e *Engine) failDeployment(deploymentID string, errorMsg string) {
 		now := time.Now()
 		status.Status = "failed"
 		status.CompletedAt = &now
+		status.Error = errorMsg
 	}
 	e.mu.Unlock()
 
 	e.broadcastLog(deploymentID, LogTypeError, errorMsg)
 	e.broadcastLog(deploymentID, LogTypeStatus, "Deployment failed")
+	e.emit(deploymentID, EventFailed)
 }
 
 // CancelDeployment cancels an ongoing deployment
@@ -359,5 +791,6 @@ func (e *Engine) CancelDeployment(deploymentID string) error {
 	e.mu.Unlock()
 
 	e.broadcastLog(deploymentID, LogTypeStatus, "Deployment cancelled by user")
+	e.emit(deploymentID, EventCancelled)
 	return nil
 }
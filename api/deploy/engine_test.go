@@ -2,10 +2,11 @@ package deploy
 
 import (
 	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
-
-	"github.com/gorilla/websocket"
 )
 
 func TestNewEngine(t *testing.T) {
@@ -16,8 +17,8 @@ func TestNewEngine(t *testing.T) {
 	if engine.deployments == nil {
 		t.Error("deployments map not initialized")
 	}
-	if engine.clients == nil {
-		t.Error("clients map not initialized")
+	if engine.subscribers == nil {
+		t.Error("subscribers map not initialized")
 	}
 }
 
@@ -52,35 +53,127 @@ func TestGetStatus(t *testing.T) {
 	}
 }
 
-func TestRegisterAndUnregisterClient(t *testing.T) {
+func TestSubscribe(t *testing.T) {
+	engine := NewEngine()
+	deploymentID := "test-deployment-1"
+	engine.deployments[deploymentID] = &DeploymentStatus{ID: deploymentID, Status: "running"}
+
+	events1, cancel1 := engine.Subscribe(deploymentID)
+	events2, cancel2 := engine.Subscribe(deploymentID)
+	defer cancel2()
+
+	engine.broadcastLog(deploymentID, LogTypeLog, "hello")
+	engine.broadcastLog(deploymentID, LogTypeError, "oops")
+
+	for _, events := range []<-chan Event{events1, events2} {
+		first, second := <-events, <-events
+		if first.Data != "hello" || first.Type != "log" || first.Seq != 1 {
+			t.Errorf("Unexpected first event %+v", first)
+		}
+		if second.Data != "oops" || second.Type != "error" || second.Seq != 2 {
+			t.Errorf("Unexpected second event %+v", second)
+		}
+	}
+
+	// Cancelling closes the channel and removes the subscriber
+	cancel1()
+	if _, ok := <-events1; ok {
+		t.Error("Expected channel to be closed after cancel")
+	}
+	if len(engine.subscribers[deploymentID]) != 1 {
+		t.Errorf("Expected 1 subscriber, got %d", len(engine.subscribers[deploymentID]))
+	}
+	cancel1()
+
+	// Finishing the deployment ends the stream
+	engine.completeDeployment(deploymentID)
+	var last Event
+	for event := range events2 {
+		last = event
+	}
+	if last.Data != "Deployment completed successfully" {
+		t.Errorf("Expected completion as last event, got %+v", last)
+	}
+	if _, exists := engine.subscribers[deploymentID]; exists {
+		t.Error("Expected subscribers to be removed when the deployment finishes")
+	}
+
+	// Subscribing to a finished deployment returns a closed channel
+	events3, cancel3 := engine.Subscribe(deploymentID)
+	defer cancel3()
+	if _, ok := <-events3; ok {
+		t.Error("Expected closed channel for a finished deployment")
+	}
+}
+
+func TestSubscribeAfter(t *testing.T) {
+	limit := HistoryLimit
+	HistoryLimit = 3
+	defer func() { HistoryLimit = limit }()
+
 	engine := NewEngine()
 	deploymentID := "test-deployment-1"
+	engine.deployments[deploymentID] = &DeploymentStatus{ID: deploymentID, Status: "running"}
+
+	for _, line := range []string{"one", "two", "three", "four"} {
+		engine.broadcastLog(deploymentID, LogTypeLog, line)
+	}
 
-	// Create mock WebSocket connection (nil is acceptable for this test)
-	var conn1, conn2 *websocket.Conn
+	// Only the last HistoryLimit events are kept
+	backlog, events, cancel := engine.SubscribeAfter(deploymentID, 0)
+	defer cancel()
+	if len(backlog) != 3 || backlog[0].Data != "two" {
+		t.Errorf("Expected the last 3 events, got %+v", backlog)
+	}
 
-	// Register first client
-	engine.RegisterClient(deploymentID, conn1)
-	if len(engine.clients[deploymentID]) != 1 {
-		t.Errorf("Expected 1 client, got %d", len(engine.clients[deploymentID]))
+	backlog, _, cancel2 := engine.SubscribeAfter(deploymentID, 3)
+	cancel2()
+	if len(backlog) != 1 || backlog[0].Seq != 4 {
+		t.Errorf("Expected only event 4, got %+v", backlog)
 	}
 
-	// Register second client
-	engine.RegisterClient(deploymentID, conn2)
-	if len(engine.clients[deploymentID]) != 2 {
-		t.Errorf("Expected 2 clients, got %d", len(engine.clients[deploymentID]))
+	// Live events follow the backlog without gaps
+	engine.broadcastLog(deploymentID, LogTypeLog, "five")
+	if event := <-events; event.Seq != 5 {
+		t.Errorf("Expected event 5, got %+v", event)
 	}
 
-	// Unregister first client
-	engine.UnregisterClient(deploymentID, conn1)
-	if len(engine.clients[deploymentID]) != 1 {
-		t.Errorf("Expected 1 client after unregister, got %d", len(engine.clients[deploymentID]))
+	// Finished deployments still replay their history
+	engine.completeDeployment(deploymentID)
+	backlog, events, _ = engine.SubscribeAfter(deploymentID, 0)
+	if len(backlog) != 3 || backlog[2].Data != "Deployment completed successfully" {
+		t.Errorf("Expected history of the finished deployment, got %+v", backlog)
+	}
+	if _, ok := <-events; ok {
+		t.Error("Expected closed channel for a finished deployment")
+	}
+}
+
+func TestSubscribeSlowConsumer(t *testing.T) {
+	buffer := SubscriberBuffer
+	SubscriberBuffer = 2
+	defer func() { SubscriberBuffer = buffer }()
+
+	engine := NewEngine()
+	deploymentID := "test-deployment-1"
+
+	events, cancel := engine.Subscribe(deploymentID)
+	defer cancel()
+
+	for i := 0; i < 3; i++ {
+		engine.broadcastLog(deploymentID, LogTypeLog, "line")
 	}
 
-	// Unregister second client
-	engine.UnregisterClient(deploymentID, conn2)
-	if _, exists := engine.clients[deploymentID]; exists {
-		t.Error("Expected clients map entry to be deleted when no clients remain")
+	// The two buffered events are delivered, then the channel is closed
+	count := 0
+	for range events {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 buffered events, got %d", count)
+	}
+	if _, exists := engine.subscribers[deploymentID]; exists {
+		t.Error("Expected slow subscriber to be disconnected")
 	}
 }
 
@@ -233,6 +326,39 @@ func TestGetAuthMethod(t *testing.T) {
 			},
 			expectErr: false,
 		},
+		{
+			name: "keyboard-interactive auth",
+			config: SSHConfig{
+				AuthType: "keyboard-interactive",
+				Password: "test123",
+			},
+			expectErr: false,
+		},
+		{
+			name: "inline key auth",
+			config: SSHConfig{
+				AuthType:   "inline-key",
+				PrivateKey: testClientKey,
+			},
+			expectErr: false,
+		},
+		{
+			name: "multiple auth methods",
+			config: SSHConfig{
+				AuthMethods: []string{"inline-key", "password"},
+				PrivateKey:  testClientKey,
+				Password:    "test123",
+			},
+			expectErr: false,
+		},
+		{
+			name: "invalid inline key",
+			config: SSHConfig{
+				AuthType:   "inline-key",
+				PrivateKey: "not a key",
+			},
+			expectErr: true,
+		},
 		{
 			name: "unknown auth type",
 			config: SSHConfig{
@@ -240,6 +366,13 @@ func TestGetAuthMethod(t *testing.T) {
 			},
 			expectErr: true,
 		},
+		{
+			name: "unknown auth method",
+			config: SSHConfig{
+				AuthMethods: []string{"password", "unknown"},
+			},
+			expectErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -261,6 +394,74 @@ func TestGetAuthMethod(t *testing.T) {
 	}
 }
 
+func TestValidateAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		config SSHConfig
+		valid  bool
+	}{
+		{"no auth type yet", SSHConfig{}, true},
+		{"password", SSHConfig{AuthType: "password"}, true},
+		{"key file", SSHConfig{AuthType: "key", KeyFile: "/keys/id_ed25519"}, true},
+		{"key without file", SSHConfig{AuthType: "key"}, false},
+		{"inline key", SSHConfig{AuthType: "inline-key", PrivateKey: testClientKey}, true},
+		{"inline key without key", SSHConfig{AuthType: "inline-key"}, false},
+		{"inline key with wrong passphrase", SSHConfig{AuthType: "inline-key", PrivateKey: testClientKey, KeyPass: "wrong"}, false},
+		{"key then password", SSHConfig{AuthMethods: []string{"inline-key", "password"}, PrivateKey: testClientKey}, true},
+		{"duplicate method", SSHConfig{AuthMethods: []string{"password", "password"}}, false},
+		{"unknown type", SSHConfig{AuthType: "kerberos"}, false},
+		{"unknown method", SSHConfig{AuthMethods: []string{"agent", "kerberos"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.ValidateAuth()
+			if tt.valid && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestConnectAuthMethods(t *testing.T) {
+	server := newTestSSHD(t, "web-1", t.TempDir())
+	otherKey, _, err := newTestClientKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config SSHConfig
+	}{
+		{"inline key", SSHConfig{AuthType: "inline-key", PrivateKey: testClientKey}},
+		{"keyboard-interactive", SSHConfig{AuthType: "keyboard-interactive", Password: testSSHPassword}},
+		{"rejected key then password", SSHConfig{AuthMethods: []string{"inline-key", "password"}, PrivateKey: otherKey, Password: testSSHPassword}},
+		{"key before wrong password", SSHConfig{AuthMethods: []string{"inline-key", "password"}, PrivateKey: testClientKey, Password: "wrong"}},
+	}
+
+	engine := NewEngine()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sshConfig := tt.config
+			sshConfig.Name, sshConfig.Host, sshConfig.Port, sshConfig.User = server.Name, server.Host, server.Port, server.User
+
+			client, release, err := engine.connect(&sshConfig)
+			if err != nil {
+				t.Fatalf("Failed to connect: %v", err)
+			}
+			defer release()
+
+			if output, err := client.Run("echo ok"); err != nil || string(output) != "ok\n" {
+				t.Errorf("Expected the command to run, got %q: %v", output, err)
+			}
+		})
+	}
+}
+
 func TestDeploymentStatusTracking(t *testing.T) {
 	engine := NewEngine()
 	deploymentID := "test-deployment-1"
@@ -305,3 +506,74 @@ func TestDeploymentStatusTracking(t *testing.T) {
 		t.Errorf("Expected status 'success', got %s", status.Status)
 	}
 }
+
+func TestCommandEnv(t *testing.T) {
+	project := &Project{
+		Name:        "test-project",
+		Environment: "staging",
+		Version:     "v1.2.0",
+		Variables: map[string]string{
+			"LOG_LEVEL": "debug",
+			"GREETING":  "it's me",
+		},
+	}
+
+	expected := `export ED_ENVIRONMENT='staging'; export ED_VERSION='v1.2.0'; export GREETING='it'\''s me'; export LOG_LEVEL='debug'; `
+	if env := commandEnv(project); env != expected {
+		t.Errorf("Expected %q, got %q", expected, env)
+	}
+
+	if env := commandEnv(&Project{Name: "plain"}); env != "" {
+		t.Errorf("Expected no exports for a plain project, got %q", env)
+	}
+}
+
+func TestClientConfigJumpHosts(t *testing.T) {
+	bastion := &SSHConfig{Name: "bastion", Host: "bastion.example.com", User: "jump", AuthType: "password"}
+	inner := &SSHConfig{Name: "inner", Host: "10.0.0.1", Port: 2222, User: "jump", AuthType: "password", Jump: bastion}
+	server := &SSHConfig{Name: "web-1", Host: "10.0.1.1", Port: 22, User: "deploy", AuthType: "password", Jump: inner}
+
+	config, err := server.ClientConfig(nil)
+	if err != nil {
+		t.Fatalf("ClientConfig failed: %v", err)
+	}
+	if config.Addr != "10.0.1.1" || config.Port != 22 {
+		t.Errorf("Unexpected target %s:%d", config.Addr, config.Port)
+	}
+	if len(config.Jump) != 2 || config.Jump[0].Addr != "bastion.example.com" || config.Jump[0].Port != 22 || config.Jump[1].Port != 2222 {
+		t.Errorf("Expected jump hosts in dialing order, got %+v", config.Jump)
+	}
+
+	bastion.Jump = server
+	if _, err := server.ClientConfig(nil); err == nil {
+		t.Error("Expected an error for a jump host cycle")
+	}
+}
+
+func TestDeployThroughJumpHosts(t *testing.T) {
+	bastionDir, innerDir, serverDir := t.TempDir(), t.TempDir(), t.TempDir()
+	bastion := newTestSSHD(t, "bastion", bastionDir)
+	inner := newTestSSHD(t, "inner", innerDir)
+	inner.Jump = bastion
+	server := newTestSSHD(t, "web-1", serverDir)
+	server.Jump = inner
+
+	engine := NewEngine()
+	project := &Project{
+		Name:          "test-project",
+		DeployScript:  "echo deployed > result",
+		DeployServers: []string{"web-1"},
+	}
+	engine.Enqueue("deploy-1", project, map[string]*SSHConfig{"web-1": server}, nil)
+	waitForDeploymentStatus(t, engine, "deploy-1", "success")
+
+	if data, _ := os.ReadFile(filepath.Join(serverDir, "result")); string(data) != "deployed\n" {
+		t.Errorf("Expected the script to run on the target, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(bastionDir, "tunnels")); string(data) != fmt.Sprintf("127.0.0.1:%d\n", inner.Port) {
+		t.Errorf("Expected the bastion to tunnel to the inner jump host, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(innerDir, "tunnels")); string(data) != fmt.Sprintf("127.0.0.1:%d\n", server.Port) {
+		t.Errorf("Expected the inner jump host to tunnel to the target, got %q", data)
+	}
+}
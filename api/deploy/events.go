@@ -0,0 +1,233 @@
+package deploy
+
+import (
+	"log"
+	"math"
+	"time"
+)
+
+// SubscriberBuffer is the number of events buffered for each subscriber.
+// A subscriber that falls a full buffer behind is disconnected.
+var SubscriberBuffer = 256
+
+// HistoryLimit is the number of recent events kept per deployment for replay
+var HistoryLimit = 1000
+
+// Deployment lifecycle events passed to hooks
+const (
+	EventQueued    = "queued"
+	EventStarted   = "started"
+	EventSucceeded = "succeeded"
+	EventFailed    = "failed"
+	EventCancelled = "cancelled"
+)
+
+// Hook is called with a snapshot of a deployment whenever it reaches a lifecycle event.
+// Hooks run synchronously on the goroutine reaching the event and must not block.
+type Hook func(event string, status DeploymentStatus)
+
+// HealthHook is called whenever connecting to a server succeeds or fails, and
+// when a pooled connection to it dies. Hooks must not block.
+type HealthHook func(server string, healthy bool, message string)
+
+// Event is a log or status message published while a deployment runs
+type Event struct {
+	Seq          uint64    `json:"seq"` // increases by one per event of a deployment
+	DeploymentID string    `json:"deploymentId"`
+	Type         string    `json:"type"` // "log", "status", "error"
+	Data         string    `json:"data"`
+	Server       string    `json:"server,omitempty"` // server the output came from
+	Stream       string    `json:"stream,omitempty"` // "stdout" or "stderr" for command output
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// NotificationResult records the delivery of a notification for a deployment
+type NotificationResult struct {
+	Channel  string    `json:"channel"`
+	Event    string    `json:"event"`
+	Success  bool      `json:"success"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+	SentAt   time.Time `json:"sentAt"`
+}
+
+// AddHook registers a hook for deployment lifecycle events
+func (e *Engine) AddHook(hook Hook) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.hooks = append(e.hooks, hook)
+}
+
+// AddHealthHook registers a hook for server health reports
+func (e *Engine) AddHealthHook(hook HealthHook) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.healthHooks = append(e.healthHooks, hook)
+}
+
+// reportHealth calls the health hooks with the health of a server
+func (e *Engine) reportHealth(server string, healthy bool, message string) {
+	e.mu.RLock()
+	hooks := e.healthHooks
+	e.mu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(server, healthy, message)
+	}
+}
+
+// RecordNotification appends a notification delivery result to a deployment
+func (e *Engine) RecordNotification(deploymentID string, result NotificationResult) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if status, exists := e.deployments[deploymentID]; exists {
+		status.Notifications = append(status.Notifications, result)
+	}
+}
+
+// Subscribe returns a channel receiving the events of a deployment, and a function
+// that cancels the subscription. The channel is closed when the deployment finishes,
+// when the subscription is cancelled, or when the subscriber falls SubscriberBuffer
+// events behind. Subscribing to a finished deployment returns a closed channel.
+func (e *Engine) Subscribe(deploymentID string) (<-chan Event, func()) {
+	_, events, cancel := e.SubscribeAfter(deploymentID, math.MaxUint64)
+	return events, cancel
+}
+
+// SubscribeAfter is like Subscribe, but also returns the kept events with a
+// sequence number after seq, so a client can resume a stream without gaps
+func (e *Engine) SubscribeAfter(deploymentID string, seq uint64) ([]Event, <-chan Event, func()) {
+	ch := make(chan Event, SubscriberBuffer)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	history, kept := e.history[deploymentID]
+	status, exists := e.deployments[deploymentID]
+	if exists && isFinished(status.Status) && !kept && e.logs != nil {
+		// The events of a finished deployment are only kept in its stored log
+		var err error
+		if history, err = e.logs.read(status.ProjectName, deploymentID); err != nil {
+			log.Printf("[LOGS] Failed to read log of deployment %s: %v", deploymentID, err)
+		}
+	}
+
+	var backlog []Event
+	for _, event := range history {
+		if event.Seq > seq {
+			backlog = append(backlog, event)
+		}
+	}
+
+	if exists && isFinished(status.Status) {
+		close(ch)
+		return backlog, ch, func() {}
+	}
+	e.subscribers[deploymentID] = append(e.subscribers[deploymentID], ch)
+
+	cancel := func() {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+
+		subs := e.subscribers[deploymentID]
+		for i, sub := range subs {
+			if sub == ch {
+				close(ch)
+				e.unsubscribe(deploymentID, i)
+				return
+			}
+		}
+	}
+	return backlog, ch, cancel
+}
+
+// unsubscribe removes the i-th subscriber of a deployment. The caller must hold e.mu.
+func (e *Engine) unsubscribe(deploymentID string, i int) {
+	subs := e.subscribers[deploymentID]
+	e.subscribers[deploymentID] = append(subs[:i], subs[i+1:]...)
+	if len(e.subscribers[deploymentID]) == 0 {
+		delete(e.subscribers, deploymentID)
+	}
+}
+
+// publish sends an event to the subscribers of a deployment without blocking,
+// disconnecting subscribers whose buffer is full. Log output past the log limit
+// is dropped, and published events are written to the log store.
+func (e *Engine) publish(deploymentID string, event Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.limitLog(deploymentID, &event) {
+		return
+	}
+
+	e.seq[deploymentID]++
+	event.Seq = e.seq[deploymentID]
+	event.DeploymentID = deploymentID
+	event.Timestamp = time.Now()
+
+	history := append(e.history[deploymentID], event)
+	if len(history) > HistoryLimit {
+		history = history[len(history)-HistoryLimit:]
+	}
+	e.history[deploymentID] = history
+	e.persist(event)
+
+	subs := e.subscribers[deploymentID]
+	for i := len(subs) - 1; i >= 0; i-- {
+		select {
+		case subs[i] <- event:
+		default:
+			close(subs[i])
+			e.unsubscribe(deploymentID, i)
+		}
+	}
+}
+
+// endStream ends the event stream and the stored log of a finished deployment
+func (e *Engine) endStream(deploymentID string) {
+	e.mu.Lock()
+	for _, ch := range e.subscribers[deploymentID] {
+		close(ch)
+	}
+	delete(e.subscribers, deploymentID)
+	e.mu.Unlock()
+
+	e.finishLog(deploymentID)
+}
+
+// isFinished reports whether a deployment status is final
+func isFinished(status string) bool {
+	switch status {
+	case "success", "failed", "aborted", "removed", "rejected", "expired":
+		return true
+	default:
+		return false
+	}
+}
+
+// emit calls all hooks with a snapshot of the deployment. The succeeded, failed
+// and cancelled events also end the event stream of the deployment.
+func (e *Engine) emit(deploymentID string, event string) {
+	e.mu.RLock()
+	status, exists := e.deployments[deploymentID]
+	if !exists {
+		e.mu.RUnlock()
+		return
+	}
+	snapshot := status.snapshot()
+	snapshot.Notifications = nil
+	hooks := e.hooks
+	e.mu.RUnlock()
+
+	for _, hook := range hooks {
+		hook(event, snapshot)
+	}
+
+	if event != EventQueued && event != EventStarted {
+		e.endStream(deploymentID)
+	}
+}
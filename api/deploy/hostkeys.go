@@ -0,0 +1,21 @@
+package deploy
+
+// DefaultKnownHostsFile is the known_hosts file the host keys of servers are
+// pinned in. Deployments refuse servers whose key is not pinned.
+var DefaultKnownHostsFile = "known_hosts"
+
+// SetKnownHosts sets the known_hosts file host keys are pinned in
+func (e *Engine) SetKnownHosts(file string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.knownHosts = file
+}
+
+// KnownHosts returns the known_hosts file host keys are pinned in
+func (e *Engine) KnownHosts() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.knownHosts
+}
@@ -0,0 +1,120 @@
+package deploy
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/ed/ssh"
+	xssh "golang.org/x/crypto/ssh"
+)
+
+func TestDeployVerifiesHostKeys(t *testing.T) {
+	sshConfig := newTestSSHD(t, "web-1", t.TempDir())
+	address := fmt.Sprintf("%s:%d", sshConfig.Host, sshConfig.Port)
+
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := xssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mismatched := filepath.Join(t.TempDir(), "known_hosts")
+	if err := ssh.PinHostKey(address, otherKey, mismatched); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		knownHosts string
+		status     string
+		message    string
+	}{
+		{"pinned", DefaultKnownHostsFile, "success", ""},
+		{"unknown", filepath.Join(t.TempDir(), "known_hosts"), "failed", "unknown host key for " + address},
+		{"mismatch", mismatched, "failed", "host key mismatch for " + address},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine()
+			engine.SetKnownHosts(tt.knownHosts)
+
+			deploymentID := fmt.Sprintf("deploy-%d", i)
+			events, cancel := engine.Subscribe(deploymentID)
+			defer cancel()
+
+			project := &Project{Name: "test-project", DeployScript: "true", DeployServers: []string{"web-1"}}
+			engine.Enqueue(deploymentID, project, map[string]*SSHConfig{"web-1": sshConfig}, nil)
+			waitForDeploymentStatus(t, engine, deploymentID, tt.status)
+
+			var output strings.Builder
+			for event := range events {
+				output.WriteString(event.Data + "\n")
+			}
+			if !strings.Contains(output.String(), tt.message) {
+				t.Errorf("Expected %q in the log, got %s", tt.message, output.String())
+			}
+		})
+	}
+}
+
+func TestPinHostKey(t *testing.T) {
+	newKey := func() xssh.PublicKey {
+		public, _, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := xssh.NewPublicKey(public)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	oldKey, newerKey, otherKey := newKey(), newKey(), newKey()
+
+	file := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(file, []byte("# pinned by ed\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, pin := range []struct {
+		address string
+		key     xssh.PublicKey
+	}{
+		{"10.0.0.1:22", oldKey},
+		{"10.0.0.2:2222", otherKey},
+		{"10.0.0.1:22", newerKey},
+		{"10.0.0.1:22", newerKey},
+	} {
+		if err := ssh.PinHostKey(pin.address, pin.key, file); err != nil {
+			t.Fatalf("PinHostKey failed: %v", err)
+		}
+	}
+
+	// The newer key replaces the old one, other hosts and comments are kept
+	data, _ := os.ReadFile(file)
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 3 || lines[0] != "# pinned by ed" {
+		t.Errorf("Expected a comment and one key per host, got %s", data)
+	}
+
+	callback, err := ssh.HostKeyPins(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := callback("10.0.0.1:22", &net.TCPAddr{}, newerKey); err != nil {
+		t.Errorf("Expected the newer key to be pinned: %v", err)
+	}
+	if err := callback("10.0.0.1:22", &net.TCPAddr{}, oldKey); err == nil {
+		t.Error("Expected the old key to be rejected")
+	}
+	if err := callback("10.0.0.2:2222", &net.TCPAddr{}, otherKey); err != nil {
+		t.Errorf("Expected the other host to stay pinned: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package deploy
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/diiyw/ed/ssh"
+)
+
+// InstallKey authorizes the public key of keyFile, read from keyFile.pub, on
+// a server. It logs in with the current auth of the server, then checks that
+// the key alone logs in. Every hop must present its pinned host key.
+func InstallKey(sshConfig *SSHConfig, knownHosts string, keyFile string) error {
+	authorizedKey, err := os.ReadFile(keyFile + ".pub")
+	if err != nil {
+		return fmt.Errorf("failed to read public key: %w", err)
+	}
+	callback, err := ssh.HostKeyPins(knownHosts)
+	if err != nil {
+		return fmt.Errorf("failed to load pinned host keys: %w", err)
+	}
+
+	config, err := sshConfig.ClientConfig(callback)
+	if err != nil {
+		return err
+	}
+	client, err := ssh.NewConn(config)
+	if err != nil {
+		return fmt.Errorf("failed to connect to SSH server: %w", err)
+	}
+	err = client.AuthorizeKey(authorizedKey)
+	client.Close()
+	if err != nil {
+		return err
+	}
+
+	// Log in again with the key only
+	keyConfig := *sshConfig
+	keyConfig.AuthType = AuthKey
+	keyConfig.KeyFile = keyFile
+	keyConfig.KeyPass = ""
+	keyConfig.AuthMethods = nil
+	if config, err = keyConfig.ClientConfig(callback); err != nil {
+		return err
+	}
+	if client, err = ssh.NewConn(config); err != nil {
+		return fmt.Errorf("key was installed but does not log in: %w", err)
+	}
+	defer client.Close()
+
+	if output, err := client.Run("true"); err != nil {
+		return fmt.Errorf("key was installed but does not log in: %w: %s", err, output)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/ed/ssh"
+	xssh "golang.org/x/crypto/ssh"
+)
+
+// writeTestKey generates a key pair at dir/name and dir/name.pub
+func writeTestKey(t *testing.T, dir string, name string) (string, string) {
+	t.Helper()
+
+	privateKey, authorizedKey, err := ssh.GenerateKey("ed@" + name)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	keyFile := filepath.Join(dir, name)
+	writeTestFile(t, keyFile, string(privateKey), 0600)
+	writeTestFile(t, keyFile+".pub", string(authorizedKey), 0644)
+	return keyFile, strings.TrimSpace(string(authorizedKey))
+}
+
+func TestInstallKey(t *testing.T) {
+	home := t.TempDir()
+	server := newTestSSHD(t, "web-1", home)
+
+	// An existing key without trailing newline is kept on its own line
+	_, other, err := newTestClientKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := strings.TrimSpace(string(xssh.MarshalAuthorizedKey(other))) + " laptop"
+	writeTestFile(t, filepath.Join(home, ".ssh", "authorized_keys"), existing, 0644)
+
+	keyFile, authorizedKey := writeTestKey(t, t.TempDir(), "web-1")
+	for i := 0; i < 2; i++ {
+		if err := InstallKey(server, DefaultKnownHostsFile, keyFile); err != nil {
+			t.Fatalf("InstallKey failed: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(home, ".ssh", "authorized_keys"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := existing + "\n" + authorizedKey + "\n"; string(data) != want {
+		t.Errorf("Expected the key to be appended once, got %q", data)
+	}
+
+	if info, _ := os.Stat(filepath.Join(home, ".ssh", "authorized_keys")); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected authorized_keys mode 0600, got %v", info.Mode().Perm())
+	}
+	if info, _ := os.Stat(filepath.Join(home, ".ssh")); info.Mode().Perm() != 0700 {
+		t.Errorf("Expected .ssh mode 0700, got %v", info.Mode().Perm())
+	}
+}
+
+func TestInstallKey_LoginFails(t *testing.T) {
+	home := t.TempDir()
+	server := newTestSSHD(t, "web-1", home)
+	server.Password = "wrong"
+
+	keyFile, _ := writeTestKey(t, t.TempDir(), "web-1")
+	if err := InstallKey(server, DefaultKnownHostsFile, keyFile); err == nil {
+		t.Fatal("Expected the install to fail with a wrong password")
+	}
+	if _, err := os.Stat(filepath.Join(home, ".ssh", "authorized_keys")); !os.IsNotExist(err) {
+		t.Error("Expected no key to be installed")
+	}
+}
@@ -0,0 +1,510 @@
+package deploy
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLogLimit is the number of log output bytes kept per deployment
+var DefaultLogLimit int64 = 10 << 20
+
+// Log file extensions of running and finished deployments
+const (
+	logExt           = ".log"
+	compressedLogExt = ".log.gz"
+)
+
+// LogRetention limits how long finished deployment logs are kept
+type LogRetention struct {
+	MaxAge        time.Duration // 0 keeps logs regardless of age
+	MaxPerProject int           // 0 keeps any number of logs per project
+}
+
+// LogUsage reports the storage used by deployment logs
+type LogUsage struct {
+	Files    int                        `json:"files"`
+	Bytes    int64                      `json:"bytes"`
+	Projects map[string]ProjectLogUsage `json:"projects,omitempty"`
+}
+
+// ProjectLogUsage reports the storage used by the logs of one project
+type ProjectLogUsage struct {
+	Files int   `json:"files"`
+	Bytes int64 `json:"bytes"`
+}
+
+// add records a log file in the usage report
+func (u *LogUsage) add(project string, size int64) {
+	if u.Projects == nil {
+		u.Projects = make(map[string]ProjectLogUsage)
+	}
+	p := u.Projects[project]
+	p.Files++
+	p.Bytes += size
+	u.Projects[project] = p
+	u.Files++
+	u.Bytes += size
+}
+
+// LogStore persists deployment events as one NDJSON file per deployment, grouped
+// in a directory per project. Logs of finished deployments are gzip compressed.
+type LogStore struct {
+	dir       string
+	retention LogRetention
+	open      map[string]*logFile
+	mu        sync.Mutex
+}
+
+// logFile is the log of a running deployment
+type logFile struct {
+	file *os.File
+	w    *bufio.Writer
+}
+
+// logEntry is a stored log file
+type logEntry struct {
+	project string
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// NewLogStore creates a log store in dir
+func NewLogStore(dir string, retention LogRetention) (*LogStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	return &LogStore{
+		dir:       dir,
+		retention: retention,
+		open:      make(map[string]*logFile),
+	}, nil
+}
+
+// path returns the log path of a deployment without extension
+func (s *LogStore) path(project string, deploymentID string) string {
+	return filepath.Join(s.dir, url.PathEscape(project), url.PathEscape(deploymentID))
+}
+
+// append writes an event to the log of a running deployment
+func (s *LogStore) append(project string, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, exists := s.open[event.DeploymentID]
+	if !exists {
+		path := s.path(project, event.DeploymentID) + logExt
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		f = &logFile{file: file, w: bufio.NewWriter(file)}
+		s.open[event.DeploymentID] = f
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = f.w.Write(append(data, '\n'))
+	return err
+}
+
+// finish closes and compresses the log of a finished deployment, then applies
+// the retention rules
+func (s *LogStore) finish(project string, deploymentID string) error {
+	s.mu.Lock()
+	f, exists := s.open[deploymentID]
+	delete(s.open, deploymentID)
+	s.mu.Unlock()
+
+	if !exists {
+		return nil
+	}
+
+	err := f.w.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := compressFile(s.path(project, deploymentID)); err != nil {
+		return err
+	}
+
+	_, err = s.Prune()
+	return err
+}
+
+// read returns the stored events of a deployment. Appends wait while the log
+// of a running deployment is read, so no partial line is seen.
+func (s *LogStore) read(project string, deploymentID string) ([]Event, error) {
+	path := s.path(project, deploymentID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if f, exists := s.open[deploymentID]; exists {
+		if err := f.w.Flush(); err != nil {
+			return nil, err
+		}
+	}
+
+	var reader io.Reader
+	file, err := os.Open(path + compressedLogExt)
+	if err == nil {
+		defer file.Close()
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		reader = zr
+	} else if os.IsNotExist(err) {
+		if file, err = os.Open(path + logExt); err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	} else {
+		return nil, err
+	}
+
+	var events []Event
+	decoder := json.NewDecoder(reader)
+	for {
+		var event Event
+		if err := decoder.Decode(&event); err == io.EOF {
+			return events, nil
+		} else if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+}
+
+// find returns the project of a stored deployment log
+func (s *LogStore) find(deploymentID string) (string, error) {
+	projects, err := os.ReadDir(s.dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range projects {
+		if !p.IsDir() {
+			continue
+		}
+		project, err := url.PathUnescape(p.Name())
+		if err != nil {
+			continue
+		}
+		path := s.path(project, deploymentID)
+		for _, ext := range []string{compressedLogExt, logExt} {
+			if _, err := os.Stat(path + ext); err == nil {
+				return project, nil
+			}
+		}
+	}
+	return "", os.ErrNotExist
+}
+
+// compressFile gzips path.log to path.log.gz and removes the original. The
+// compressed log is renamed into place once complete.
+func compressFile(path string) error {
+	src, err := os.Open(path + logExt)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + compressedLogExt + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path+compressedLogExt)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(path + logExt)
+}
+
+// entries lists the stored logs. Logs of running deployments are skipped
+// unless withOpen is set.
+func (s *LogStore) entries(withOpen bool) ([]logEntry, error) {
+	projects, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []logEntry
+	for _, p := range projects {
+		if !p.IsDir() {
+			continue
+		}
+		project, err := url.PathUnescape(p.Name())
+		if err != nil {
+			continue
+		}
+
+		files, err := os.ReadDir(filepath.Join(s.dir, p.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			if !withOpen && !strings.HasSuffix(f.Name(), compressedLogExt) {
+				continue
+			}
+			info, err := f.Info()
+			if err != nil {
+				continue
+			}
+			result = append(result, logEntry{
+				project: project,
+				path:    filepath.Join(s.dir, p.Name(), f.Name()),
+				size:    info.Size(),
+				modTime: info.ModTime(),
+			})
+		}
+	}
+	return result, nil
+}
+
+// Usage reports the storage used by all stored logs
+func (s *LogStore) Usage() (LogUsage, error) {
+	var usage LogUsage
+
+	entries, err := s.entries(true)
+	if err != nil {
+		return usage, err
+	}
+	for _, entry := range entries {
+		usage.add(entry.project, entry.size)
+	}
+	return usage, nil
+}
+
+// Prune removes finished logs older than the retention age, and the oldest logs
+// of projects over the retention count. It reports what was removed.
+func (s *LogStore) Prune() (LogUsage, error) {
+	var removed LogUsage
+
+	entries, err := s.entries(false)
+	if err != nil {
+		return removed, err
+	}
+
+	// Newest first, so the logs past MaxPerProject are the oldest
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.After(entries[j].modTime)
+	})
+
+	kept := make(map[string]int)
+	for _, entry := range entries {
+		expired := s.retention.MaxAge > 0 && time.Since(entry.modTime) > s.retention.MaxAge
+		excess := s.retention.MaxPerProject > 0 && kept[entry.project] >= s.retention.MaxPerProject
+		if !expired && !excess {
+			kept[entry.project]++
+			continue
+		}
+
+		if err := os.Remove(entry.path); err != nil {
+			return removed, err
+		}
+		removed.add(entry.project, entry.size)
+	}
+	return removed, nil
+}
+
+// SetLogStore persists the events of deployments to store
+func (e *Engine) SetLogStore(store *LogStore) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.logs = store
+}
+
+// LogStore returns the store deployment events are persisted to, or nil
+func (e *Engine) LogStore() *LogStore {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.logs
+}
+
+// Logs returns the events of a deployment from the log store, or from the
+// events kept in memory if the log is not stored. Deployments no longer known
+// to the engine, e.g. after a restart, are looked up in the store by ID.
+func (e *Engine) Logs(deploymentID string) ([]Event, error) {
+	e.mu.RLock()
+	store := e.logs
+	history := slices.Clone(e.history[deploymentID])
+	status, known := e.deployments[deploymentID]
+	var project string
+	if known {
+		project = status.ProjectName
+	}
+	e.mu.RUnlock()
+
+	if store == nil {
+		return history, nil
+	}
+
+	if !known {
+		var err error
+		if project, err = store.find(deploymentID); os.IsNotExist(err) {
+			return history, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	events, err := store.read(project, deploymentID)
+	if os.IsNotExist(err) {
+		return history, nil
+	}
+	return events, err
+}
+
+// SetLogLimit sets the number of log output bytes kept per deployment. Output
+// past the limit is replaced by a single truncation marker. 0 disables the limit.
+func (e *Engine) SetLogLimit(limit int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.logLimit = max(limit, 0)
+}
+
+// limitLog applies the log size limit to an event about to be published. Log
+// lines and command output on any stream count towards the limit, errors of
+// the engine itself are always kept. It returns false if the event is
+// dropped. The caller must hold e.mu.
+func (e *Engine) limitLog(deploymentID string, event *Event) bool {
+	if e.logLimit == 0 || (event.Type != string(LogTypeLog) && event.Stream == "") {
+		return true
+	}
+
+	used := e.logBytes[deploymentID]
+	if used >= e.logLimit {
+		return false
+	}
+
+	used += int64(len(event.Data))
+	if used <= e.logLimit {
+		e.logBytes[deploymentID] = used
+		return true
+	}
+
+	e.logBytes[deploymentID] = e.logLimit
+	*event = Event{
+		Type: string(LogTypeStatus),
+		Data: fmt.Sprintf("Log truncated: output exceeded %d bytes", e.logLimit),
+	}
+	return true
+}
+
+// persist writes a published event to the log store. The caller must hold e.mu.
+func (e *Engine) persist(event Event) {
+	if e.logs == nil {
+		return
+	}
+
+	var project string
+	if status, exists := e.deployments[event.DeploymentID]; exists {
+		project = status.ProjectName
+	}
+	if err := e.logs.append(project, event); err != nil {
+		log.Printf("[LOGS] Failed to write log of deployment %s: %v", event.DeploymentID, err)
+	}
+}
+
+// finishLog closes, compresses and prunes the stored log of a finished
+// deployment. Once stored, its events are no longer kept in memory and
+// finished deployments past the retention are forgotten.
+func (e *Engine) finishLog(deploymentID string) {
+	e.mu.Lock()
+	store := e.logs
+	var project string
+	if status, exists := e.deployments[deploymentID]; exists {
+		project = status.ProjectName
+	}
+	delete(e.logBytes, deploymentID)
+	e.mu.Unlock()
+
+	if store == nil {
+		return
+	}
+	if err := store.finish(project, deploymentID); err != nil {
+		log.Printf("[LOGS] Failed to finish log of deployment %s: %v", deploymentID, err)
+		return
+	}
+
+	e.mu.Lock()
+	delete(e.history, deploymentID)
+	e.pruneDeployments(store.retention)
+	e.mu.Unlock()
+}
+
+// pruneDeployments forgets the finished deployments past the log retention, so
+// the deployments kept in memory match the stored logs. The caller must hold e.mu.
+func (e *Engine) pruneDeployments(retention LogRetention) {
+	var finished []*DeploymentStatus
+	for _, status := range e.deployments {
+		if isFinished(status.Status) {
+			finished = append(finished, status)
+		}
+	}
+
+	// Newest first, so the deployments past MaxPerProject are the oldest
+	finishedAt := func(status *DeploymentStatus) time.Time {
+		if status.CompletedAt != nil {
+			return *status.CompletedAt
+		}
+		return status.StartedAt
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finishedAt(finished[i]).After(finishedAt(finished[j]))
+	})
+
+	kept := make(map[string]int)
+	for _, status := range finished {
+		expired := retention.MaxAge > 0 && time.Since(finishedAt(status)) > retention.MaxAge
+		excess := retention.MaxPerProject > 0 && kept[status.ProjectName] >= retention.MaxPerProject
+		if !expired && !excess {
+			kept[status.ProjectName]++
+			continue
+		}
+
+		delete(e.deployments, status.ID)
+		delete(e.history, status.ID)
+		delete(e.seq, status.ID)
+	}
+}
@@ -0,0 +1,243 @@
+package deploy
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogLimit(t *testing.T) {
+	engine := NewEngine()
+	engine.SetLogLimit(10)
+	deploymentID := "test-deployment-1"
+
+	events, cancel := engine.Subscribe(deploymentID)
+	defer cancel()
+
+	engine.broadcastLog(deploymentID, LogTypeLog, "12345")
+	engine.broadcastLog(deploymentID, LogTypeLog, "123456")
+	engine.broadcastLog(deploymentID, LogTypeLog, "dropped")
+	engine.broadcastLog(deploymentID, LogTypeError, "errors are kept")
+
+	expected := []Event{
+		{Type: "log", Data: "12345"},
+		{Type: "status", Data: "Log truncated: output exceeded 10 bytes"},
+		{Type: "error", Data: "errors are kept"},
+	}
+	for _, want := range expected {
+		got := <-events
+		if got.Type != want.Type || got.Data != want.Data {
+			t.Errorf("Expected %s event %q, got %+v", want.Type, want.Data, got)
+		}
+	}
+	select {
+	case event := <-events:
+		t.Errorf("Unexpected event %+v", event)
+	default:
+	}
+}
+
+func TestLogLimit_Stderr(t *testing.T) {
+	engine := NewEngine()
+	engine.SetLogLimit(4096)
+	deploymentID := "test-deployment-1"
+
+	events, cancel := engine.Subscribe(deploymentID)
+	defer cancel()
+
+	// A command flooding stderr is capped like stdout
+	flood := strings.Repeat("error: disk full\n", 10000)
+	engine.streamOutput(deploymentID, "web-1", "stderr", strings.NewReader(flood))
+	engine.broadcastLog(deploymentID, LogTypeError, "Deployment failed")
+
+	var size int
+	var truncated bool
+	for {
+		event := <-events
+		if event.Stream == "stderr" {
+			if truncated {
+				t.Fatal("Expected no output after the log was truncated")
+			}
+			size += len(event.Data)
+			continue
+		}
+		if event.Type == "status" && strings.HasPrefix(event.Data, "Log truncated") {
+			truncated = true
+			continue
+		}
+		if event.Data != "Deployment failed" {
+			t.Errorf("Unexpected event %+v", event)
+		}
+		break
+	}
+	if !truncated || size > 4096 {
+		t.Errorf("Expected stderr to be truncated at 4096 bytes, got %d bytes, truncated %v", size, truncated)
+	}
+}
+
+func TestLogStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLogStore(dir, LogRetention{})
+	if err != nil {
+		t.Fatalf("NewLogStore failed: %v", err)
+	}
+
+	engine := NewEngine()
+	engine.SetLogStore(store)
+	engine.Enqueue("deploy-1", &Project{Name: "my project", BuildInstructions: "step 1"}, nil, nil)
+	waitForDeploymentStatus(t, engine, "deploy-1", "success")
+
+	path := filepath.Join(dir, "my%20project", "deploy-1.log.gz")
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(path); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Expected compressed log: %v", err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("Failed to read compressed log: %v", err)
+	}
+
+	var last Event
+	scanner := bufio.NewScanner(zr)
+	for scanner.Scan() {
+		if err := json.Unmarshal(scanner.Bytes(), &last); err != nil {
+			t.Fatalf("Invalid log line %q: %v", scanner.Text(), err)
+		}
+	}
+	if last.Data != "Deployment completed successfully" {
+		t.Errorf("Expected completion as last logged event, got %+v", last)
+	}
+	if _, err := os.Stat(strings.TrimSuffix(path, ".gz")); !os.IsNotExist(err) {
+		t.Error("Expected uncompressed log to be removed")
+	}
+
+	logged, err := engine.Logs("deploy-1")
+	if err != nil || len(logged) == 0 || logged[len(logged)-1] != last {
+		t.Errorf("Expected engine logs to be read from the store, got %d events, %v", len(logged), err)
+	}
+
+	usage, err := store.Usage()
+	if err != nil {
+		t.Fatalf("Usage failed: %v", err)
+	}
+	if usage.Files != 1 || usage.Projects["my project"].Files != 1 || usage.Bytes == 0 {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+}
+
+func TestLogStorePrune(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLogStore(dir, LogRetention{MaxAge: 24 * time.Hour, MaxPerProject: 2})
+	if err != nil {
+		t.Fatalf("NewLogStore failed: %v", err)
+	}
+
+	write := func(project, name string, age time.Duration) {
+		path := filepath.Join(dir, project, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte("log"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := time.Now().Add(-age)
+		os.Chtimes(path, modTime, modTime)
+	}
+	write("a", "a-1.log.gz", 3*time.Hour)
+	write("a", "a-2.log.gz", 2*time.Hour)
+	write("a", "a-3.log.gz", time.Hour)
+	write("a", "a-4.log", 48*time.Hour) // still running
+	write("b", "b-1.log.gz", 48*time.Hour)
+
+	removed, err := store.Prune()
+	if err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if removed.Files != 2 || removed.Projects["a"].Files != 1 || removed.Projects["b"].Files != 1 {
+		t.Errorf("Unexpected removal %+v", removed)
+	}
+
+	for _, name := range []string{"a/a-2.log.gz", "a/a-3.log.gz", "a/a-4.log"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be kept", name)
+		}
+	}
+	for _, name := range []string{"a/a-1.log.gz", "b/b-1.log.gz"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", name)
+		}
+	}
+}
+
+func TestLogStoreReleasesMemory(t *testing.T) {
+	store, err := NewLogStore(t.TempDir(), LogRetention{MaxPerProject: 1})
+	if err != nil {
+		t.Fatalf("NewLogStore failed: %v", err)
+	}
+
+	engine := NewEngine()
+	engine.SetLogStore(store)
+
+	// waitUntil polls cond under the engine lock
+	waitUntil := func(description string, cond func() bool) {
+		t.Helper()
+		for i := 0; ; i++ {
+			engine.mu.RLock()
+			done := cond()
+			engine.mu.RUnlock()
+			if done {
+				return
+			}
+			if i == 100 {
+				t.Fatalf("Timed out waiting until %s", description)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	engine.Enqueue("deploy-1", &Project{Name: "project1", BuildInstructions: "step 1"}, nil, nil)
+	waitForDeploymentStatus(t, engine, "deploy-1", "success")
+	waitUntil("the stored events are dropped from memory", func() bool {
+		_, kept := engine.history["deploy-1"]
+		return !kept
+	})
+
+	// The events of a finished deployment are replayed from its stored log
+	backlog, _, _ := engine.SubscribeAfter("deploy-1", 0)
+	if len(backlog) == 0 || backlog[len(backlog)-1].Data != "Deployment completed successfully" {
+		t.Errorf("Expected the backlog to be read from the store, got %+v", backlog)
+	}
+
+	// Deployments past the retention are forgotten with their logs
+	engine.Enqueue("deploy-2", &Project{Name: "project1", BuildInstructions: "step 2"}, nil, nil)
+	waitForDeploymentStatus(t, engine, "deploy-2", "success")
+	waitUntil("deploy-1 is forgotten", func() bool {
+		_, exists := engine.deployments["deploy-1"]
+		return !exists
+	})
+	if _, exists := engine.seq["deploy-1"]; exists {
+		t.Error("Expected the sequence of deploy-1 to be forgotten")
+	}
+
+	// After a restart the log is found by deployment ID alone
+	restarted := NewEngine()
+	restarted.SetLogStore(store)
+	logged, err := restarted.Logs("deploy-2")
+	if err != nil || len(logged) == 0 || logged[len(logged)-1].Data != "Deployment completed successfully" {
+		t.Errorf("Expected the stored log of deploy-2, got %d events, %v", len(logged), err)
+	}
+	if logged, err := restarted.Logs("deploy-1"); err != nil || len(logged) != 0 {
+		t.Errorf("Expected no log of the pruned deploy-1, got %d events, %v", len(logged), err)
+	}
+}
@@ -0,0 +1,115 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diiyw/ed/ssh"
+)
+
+// waitForPool waits until the engine pools the given number of connections
+func waitForPool(t *testing.T, engine *Engine, open int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for engine.PoolStats().Open != open {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d pooled connections, got %+v", open, engine.PoolStats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDeploysShareConnection(t *testing.T) {
+	sshConfig := newTestSSHD(t, "web-1", t.TempDir())
+	engine := NewEngine()
+	project := &Project{
+		Name:          "test-project",
+		DeployScript:  "echo one\necho two\necho three",
+		DeployServers: []string{"web-1"},
+	}
+
+	deployOutput(t, engine, "deploy-1", project, sshConfig)
+	deployOutput(t, engine, "deploy-2", project, sshConfig)
+
+	if stats := engine.PoolStats(); stats.Dials != 1 || stats.Open != 1 || stats.InUse != 0 {
+		t.Errorf("Expected both deploys to share one idle connection, got %+v", stats)
+	}
+
+	// Another server config is another connection
+	other := *sshConfig
+	other.User = "other"
+	client, release, err := engine.connect(&other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := engine.PoolStats(); stats.Dials != 2 || stats.Open != 2 || stats.InUse != 1 {
+		t.Errorf("Expected a second connection in use, got %+v", stats)
+	}
+	release()
+
+	// A connection that died is redialed
+	client.Client.Close()
+	waitForPool(t, engine, 1)
+	deployOutput(t, engine, "deploy-3", project, &other)
+	if stats := engine.PoolStats(); stats.Dials != 3 {
+		t.Errorf("Expected the dead connection to be redialed, got %+v", stats)
+	}
+}
+
+func TestPoolClosesIdleConnections(t *testing.T) {
+	sshConfig := newTestSSHD(t, "web-1", t.TempDir())
+	engine := NewEngine()
+	engine.pool = ssh.NewPool(10*time.Millisecond, 50*time.Millisecond)
+
+	_, release, err := engine.connect(sshConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keepalives hold the connection open while it is in use
+	time.Sleep(150 * time.Millisecond)
+	if stats := engine.PoolStats(); stats.Open != 1 || stats.InUse != 1 {
+		t.Fatalf("Expected the connection in use to stay open, got %+v", stats)
+	}
+
+	release()
+	waitForPool(t, engine, 0)
+}
+
+func TestConnectReportsHealth(t *testing.T) {
+	sshConfig := newTestSSHD(t, "web-1", t.TempDir())
+	engine := NewEngine()
+
+	reports := make(chan string, 10)
+	engine.AddHealthHook(func(server string, healthy bool, message string) {
+		reports <- fmt.Sprintf("%s:%v:%s", server, healthy, message)
+	})
+
+	client, release, err := engine.connect(sshConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if got := <-reports; got != "web-1:true:" {
+		t.Errorf("Expected web-1 to be reported healthy, got %s", got)
+	}
+
+	// A pooled connection that dies reports its server
+	client.Client.Close()
+	if got := <-reports; got != "web-1:false:connection lost" {
+		t.Errorf("Expected web-1 to be reported lost, got %s", got)
+	}
+
+	unreachable := *sshConfig
+	unreachable.Name = "web-2"
+	unreachable.Port = 1
+	if _, _, err := engine.connect(&unreachable); err == nil {
+		t.Fatal("Expected connecting to a closed port to fail")
+	}
+	if got := <-reports; !strings.HasPrefix(got, "web-2:false:") {
+		t.Errorf("Expected web-2 to be reported unhealthy, got %s", got)
+	}
+}
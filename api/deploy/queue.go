@@ -0,0 +1,304 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// DefaultWorkers is the number of queued deployments an engine runs at once
+var DefaultWorkers = 4
+
+// Queue priorities, higher priorities run first. PriorityScheduled is for
+// triggers with the "scheduled" source; ed has no scheduler yet, one must start
+// its deploys like the handlers do so freeze windows apply to them.
+const (
+	PriorityWebhook   = 0
+	PriorityScheduled = 1
+	PriorityManual    = 2
+)
+
+// QueuedJob describes a deployment waiting in the queue
+type QueuedJob struct {
+	ID          string    `json:"id"`
+	ProjectName string    `json:"projectName"`
+	Environment string    `json:"environment,omitempty"`
+	Source      string    `json:"source"`
+	Priority    int       `json:"priority"`
+	Position    int       `json:"position"`
+	QueuedAt    time.Time `json:"queuedAt"`
+	Blocked     bool      `json:"blocked"` // waiting for another deployment of the same project
+}
+
+// job is a queued deployment
+type job struct {
+	id         string
+	project    *Project
+	sshConfigs map[string]*SSHConfig
+	trigger    *Trigger
+	priority   int
+	queuedAt   time.Time
+}
+
+// triggerPriority returns the queue priority of a trigger
+func triggerPriority(trigger *Trigger) int {
+	if trigger == nil {
+		return PriorityManual
+	}
+	switch trigger.Source {
+	case "webhook":
+		return PriorityWebhook
+	case "scheduled":
+		return PriorityScheduled
+	default:
+		return PriorityManual
+	}
+}
+
+// Enqueue registers a deployment and queues it to run once a worker is free
+func (e *Engine) Enqueue(deploymentID string, project *Project, sshConfigs map[string]*SSHConfig, trigger *Trigger) {
+	now := time.Now()
+
+	e.mu.Lock()
+	e.deployments[deploymentID] = &DeploymentStatus{
+		ID:          deploymentID,
+		ProjectName: project.Name,
+		Environment: project.Environment,
+		Version:     project.Version,
+		Status:      "queued",
+		StartedAt:   now,
+		Trigger:     trigger,
+	}
+	e.mu.Unlock()
+
+	e.schedule(deploymentID, project, sshConfigs, trigger)
+}
+
+// schedule adds a registered deployment to the queue and dispatches it if a worker is free
+func (e *Engine) schedule(deploymentID string, project *Project, sshConfigs map[string]*SSHConfig, trigger *Trigger) {
+	j := &job{
+		id:         deploymentID,
+		project:    project,
+		sshConfigs: sshConfigs,
+		trigger:    trigger,
+		priority:   triggerPriority(trigger),
+		queuedAt:   time.Now(),
+	}
+
+	e.mu.Lock()
+	// A job waiting behind a job of the same project effectively has the lowest
+	// priority of the two
+	effective := make([]int, len(e.queue))
+	lowest := make(map[string]int)
+	for i, queued := range e.queue {
+		p, seen := lowest[queued.project.Name]
+		if !seen || queued.priority < p {
+			p = queued.priority
+		}
+		lowest[queued.project.Name] = p
+		effective[i] = p
+	}
+
+	// Queue behind jobs of higher or equal priority, and never ahead of the same project
+	idx := len(e.queue)
+	for idx > 0 && effective[idx-1] < j.priority && e.queue[idx-1].project.Name != project.Name {
+		idx--
+	}
+	e.queue = slices.Insert(e.queue, idx, j)
+	e.mu.Unlock()
+
+	e.broadcastLog(deploymentID, LogTypeStatus, "Deployment queued")
+	e.emit(deploymentID, EventQueued)
+	e.dispatch()
+}
+
+// SetWorkers sets the number of queued deployments run at once
+func (e *Engine) SetWorkers(workers int) {
+	e.mu.Lock()
+	e.workers = max(workers, 1)
+	e.mu.Unlock()
+
+	e.dispatch()
+}
+
+// Workers returns the worker count and the number of busy workers
+func (e *Engine) Workers() (int, int) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.workers, e.running
+}
+
+// dispatch starts queued deployments while workers are free. A project runs one
+// queued deployment at a time, in queue order. Paused canaries free their worker
+// but keep their project busy.
+func (e *Engine) dispatch() {
+	var started []*job
+
+	e.mu.Lock()
+	for e.running < e.workers {
+		j := e.nextJob()
+		if j == nil {
+			break
+		}
+
+		e.running++
+		e.busy[j.project.Name] = true
+		e.working[j.id] = true
+		if status, exists := e.deployments[j.id]; exists {
+			status.Status = "running"
+			status.StartedAt = time.Now()
+		}
+		started = append(started, j)
+	}
+	e.mu.Unlock()
+
+	for _, j := range started {
+		go e.work(j)
+	}
+}
+
+// nextJob removes and returns the first job whose project is not busy and has no
+// earlier job in the queue. The caller must hold e.mu.
+func (e *Engine) nextJob() *job {
+	seen := make(map[string]bool)
+	for i, j := range e.queue {
+		if !e.busy[j.project.Name] && !seen[j.project.Name] {
+			e.queue = slices.Delete(e.queue, i, i+1)
+			return j
+		}
+		seen[j.project.Name] = true
+	}
+	return nil
+}
+
+// work runs a dequeued deployment and frees its worker afterwards
+func (e *Engine) work(j *job) {
+	_ = e.run(context.Background(), j.id, j.project, j.sshConfigs, j.trigger)
+
+	e.mu.Lock()
+	if e.working[j.id] {
+		e.running--
+	}
+	delete(e.working, j.id)
+	delete(e.busy, j.project.Name)
+	e.mu.Unlock()
+
+	e.dispatch()
+}
+
+// releaseWorker frees the worker of a queued deployment while it waits and
+// returns a function taking it back. The worker count may be exceeded until the
+// other deployments finish. Deployments run outside the queue hold no worker.
+func (e *Engine) releaseWorker(deploymentID string) func() {
+	e.mu.Lock()
+	held := e.working[deploymentID]
+	if held {
+		e.running--
+		e.working[deploymentID] = false
+	}
+	e.mu.Unlock()
+
+	if !held {
+		return func() {}
+	}
+	e.dispatch()
+
+	return func() {
+		e.mu.Lock()
+		e.running++
+		e.working[deploymentID] = true
+		e.mu.Unlock()
+	}
+}
+
+// Queue returns the queued deployments in the order they will be considered
+func (e *Engine) Queue() []QueuedJob {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	result := make([]QueuedJob, 0, len(e.queue))
+	for i, j := range e.queue {
+		source := "manual"
+		if j.trigger != nil {
+			source = j.trigger.Source
+		}
+		result = append(result, QueuedJob{
+			ID:          j.id,
+			ProjectName: j.project.Name,
+			Environment: j.project.Environment,
+			Source:      source,
+			Priority:    j.priority,
+			Position:    i,
+			QueuedAt:    j.queuedAt,
+			Blocked:     e.busy[j.project.Name] || seen[j.project.Name],
+		})
+		seen[j.project.Name] = true
+	}
+	return result
+}
+
+// MoveJob moves a queued deployment to a new position in the queue. A deployment
+// cannot be moved past another queued deployment of the same project.
+func (e *Engine) MoveJob(deploymentID string, position int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	from := e.jobIndex(deploymentID)
+	if from < 0 {
+		return e.notQueued(deploymentID)
+	}
+	to := min(max(position, 0), len(e.queue)-1)
+
+	j := e.queue[from]
+	lo, hi := min(from, to), max(from, to)
+	for _, other := range e.queue[lo : hi+1] {
+		if other != j && other.project.Name == j.project.Name {
+			return fmt.Errorf("cannot move a deployment past another deployment of the same project")
+		}
+	}
+
+	e.queue = slices.Delete(e.queue, from, from+1)
+	e.queue = slices.Insert(e.queue, to, j)
+	return nil
+}
+
+// RemoveJob removes a deployment from the queue so it never runs
+func (e *Engine) RemoveJob(deploymentID string) error {
+	e.mu.Lock()
+	idx := e.jobIndex(deploymentID)
+	if idx < 0 {
+		err := e.notQueued(deploymentID)
+		e.mu.Unlock()
+		return err
+	}
+	e.queue = slices.Delete(e.queue, idx, idx+1)
+
+	if status, exists := e.deployments[deploymentID]; exists {
+		now := time.Now()
+		status.Status = "removed"
+		status.CompletedAt = &now
+	}
+	e.mu.Unlock()
+
+	e.broadcastLog(deploymentID, LogTypeStatus, "Deployment removed from the queue")
+	e.emit(deploymentID, EventCancelled)
+	return nil
+}
+
+// jobIndex returns the queue position of a deployment, or -1. The caller must hold e.mu.
+func (e *Engine) jobIndex(deploymentID string) int {
+	return slices.IndexFunc(e.queue, func(j *job) bool {
+		return j.id == deploymentID
+	})
+}
+
+// notQueued returns the error for a deployment missing from the queue. The caller must hold e.mu.
+func (e *Engine) notQueued(deploymentID string) error {
+	if _, exists := e.deployments[deploymentID]; !exists {
+		return fmt.Errorf("deployment not found")
+	}
+	return fmt.Errorf("deployment is not queued")
+}
@@ -0,0 +1,147 @@
+package deploy
+
+import (
+	"reflect"
+	"testing"
+)
+
+// queuedIDs returns the IDs of the queued deployments in order
+func queuedIDs(engine *Engine) []string {
+	var ids []string
+	for _, j := range engine.Queue() {
+		ids = append(ids, j.ID)
+	}
+	return ids
+}
+
+func TestQueuePriority(t *testing.T) {
+	engine := NewEngine()
+	engine.workers = 0 // keep everything queued
+
+	engine.Enqueue("a-1", &Project{Name: "a"}, nil, &Trigger{Source: "webhook"})
+	engine.Enqueue("b-1", &Project{Name: "b"}, nil, &Trigger{Source: "webhook"})
+	engine.Enqueue("c-1", &Project{Name: "c"}, nil, &Trigger{Source: "scheduled"})
+	engine.Enqueue("a-2", &Project{Name: "a"}, nil, &Trigger{Source: "manual"})
+	engine.Enqueue("d-1", &Project{Name: "d"}, nil, nil)
+
+	// Manual before scheduled before webhook, but a-2 stays behind a-1
+	expected := []string{"d-1", "c-1", "a-1", "a-2", "b-1"}
+	if got := queuedIDs(engine); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected queue %v, got %v", expected, got)
+	}
+
+	queue := engine.Queue()
+	if queue[2].Blocked || !queue[3].Blocked {
+		t.Errorf("Expected only a-2 to be blocked, got %+v", queue)
+	}
+
+	status, _ := engine.GetStatus("a-1")
+	if status.Status != "queued" {
+		t.Errorf("Expected status 'queued', got %s", status.Status)
+	}
+}
+
+func TestMoveJob(t *testing.T) {
+	engine := NewEngine()
+	engine.workers = 0
+
+	engine.Enqueue("a-1", &Project{Name: "a"}, nil, nil)
+	engine.Enqueue("b-1", &Project{Name: "b"}, nil, nil)
+	engine.Enqueue("a-2", &Project{Name: "a"}, nil, nil)
+
+	if err := engine.MoveJob("b-1", 0); err != nil {
+		t.Fatalf("MoveJob failed: %v", err)
+	}
+	if got := queuedIDs(engine); !reflect.DeepEqual(got, []string{"b-1", "a-1", "a-2"}) {
+		t.Errorf("Unexpected queue order %v", got)
+	}
+
+	// Deployments of the same project keep their order
+	if err := engine.MoveJob("a-2", 0); err == nil {
+		t.Error("Expected error when moving a-2 ahead of a-1")
+	}
+	if err := engine.MoveJob("missing", 0); err == nil {
+		t.Error("Expected error when moving an unknown deployment")
+	}
+}
+
+func TestRemoveJob(t *testing.T) {
+	engine := NewEngine()
+	engine.workers = 0
+
+	engine.Enqueue("a-1", &Project{Name: "a"}, nil, nil)
+
+	if err := engine.RemoveJob("a-1"); err != nil {
+		t.Fatalf("RemoveJob failed: %v", err)
+	}
+	if len(engine.Queue()) != 0 {
+		t.Error("Expected queue to be empty")
+	}
+
+	status, _ := engine.GetStatus("a-1")
+	if status.Status != "removed" || status.CompletedAt == nil {
+		t.Errorf("Expected removed deployment, got %+v", status)
+	}
+
+	if err := engine.RemoveJob("a-1"); err == nil {
+		t.Error("Expected error when removing a deployment twice")
+	}
+}
+
+func TestQueueWorkerLimit(t *testing.T) {
+	engine := NewEngine()
+	engine.SetWorkers(2)
+
+	slow := func(name string) *Project {
+		return &Project{Name: name, BuildInstructions: "step 1\nstep 2\nstep 3"}
+	}
+	engine.Enqueue("a-1", slow("a"), nil, nil)
+	engine.Enqueue("a-2", slow("a"), nil, nil)
+	engine.Enqueue("b-1", slow("b"), nil, nil)
+	engine.Enqueue("c-1", slow("c"), nil, nil)
+
+	// a-1 and b-1 run, a-2 waits for a-1 and c-1 for a free worker
+	workers, busy := engine.Workers()
+	if workers != 2 || busy != 2 {
+		t.Errorf("Expected 2 of 2 workers busy, got %d of %d", busy, workers)
+	}
+	if got := queuedIDs(engine); !reflect.DeepEqual(got, []string{"a-2", "c-1"}) {
+		t.Errorf("Expected a-2 and c-1 to be queued, got %v", got)
+	}
+
+	for _, id := range []string{"a-1", "a-2", "b-1", "c-1"} {
+		waitForDeploymentStatus(t, engine, id, "success")
+	}
+	if _, busy := engine.Workers(); busy != 0 {
+		t.Errorf("Expected all workers to be free, got %d busy", busy)
+	}
+}
+
+func TestQueuePausedCanaryFreesWorker(t *testing.T) {
+	engine := NewEngine()
+	engine.SetWorkers(1)
+	sshConfigs, _ := newCanaryTestFleet(t, "web-1", "web-2", "web-3")
+
+	engine.Enqueue("canary-1", newCanaryTestProject(&Canary{Servers: []string{"web-1"}}), sshConfigs, nil)
+	waitForDeploymentStatus(t, engine, "canary-1", "canary")
+	if _, busy := engine.Workers(); busy != 0 {
+		t.Errorf("Expected the paused canary to free its worker, got %d busy", busy)
+	}
+
+	// Another project runs on the freed worker, the canary's project stays blocked
+	engine.Enqueue("b-1", &Project{Name: "b", BuildInstructions: "echo b"}, nil, nil)
+	engine.Enqueue("canary-2", newCanaryTestProject(nil), sshConfigs, nil)
+	waitForDeploymentStatus(t, engine, "b-1", "success")
+	if got := queuedIDs(engine); !reflect.DeepEqual(got, []string{"canary-2"}) {
+		t.Errorf("Expected canary-2 to wait for canary-1, got %v", got)
+	}
+
+	if err := engine.Promote("canary-1", "alice"); err != nil {
+		t.Fatalf("Promote failed: %v", err)
+	}
+	waitForDeploymentStatus(t, engine, "canary-1", "success")
+	waitForDeploymentStatus(t, engine, "canary-2", "success")
+	if _, busy := engine.Workers(); busy != 0 {
+		t.Errorf("Expected all workers to be free, got %d busy", busy)
+	}
+}
@@ -0,0 +1,350 @@
+package deploy
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	edssh "github.com/diiyw/ed/ssh"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// testSSHPassword is the password accepted by test SSH servers
+const testSSHPassword = "secret"
+
+// testClientKey is the PEM private key accepted by test SSH servers
+var testClientKey string
+
+// testClientPublicKey is the public key of testClientKey
+var testClientPublicKey ssh.PublicKey
+
+// testUserCA signs the user certificates accepted by test SSH servers
+var testUserCA ssh.Signer
+
+// newTestSigner returns a signer of a new ed25519 key
+func newTestSigner() (ssh.Signer, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewSignerFromKey(key)
+}
+
+// newTestCertificate signs a certificate of key with ca, valid from validAfter
+// until validBefore
+func newTestCertificate(t *testing.T, key ssh.PublicKey, ca ssh.Signer, certType uint32, principals []string, validAfter time.Time, validBefore time.Time) *ssh.Certificate {
+	t.Helper()
+
+	cert := &ssh.Certificate{
+		Key:             key,
+		KeyId:           "test",
+		CertType:        certType,
+		ValidPrincipals: principals,
+		ValidAfter:      uint64(validAfter.Unix()),
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatalf("Failed to sign certificate: %v", err)
+	}
+	return cert
+}
+
+// newTestClientKey returns a new PEM private key and its public key
+func newTestClientKey() (string, ssh.PublicKey, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", nil, err
+	}
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		return "", nil, err
+	}
+	publicKey, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(pem.EncodeToMemory(block)), publicKey, nil
+}
+
+// TestMain pins the host keys of test SSH servers in a temporary known_hosts
+// file, and generates the client key and user CA they accept
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "ed-deploy-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	DefaultKnownHostsFile = filepath.Join(dir, "known_hosts")
+
+	if testClientKey, testClientPublicKey, err = newTestClientKey(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if testUserCA, err = newTestSigner(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// newTestSSHD starts an SSH server on localhost that runs exec requests with
+// sh in dir, serves SFTP from dir and forwards TCP connections, and returns
+// the SSH config of a server connecting to it. It accepts testSSHPassword,
+// testClientKey, certificates of testUserCA and the keys in
+// .ssh/authorized_keys of dir, which is also the HOME of commands. Its host
+// key is pinned in DefaultKnownHostsFile.
+func newTestSSHD(t *testing.T, name string, dir string) *SSHConfig {
+	t.Helper()
+
+	signer, err := newTestSigner()
+	if err != nil {
+		t.Fatalf("Failed to create host key: %v", err)
+	}
+	sshConfig := startTestSSHD(t, name, dir, signer)
+
+	address := net.JoinHostPort(sshConfig.Host, fmt.Sprint(sshConfig.Port))
+	if err := edssh.PinHostKey(address, signer.PublicKey(), DefaultKnownHostsFile); err != nil {
+		t.Fatalf("Failed to pin host key: %v", err)
+	}
+	return sshConfig
+}
+
+// startTestSSHD starts the SSH server of newTestSSHD with a host key, without
+// pinning it
+func startTestSSHD(t *testing.T, name string, dir string, hostKey ssh.Signer) *SSHConfig {
+	t.Helper()
+
+	userCerts := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return bytes.Equal(auth.Marshal(), testUserCA.PublicKey().Marshal())
+		},
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if string(password) != testSSHPassword {
+				return nil, errors.New("wrong password")
+			}
+			return nil, nil
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if _, ok := key.(*ssh.Certificate); ok {
+				return userCerts.Authenticate(conn, key)
+			}
+			if !bytes.Equal(key.Marshal(), testClientPublicKey.Marshal()) && !testAuthorizedKey(dir, key) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+		KeyboardInteractiveCallback: func(conn ssh.ConnMetadata, challenge ssh.KeyboardInteractiveChallenge) (*ssh.Permissions, error) {
+			answers, err := challenge("", "", []string{"Password: "}, []bool{false})
+			if err != nil {
+				return nil, err
+			}
+			if len(answers) != 1 || answers[0] != testSSHPassword {
+				return nil, errors.New("wrong password")
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSHConn(conn, config, dir)
+		}
+	}()
+
+	return &SSHConfig{
+		Name:     name,
+		Host:     "127.0.0.1",
+		Port:     listener.Addr().(*net.TCPAddr).Port,
+		User:     "deploy",
+		AuthType: "password",
+		Password: testSSHPassword,
+	}
+}
+
+// testAuthorizedKey reports whether a key is listed in .ssh/authorized_keys of dir
+func testAuthorizedKey(dir string, key ssh.PublicKey) bool {
+	data, _ := os.ReadFile(filepath.Join(dir, ".ssh", "authorized_keys"))
+	for len(data) > 0 {
+		authorized, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return false
+		}
+		if bytes.Equal(authorized.Marshal(), key.Marshal()) {
+			return true
+		}
+		data = rest
+	}
+	return false
+}
+
+// serveTestSSHConn serves the session channels of a single SSH connection
+func serveTestSSHConn(conn net.Conn, config *ssh.ServerConfig, dir string) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() == "direct-tcpip" {
+			go serveTestTunnel(newChannel, dir)
+			continue
+		}
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go serveTestSSHSession(channel, requests, dir)
+	}
+}
+
+// serveTestTunnel forwards a direct-tcpip channel, as used by jump hosts, and
+// appends its destination to the tunnels file in dir
+func serveTestTunnel(newChannel ssh.NewChannel, dir string) {
+	var target struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid payload")
+		return
+	}
+
+	addr := net.JoinHostPort(target.Host, fmt.Sprint(target.Port))
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	if f, err := os.OpenFile(filepath.Join(dir, "tunnels"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err == nil {
+		fmt.Fprintln(f, addr)
+		f.Close()
+	}
+
+	go func() {
+		io.Copy(conn, channel)
+		conn.Close()
+	}()
+	io.Copy(channel, conn)
+	channel.Close()
+}
+
+// serveTestSSHSession runs the exec request of a session and reports its exit status,
+// or serves the sftp subsystem.
+// A pty-req is simulated by merging stderr into stdout and exporting TERM, COLUMNS and LINES.
+func serveTestSSHSession(channel ssh.Channel, requests <-chan *ssh.Request, dir string) {
+	defer channel.Close()
+
+	var ptyEnv []string
+	for req := range requests {
+		if req.Type == "pty-req" {
+			var pty struct {
+				Term          string
+				Cols, Rows    uint32
+				Width, Height uint32
+				Modes         string
+			}
+			if err := ssh.Unmarshal(req.Payload, &pty); err != nil {
+				req.Reply(false, nil)
+				continue
+			}
+			ptyEnv = []string{"TERM=" + pty.Term, fmt.Sprintf("COLUMNS=%d", pty.Cols), fmt.Sprintf("LINES=%d", pty.Rows)}
+			req.Reply(true, nil)
+			continue
+		}
+		if req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp" {
+			req.Reply(true, nil)
+			server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(dir))
+			if err != nil {
+				return
+			}
+			server.Serve()
+			server.Close()
+			return
+		}
+		if req.Type != "exec" || len(req.Payload) < 4 {
+			req.Reply(req.Type == "env", nil)
+			continue
+		}
+		req.Reply(true, nil)
+
+		command := string(req.Payload[4:])
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "HOME="+dir)
+		cmd.Stdout = channel
+		cmd.Stderr = channel.Stderr()
+		if ptyEnv != nil {
+			cmd.Env = append(cmd.Env, ptyEnv...)
+			cmd.Stderr = channel
+		}
+
+		// Copy stdin without waiting for it, as most clients never close it
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return
+		}
+		go func() {
+			io.Copy(stdin, channel)
+			stdin.Close()
+		}()
+
+		exitStatus := uint32(0)
+		if err := cmd.Run(); err != nil {
+			exitStatus = 1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				exitStatus = uint32(exitErr.ExitCode())
+			}
+		}
+
+		payload := make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, exitStatus)
+		channel.SendRequest("exit-status", false, payload)
+		return
+	}
+}
@@ -0,0 +1,115 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/diiyw/ed/ssh"
+)
+
+// Deploy step types
+const (
+	StepScript  = "script"
+	StepSync    = "sync"
+	StepSystemd = "systemd"
+)
+
+// Step is a deploy step run on every server after the deploy script
+type Step struct {
+	Type string `json:"type"` // "script", "sync" or "systemd"
+	Name string `json:"name,omitempty"`
+
+	// Script step
+	Script string `json:"script,omitempty"`
+
+	// Sync step, Source is a local directory and Target a remote directory
+	Source   string   `json:"source,omitempty"`
+	Target   string   `json:"target,omitempty"`
+	Delete   bool     `json:"delete,omitempty"`   // delete remote files missing locally
+	Checksum bool     `json:"checksum,omitempty"` // compare contents instead of mtime
+	Ignore   []string `json:"ignore,omitempty"`
+
+	// Systemd step
+	Service *Service `json:"service,omitempty"`
+}
+
+// title returns the name of a step for the deployment log
+func (s Step) title() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	if s.Type == StepSync {
+		return fmt.Sprintf("sync %s -> %s", s.Source, s.Target)
+	}
+	if s.Type == StepSystemd && s.Service != nil {
+		return "systemd " + s.Service.unitName()
+	}
+	return s.Type
+}
+
+// ValidateSteps checks the deploy steps of a project
+func ValidateSteps(steps []Step) error {
+	for i, step := range steps {
+		switch step.Type {
+		case StepScript:
+			if step.Script == "" {
+				return fmt.Errorf("step %d: script is required", i+1)
+			}
+		case StepSync:
+			if step.Source == "" || step.Target == "" {
+				return fmt.Errorf("step %d: source and target are required", i+1)
+			}
+		case StepSystemd:
+			if err := step.Service.validate(); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+		default:
+			return fmt.Errorf("step %d: invalid type: %s", i+1, step.Type)
+		}
+	}
+	return nil
+}
+
+// runSteps runs the deploy steps of a project on a server
+func (e *Engine) runSteps(ctx context.Context, deploymentID string, project *Project, sshConfig *SSHConfig, client *ssh.Client) error {
+	for _, step := range project.Steps {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		e.serverLog(deploymentID, sshConfig.Name, LogTypeStatus, fmt.Sprintf("Step: %s", step.title()))
+
+		switch step.Type {
+		case StepScript:
+			if err := e.runScript(ctx, deploymentID, project, sshConfig, client, step.Script); err != nil {
+				return err
+			}
+		case StepSync:
+			result, err := client.Sync(step.Source, step.Target, ssh.SyncOptions{
+				Checksum: step.Checksum,
+				Delete:   step.Delete,
+				Ignore:   step.Ignore,
+			})
+			if err != nil {
+				return fmt.Errorf("sync failed: %w", err)
+			}
+			for _, path := range result.Uploaded {
+				e.serverLog(deploymentID, sshConfig.Name, LogTypeLog, "  + "+path)
+			}
+			for _, path := range result.Deleted {
+				e.serverLog(deploymentID, sshConfig.Name, LogTypeLog, "  - "+path)
+			}
+			e.serverLog(deploymentID, sshConfig.Name, LogTypeLog, fmt.Sprintf("Synced %d files (%d bytes), %d unchanged, %d deleted",
+				len(result.Uploaded), result.Bytes, result.Unchanged, len(result.Deleted)))
+		case StepSystemd:
+			if err := e.runService(ctx, deploymentID, project, sshConfig, client, step.Service); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid step type: %s", step.Type)
+		}
+	}
+	return nil
+}
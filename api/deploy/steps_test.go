@@ -0,0 +1,171 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateSteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []Step
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"script", []Step{{Type: StepScript, Script: "echo hi"}}, false},
+		{"sync", []Step{{Type: StepSync, Source: "dist", Target: "/var/www"}}, false},
+		{"empty script", []Step{{Type: StepScript}}, true},
+		{"sync without target", []Step{{Type: StepSync, Source: "dist"}}, true},
+		{"unknown type", []Step{{Type: "rsync"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateSteps(tt.steps); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// writeTestFile writes a file, creating its parent directories
+func writeTestFile(t *testing.T, path string, data string, mode os.FileMode) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(data), mode); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatalf("Failed to chmod file: %v", err)
+	}
+}
+
+// deployOutput runs a deployment to completion and returns its log
+func deployOutput(t *testing.T, engine *Engine, deploymentID string, project *Project, sshConfig *SSHConfig) string {
+	t.Helper()
+
+	events, cancel := engine.Subscribe(deploymentID)
+	defer cancel()
+
+	engine.Enqueue(deploymentID, project, map[string]*SSHConfig{sshConfig.Name: sshConfig}, nil)
+	waitForDeploymentStatus(t, engine, deploymentID, "success")
+
+	var output strings.Builder
+	for event := range events {
+		output.WriteString(event.Data + "\n")
+	}
+	return output.String()
+}
+
+func TestDeploySyncStep(t *testing.T) {
+	local := t.TempDir()
+	writeTestFile(t, filepath.Join(local, "index.html"), "<h1>hello</h1>", 0644)
+	writeTestFile(t, filepath.Join(local, "bin", "start.sh"), "#!/bin/sh", 0755)
+	writeTestFile(t, filepath.Join(local, ".git", "HEAD"), "ref: main", 0644)
+
+	remote := t.TempDir()
+	writeTestFile(t, filepath.Join(remote, "site", "stale.txt"), "old", 0644)
+	writeTestFile(t, filepath.Join(remote, "site", "old", "page.html"), "old", 0644)
+	writeTestFile(t, filepath.Join(remote, "site", "access.log"), "keep", 0644)
+
+	sshConfig := newTestSSHD(t, "web-1", remote)
+	engine := NewEngine()
+	step := Step{
+		Type:   StepSync,
+		Source: local,
+		Target: "site",
+		Delete: true,
+		Ignore: []string{".git", "*.log"},
+	}
+	project := &Project{
+		Name:          "test-project",
+		DeployServers: []string{"web-1"},
+		Steps:         []Step{step, {Type: StepScript, Script: "cat site/index.html"}},
+	}
+
+	output := deployOutput(t, engine, "deploy-1", project, sshConfig)
+	if !strings.Contains(output, "Synced 2 files") || !strings.Contains(output, "3 deleted") {
+		t.Errorf("Expected 2 files synced and 3 deleted, got %s", output)
+	}
+	if !strings.Contains(output, "<h1>hello</h1>") {
+		t.Errorf("Expected script step to run after sync, got %s", output)
+	}
+
+	// Modes are kept, ignored and stale files handled
+	info, err := os.Stat(filepath.Join(remote, "site", "bin", "start.sh"))
+	if err != nil {
+		t.Fatalf("Expected start.sh to be uploaded: %v", err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Expected mode 0755, got %v", info.Mode().Perm())
+	}
+	for _, name := range []string{"stale.txt", "old", ".git"} {
+		if _, err := os.Stat(filepath.Join(remote, "site", name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be absent, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(remote, "site", "access.log")); err != nil {
+		t.Errorf("Expected ignored remote file to be kept: %v", err)
+	}
+
+	// Unchanged files are skipped
+	output = deployOutput(t, engine, "deploy-2", project, sshConfig)
+	if !strings.Contains(output, "Synced 0 files (0 bytes), 2 unchanged, 0 deleted") {
+		t.Errorf("Expected nothing to sync, got %s", output)
+	}
+
+	// A same size change with the old mtime is only found by checksum
+	stat, _ := os.Stat(filepath.Join(local, "index.html"))
+	writeTestFile(t, filepath.Join(local, "index.html"), "<h1>howdy</h1>", 0644)
+	os.Chtimes(filepath.Join(local, "index.html"), stat.ModTime(), stat.ModTime())
+
+	output = deployOutput(t, engine, "deploy-3", project, sshConfig)
+	if !strings.Contains(output, "Synced 0 files") {
+		t.Errorf("Expected mtime comparison to skip the file, got %s", output)
+	}
+
+	project.Steps[0].Checksum = true
+	output = deployOutput(t, engine, "deploy-4", project, sshConfig)
+	if !strings.Contains(output, "Synced 1 files") || !strings.Contains(output, "<h1>howdy</h1>") {
+		t.Errorf("Expected checksum comparison to upload the file, got %s", output)
+	}
+}
+
+func TestDeploySyncStep_DeleteStale(t *testing.T) {
+	local := t.TempDir()
+	writeTestFile(t, filepath.Join(local, "assets"), "now a file", 0644)
+
+	// A directory replaced by a file, and a stale directory holding an ignored file
+	remote := t.TempDir()
+	writeTestFile(t, filepath.Join(remote, "site", "assets", "app.js"), "old", 0644)
+	writeTestFile(t, filepath.Join(remote, "site", "assets", "css", "app.css"), "old", 0644)
+	writeTestFile(t, filepath.Join(remote, "site", "cache", "page.html"), "old", 0644)
+	writeTestFile(t, filepath.Join(remote, "site", "cache", "logs", "access.log"), "keep", 0644)
+
+	sshConfig := newTestSSHD(t, "web-1", remote)
+	project := &Project{
+		Name:          "test-project",
+		DeployServers: []string{"web-1"},
+		Steps:         []Step{{Type: StepSync, Source: local, Target: "site", Delete: true, Ignore: []string{"*.log"}}},
+	}
+
+	output := deployOutput(t, NewEngine(), "deploy-1", project, sshConfig)
+	if !strings.Contains(output, "Synced 1 files") || !strings.Contains(output, "1 deleted") {
+		t.Errorf("Expected 1 file synced and 1 deleted, got %s", output)
+	}
+
+	if data, err := os.ReadFile(filepath.Join(remote, "site", "assets")); err != nil || string(data) != "now a file" {
+		t.Errorf("Expected the directory to be replaced by the file, got %q: %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(remote, "site", "cache", "page.html")); !os.IsNotExist(err) {
+		t.Errorf("Expected the stale file to be deleted, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(remote, "site", "cache", "logs", "access.log")); err != nil {
+		t.Errorf("Expected the ignored file and its directories to be kept: %v", err)
+	}
+}
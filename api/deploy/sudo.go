@@ -0,0 +1,102 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/diiyw/ed/ssh"
+)
+
+// Sudo modes of a server
+const (
+	SudoNone         = "none"
+	SudoPasswordless = "passwordless"
+	SudoPassword     = "password"
+)
+
+// sudoPrompt is the password prompt sudo is told to print. It is stripped from
+// the streamed output.
+const sudoPrompt = "[ed-sudo-password]"
+
+// partialPrompt returns the length of the longest end of output that starts
+// the sudo prompt
+func partialPrompt(output string) int {
+	for n := min(len(output), len(sudoPrompt)-1); n > 0; n-- {
+		if strings.HasSuffix(output, sudoPrompt[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// Sudo configures how privileged deploy commands run on a server. Deploy script
+// lines starting with "sudo " are privileged.
+type Sudo struct {
+	Mode     string `json:"mode"`               // "none", "passwordless" or "password"
+	Password string `json:"password,omitempty"` // defaults to the SSH password
+}
+
+// ValidateSudo checks the sudo mode of a server
+func ValidateSudo(sudo *Sudo) error {
+	if sudo == nil {
+		return nil
+	}
+	switch sudo.Mode {
+	case "", SudoNone, SudoPasswordless, SudoPassword:
+		return nil
+	default:
+		return fmt.Errorf("invalid sudo mode: %s", sudo.Mode)
+	}
+}
+
+// remoteCommand returns the command running a deploy script line on a server,
+// and the password to write to its stdin, if any. Privileged lines run through
+// sudo, which reads the password from stdin so it never appears on a command line.
+func remoteCommand(sshConfig *SSHConfig, env string, line string) (string, []string, string, error) {
+	command, privileged := strings.CutPrefix(line, "sudo ")
+	shell := []string{"bash", "-c", shellQuote(env + command)}
+	if !privileged {
+		return shell[0], shell[1:], "", nil
+	}
+
+	mode := SudoNone
+	if sshConfig.Sudo != nil && sshConfig.Sudo.Mode != "" {
+		mode = sshConfig.Sudo.Mode
+	}
+
+	switch mode {
+	case SudoPasswordless:
+		return "sudo", append([]string{"-n", "--"}, shell...), "", nil
+	case SudoPassword:
+		password := sshConfig.Sudo.Password
+		if password == "" {
+			password = sshConfig.Password
+		}
+		if password == "" {
+			return "", nil, "", fmt.Errorf("no sudo password configured for %s", sshConfig.Name)
+		}
+		return "sudo", append([]string{"-S", "-p", shellQuote(sudoPrompt), "--"}, shell...), password, nil
+	default:
+		return "", nil, "", fmt.Errorf("sudo is not enabled on %s", sshConfig.Name)
+	}
+}
+
+// sudoWithoutPassword reports whether sudo runs privileged commands on client
+// without asking for a password, e.g. because of a NOPASSWD rule
+func sudoWithoutPassword(ctx context.Context, client *ssh.Client) bool {
+	cmd, err := client.CommandContext(ctx, "sudo", "-n", "true")
+	if err != nil {
+		return false
+	}
+	defer cmd.Close()
+	return cmd.Run() == nil
+}
+
+// withoutPassword rewrites the arguments of a sudo command reading its password
+// from stdin to never prompt
+func withoutPassword(args []string) []string {
+	command := args[slices.Index(args, "--")+1:]
+	return append([]string{"-n", "--"}, command...)
+}
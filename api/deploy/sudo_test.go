@@ -0,0 +1,150 @@
+package deploy
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSudo is a sudo stand-in that checks the password read with -S. Setting
+// FAKE_SUDO_NOPASSWD acts like a NOPASSWD rule: -S reads no password, and -n
+// fails without it.
+const fakeSudo = `#!/bin/sh
+while [ $# -gt 0 ]; do
+	case "$1" in
+		-n) [ -n "$FAKE_SUDO_NOPASSWD" ] || { echo "sudo: a password is required" >&2; exit 1; }; shift ;;
+		-S) stdin=1; shift ;;
+		-p) prompt="$2"; shift 2 ;;
+		--) shift; break ;;
+		*) break ;;
+	esac
+done
+if [ -n "$stdin" ] && [ -z "$FAKE_SUDO_NOPASSWD" ]; then
+	printf '%s' "$prompt" >&2
+	read -r password
+	[ "$password" = "rootpw" ] || { echo "sudo: incorrect password" >&2; exit 1; }
+fi
+SUDO_USER=deploy exec "$@"
+`
+
+// installFakeSudo puts fakeSudo first on the PATH of test SSH servers
+func installFakeSudo(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "sudo"), []byte(fakeSudo), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestRemoteCommand(t *testing.T) {
+	server := &SSHConfig{Name: "web-1", Password: "sshpw"}
+
+	name, args, password, err := remoteCommand(server, "", "echo hi")
+	if err != nil || name != "bash" || strings.Join(args, " ") != "-c 'echo hi'" || password != "" {
+		t.Errorf("Unexpected unprivileged command %s %v %q %v", name, args, password, err)
+	}
+
+	if _, _, _, err := remoteCommand(server, "", "sudo systemctl restart app"); err == nil {
+		t.Error("Expected error when sudo is not enabled")
+	}
+
+	server.Sudo = &Sudo{Mode: SudoPasswordless}
+	name, args, password, _ = remoteCommand(server, "", "sudo systemctl restart app")
+	if name != "sudo" || strings.Join(args, " ") != "-n -- bash -c 'systemctl restart app'" || password != "" {
+		t.Errorf("Unexpected passwordless command %s %v", name, args)
+	}
+
+	// The SSH password is reused and never appears in the command
+	server.Sudo = &Sudo{Mode: SudoPassword}
+	name, args, password, _ = remoteCommand(server, "", "sudo systemctl restart app")
+	if name != "sudo" || args[0] != "-S" || password != "sshpw" || strings.Contains(strings.Join(args, " "), "sshpw") {
+		t.Errorf("Unexpected password command %s %v %q", name, args, password)
+	}
+
+	server.Sudo.Password = "rootpw"
+	if _, _, password, _ = remoteCommand(server, "", "sudo true"); password != "rootpw" {
+		t.Errorf("Expected separate sudo password, got %q", password)
+	}
+}
+
+func TestDeployWithSudo(t *testing.T) {
+	installFakeSudo(t)
+
+	tests := []struct {
+		name     string
+		sudo     *Sudo
+		nopasswd bool
+		status   string
+	}{
+		{"password", &Sudo{Mode: SudoPassword, Password: "rootpw"}, false, "success"},
+		{"wrong password", &Sudo{Mode: SudoPassword}, false, "failed"},
+		{"password with NOPASSWD", &Sudo{Mode: SudoPassword, Password: "rootpw"}, true, "success"},
+		{"passwordless", &Sudo{Mode: SudoPasswordless}, true, "success"},
+		{"disabled", nil, false, "failed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nopasswd := ""
+			if tt.nopasswd {
+				nopasswd = "1"
+			}
+			t.Setenv("FAKE_SUDO_NOPASSWD", nopasswd)
+
+			sshConfig := newTestSSHD(t, "web-1", t.TempDir())
+			sshConfig.Sudo = tt.sudo
+
+			engine := NewEngine()
+			events, cancel := engine.Subscribe("deploy-1")
+			defer cancel()
+
+			project := &Project{
+				Name:          "test-project",
+				DeployScript:  "sudo echo \"root via $SUDO_USER\"\nsudo cat", // cat echoes any password left on stdin
+				DeployServers: []string{"web-1"},
+			}
+			engine.Enqueue("deploy-1", project, map[string]*SSHConfig{"web-1": sshConfig}, nil)
+			waitForDeploymentStatus(t, engine, "deploy-1", tt.status)
+
+			var output strings.Builder
+			for event := range events {
+				output.WriteString(event.Data + "\n")
+			}
+			if strings.Contains(output.String(), sudoPrompt) || strings.Contains(output.String(), "rootpw") {
+				t.Errorf("Expected prompt and password to be hidden, got %s", output.String())
+			}
+			if tt.status == "success" && !strings.Contains(output.String(), "root via deploy") {
+				t.Errorf("Expected privileged output, got %s", output.String())
+			}
+		})
+	}
+}
+
+func TestStreamOutput_SplitPrompt(t *testing.T) {
+	engine := NewEngine()
+	deploymentID := "test-deployment-1"
+
+	events, cancel := engine.Subscribe(deploymentID)
+	defer cancel()
+
+	// Each reader is returned by its own read, splitting the prompts
+	reader := io.MultiReader(
+		strings.NewReader("[ed-sudo"),
+		strings.NewReader("-password]installing ["),
+		strings.NewReader("ok]\n[ed-"),
+		strings.NewReader("sudo-password"),
+		strings.NewReader("]done\n["),
+	)
+	engine.streamOutput(deploymentID, "web-1", "tty", reader)
+	engine.broadcastLog(deploymentID, LogTypeStatus, "end")
+
+	var output string
+	for event := <-events; event.Type != string(LogTypeStatus); event = <-events {
+		output += event.Data
+	}
+	if output != "installing [ok]\ndone\n[" {
+		t.Errorf("Expected the split prompts to be removed, got %q", output)
+	}
+}
@@ -0,0 +1,183 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/diiyw/ed/ssh"
+)
+
+// DefaultUnitDir is where systemd unit files are uploaded
+var DefaultUnitDir = "/etc/systemd/system"
+
+// JournalLines is the number of journal lines logged when a service fails to start
+var JournalLines = 50
+
+// restartPolicies are the values of the systemd Restart= setting
+var restartPolicies = []string{"no", "on-success", "on-failure", "on-abnormal", "on-watchdog", "on-abort", "always"}
+
+// Service is the unit spec of a systemd step. The unit file is generated from it,
+// uploaded, and the service enabled and restarted.
+type Service struct {
+	Name             string            `json:"name"` // unit name without ".service"
+	Description      string            `json:"description,omitempty"`
+	ExecStart        string            `json:"exec_start"`
+	WorkingDirectory string            `json:"working_directory,omitempty"`
+	User             string            `json:"user,omitempty"`
+	Group            string            `json:"group,omitempty"`
+	Environment      map[string]string `json:"environment,omitempty"`
+	Restart          string            `json:"restart,omitempty"`     // e.g. "on-failure", empty for "always"
+	RestartSec       int               `json:"restart_sec,omitempty"` // seconds
+	After            []string          `json:"after,omitempty"`       // defaults to network.target
+	WantedBy         string            `json:"wanted_by,omitempty"`   // defaults to multi-user.target
+	UnitDir          string            `json:"unit_dir,omitempty"`    // defaults to DefaultUnitDir
+	Sudo             bool              `json:"sudo,omitempty"`        // manage the service through sudo
+}
+
+// unitName returns the unit name of the service
+func (s *Service) unitName() string {
+	return s.Name + ".service"
+}
+
+// validate checks the unit spec of a service
+func (s *Service) validate() error {
+	if s == nil || s.Name == "" || s.ExecStart == "" {
+		return fmt.Errorf("service name and exec_start are required")
+	}
+	if strings.ContainsAny(s.Name, "/ \t\n") {
+		return fmt.Errorf("invalid service name: %s", s.Name)
+	}
+	if strings.ContainsAny(s.ExecStart+s.WorkingDirectory+s.User+s.Group+s.Description+s.WantedBy, "\n") {
+		return fmt.Errorf("service settings must be single lines")
+	}
+	for _, unit := range s.After {
+		if unit == "" || strings.ContainsAny(unit, " \t\n") {
+			return fmt.Errorf("invalid after unit: %q", unit)
+		}
+	}
+	for name := range s.Environment {
+		if name == "" || strings.ContainsAny(name, "= \t\n") {
+			return fmt.Errorf("invalid environment variable name: %q", name)
+		}
+	}
+	if s.Restart != "" && !slices.Contains(restartPolicies, s.Restart) {
+		return fmt.Errorf("invalid restart policy: %s", s.Restart)
+	}
+	return nil
+}
+
+// unitFile generates the unit file of the service
+func (s *Service) unitFile() string {
+	after := s.After
+	if len(after) == 0 {
+		after = []string{"network.target"}
+	}
+	restart := s.Restart
+	if restart == "" {
+		restart = "always"
+	}
+	wantedBy := s.WantedBy
+	if wantedBy == "" {
+		wantedBy = "multi-user.target"
+	}
+	description := s.Description
+	if description == "" {
+		description = s.Name
+	}
+
+	var b strings.Builder
+	b.WriteString("# Generated by ed, do not edit\n")
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", description)
+	fmt.Fprintf(&b, "After=%s\n", strings.Join(after, " "))
+	b.WriteString("\n[Service]\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", s.ExecStart)
+	if s.WorkingDirectory != "" {
+		fmt.Fprintf(&b, "WorkingDirectory=%s\n", s.WorkingDirectory)
+	}
+	if s.User != "" {
+		fmt.Fprintf(&b, "User=%s\n", s.User)
+	}
+	if s.Group != "" {
+		fmt.Fprintf(&b, "Group=%s\n", s.Group)
+	}
+
+	names := make([]string, 0, len(s.Environment))
+	for name := range s.Environment {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "Environment=%s\n", systemdQuote(name+"="+s.Environment[name]))
+	}
+
+	fmt.Fprintf(&b, "Restart=%s\n", restart)
+	if s.RestartSec > 0 {
+		fmt.Fprintf(&b, "RestartSec=%d\n", s.RestartSec)
+	}
+	b.WriteString("\n[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=%s\n", wantedBy)
+	return b.String()
+}
+
+// systemdQuote quotes s as a single word of a unit file setting
+func systemdQuote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "%", "%%").Replace(s)
+	return `"` + s + `"`
+}
+
+// runService uploads the unit file of a systemd step, reloads systemd if it
+// changed, then enables and restarts the service. The journal of the service
+// is logged if it is not active afterwards.
+func (e *Engine) runService(ctx context.Context, deploymentID string, project *Project, sshConfig *SSHConfig, client *ssh.Client, service *Service) error {
+	unitDir := service.UnitDir
+	if unitDir == "" {
+		unitDir = DefaultUnitDir
+	}
+	target := path.Join(unitDir, service.unitName())
+	content := []byte(service.unitFile())
+
+	ftp, err := client.Sftp()
+	if err != nil {
+		return fmt.Errorf("failed to start sftp: %w", err)
+	}
+	changed, err := remoteDiffers(ftp, target, content)
+	if err == nil && changed {
+		err = e.writeTemplate(ctx, deploymentID, project, sshConfig, client, ftp, Template{Target: target, Mode: "0644", Sudo: service.Sudo}, content)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", target, err)
+	}
+
+	prefix := ""
+	if service.Sudo {
+		prefix = "sudo "
+	}
+	unit := shellQuote(service.unitName())
+
+	var script []string
+	if changed {
+		e.serverLog(deploymentID, sshConfig.Name, LogTypeLog, fmt.Sprintf("Unit %s changed", target))
+		script = append(script, prefix+"systemctl daemon-reload")
+	}
+	script = append(script,
+		prefix+"systemctl enable "+unit,
+		prefix+"systemctl restart "+unit,
+	)
+	if err := e.runScript(ctx, deploymentID, project, sshConfig, client, strings.Join(script, "\n")); err != nil {
+		return err
+	}
+
+	if err := e.runScript(ctx, deploymentID, project, sshConfig, client, "systemctl is-active "+unit); err != nil {
+		journal := fmt.Sprintf("%sjournalctl -u %s -n %d --no-pager", prefix, unit, JournalLines)
+		if err := e.runScript(ctx, deploymentID, project, sshConfig, client, journal); err != nil {
+			e.serverLog(deploymentID, sshConfig.Name, LogTypeError, fmt.Sprintf("Failed to read journal: %v", err))
+		}
+		return fmt.Errorf("service %s is not active", service.unitName())
+	}
+	return nil
+}
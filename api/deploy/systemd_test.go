@@ -0,0 +1,149 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSystemctl records its arguments in systemctl.log, and reports services
+// listed in the file named by FAKE_FAILED as inactive
+const fakeSystemctl = `#!/bin/sh
+echo "$*" >> systemctl.log
+if [ "$1" = "is-active" ]; then
+	if grep -qx "$2" "$FAKE_FAILED" 2>/dev/null; then
+		echo failed
+		exit 3
+	fi
+	echo active
+fi
+`
+
+// fakeJournalctl prints a journal line for the unit given with -u
+const fakeJournalctl = `#!/bin/sh
+echo "$2: panic: listen tcp :8080: address already in use"
+`
+
+// installFakeSystemd puts fakeSystemctl and fakeJournalctl first on the PATH of
+// test SSH servers, and returns the file listing failed units
+func installFakeSystemd(t *testing.T) string {
+	dir := t.TempDir()
+	for name, script := range map[string]string{"systemctl": fakeSystemctl, "journalctl": fakeJournalctl} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	failed := filepath.Join(dir, "failed")
+	t.Setenv("FAKE_FAILED", failed)
+	return failed
+}
+
+func TestServiceValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		service *Service
+		wantErr bool
+	}{
+		{"valid", &Service{Name: "app", ExecStart: "/opt/app/bin/app", Restart: "on-failure"}, false},
+		{"missing", nil, true},
+		{"no exec start", &Service{Name: "app"}, true},
+		{"invalid name", &Service{Name: "../app", ExecStart: "/bin/true"}, true},
+		{"multi-line", &Service{Name: "app", ExecStart: "/bin/true\nExecStartPre=/bin/false"}, true},
+		{"invalid restart", &Service{Name: "app", ExecStart: "/bin/true", Restart: "sometimes"}, true},
+		{"multi-line after", &Service{Name: "app", ExecStart: "/bin/true", After: []string{"network.target\nExecStartPre=/bin/false"}}, true},
+		{"empty after", &Service{Name: "app", ExecStart: "/bin/true", After: []string{""}}, true},
+		{"multi-line wanted by", &Service{Name: "app", ExecStart: "/bin/true", WantedBy: "multi-user.target\n[Service]"}, true},
+		{"environment name with =", &Service{Name: "app", ExecStart: "/bin/true", Environment: map[string]string{"A=B": "c"}}, true},
+		{"environment name with space", &Service{Name: "app", ExecStart: "/bin/true", Environment: map[string]string{"A B": "c"}}, true},
+		{"valid environment", &Service{Name: "app", ExecStart: "/bin/true", After: []string{"network.target", "db.service"}, Environment: map[string]string{"PORT": "8080 9090"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.service.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestServiceUnitFile(t *testing.T) {
+	service := &Service{
+		Name:        "app",
+		ExecStart:   "/opt/app/bin/app --port 8080",
+		User:        "app",
+		Environment: map[string]string{"MODE": "production", "GREETING": `say "hi"`},
+		RestartSec:  5,
+	}
+
+	expected := `# Generated by ed, do not edit
+[Unit]
+Description=app
+After=network.target
+
+[Service]
+ExecStart=/opt/app/bin/app --port 8080
+User=app
+Environment="GREETING=say \"hi\""
+Environment="MODE=production"
+Restart=always
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`
+	if got := service.unitFile(); got != expected {
+		t.Errorf("Unexpected unit file:\n%s", got)
+	}
+}
+
+func TestDeploySystemdStep(t *testing.T) {
+	failed := installFakeSystemd(t)
+
+	remote := t.TempDir()
+	sshConfig := newTestSSHD(t, "web-1", remote)
+	engine := NewEngine()
+
+	service := &Service{Name: "app", ExecStart: "/opt/app/bin/app", UnitDir: filepath.Join(remote, "units")}
+	project := &Project{
+		Name:          "test-project",
+		DeployServers: []string{"web-1"},
+		Steps:         []Step{{Type: StepSystemd, Service: service}},
+	}
+
+	deployOutput(t, engine, "deploy-1", project, sshConfig)
+	if _, err := os.Stat(filepath.Join(remote, "units", "app.service")); err != nil {
+		t.Fatalf("Expected unit file to be uploaded: %v", err)
+	}
+
+	// An unchanged unit is not reloaded
+	deployOutput(t, engine, "deploy-2", project, sshConfig)
+
+	calls, _ := os.ReadFile(filepath.Join(remote, "systemctl.log"))
+	expected := "daemon-reload\nenable app.service\nrestart app.service\nis-active app.service\n" +
+		"enable app.service\nrestart app.service\nis-active app.service\n"
+	if string(calls) != expected {
+		t.Errorf("Unexpected systemctl calls:\n%s", calls)
+	}
+
+	// A service that fails to start fails the deployment with its journal attached
+	os.WriteFile(failed, []byte("app.service\n"), 0644)
+	events, cancel := engine.Subscribe("deploy-3")
+	defer cancel()
+	engine.Enqueue("deploy-3", project, map[string]*SSHConfig{"web-1": sshConfig}, nil)
+	waitForDeploymentStatus(t, engine, "deploy-3", "failed")
+
+	var output strings.Builder
+	for event := range events {
+		output.WriteString(event.Data + "\n")
+	}
+	if !strings.Contains(output.String(), "app.service: panic: listen tcp :8080") {
+		t.Errorf("Expected journal in the deploy log, got %s", output.String())
+	}
+	if !strings.Contains(output.String(), "service app.service is not active") {
+		t.Errorf("Expected inactive service error, got %s", output.String())
+	}
+}
@@ -0,0 +1,274 @@
+package deploy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/diiyw/ed/ssh"
+	"github.com/google/uuid"
+	"github.com/pkg/sftp"
+)
+
+// Template is a config file rendered per server and uploaded before the deploy
+// script. The file is only written when its content changed, and a change runs
+// the named handler once the deployment to the server is done.
+type Template struct {
+	Source  string `json:"source,omitempty"`  // local template file
+	Content string `json:"content,omitempty"` // inline template, used when Source is empty
+	Target  string `json:"target"`            // remote path
+	Owner   string `json:"owner,omitempty"`   // "user" or "user:group"
+	Mode    string `json:"mode,omitempty"`    // octal, e.g. "0644"
+	Sudo    bool   `json:"sudo,omitempty"`    // write the target through sudo
+	Handler string `json:"handler,omitempty"` // name of a project handler run on change
+}
+
+// TemplateData is the data templates are rendered with
+type TemplateData struct {
+	Project     string
+	Environment string
+	Version     string
+	Server      TemplateServer
+	Vars        map[string]string
+}
+
+// TemplateServer describes the server a template is rendered for
+type TemplateServer struct {
+	Name string
+	Host string
+	Port int
+	User string
+}
+
+// name returns the name of a template for the deployment log
+func (t Template) name() string {
+	if t.Source != "" {
+		return t.Source
+	}
+	return path.Base(t.Target)
+}
+
+// mode returns the parsed file mode of a template, 0 if not set
+func (t Template) mode() (os.FileMode, error) {
+	if t.Mode == "" {
+		return 0, nil
+	}
+	mode, err := strconv.ParseUint(t.Mode, 8, 32)
+	if err != nil || mode > 0o7777 {
+		return 0, fmt.Errorf("invalid mode: %s", t.Mode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// ValidateTemplates checks the config templates of a project against its handlers
+func ValidateTemplates(templates []Template, handlers map[string]string) error {
+	for i, t := range templates {
+		if t.Source == "" && t.Content == "" {
+			return fmt.Errorf("template %d: source or content is required", i+1)
+		}
+		if t.Target == "" {
+			return fmt.Errorf("template %d: target is required", i+1)
+		}
+		if _, err := t.mode(); err != nil {
+			return fmt.Errorf("template %d: %w", i+1, err)
+		}
+		if t.Handler != "" {
+			if _, exists := handlers[t.Handler]; !exists {
+				return fmt.Errorf("template %d: unknown handler: %s", i+1, t.Handler)
+			}
+		}
+	}
+	return nil
+}
+
+// render renders a template for a server
+func (t Template) render(project *Project, sshConfig *SSHConfig) ([]byte, error) {
+	text := t.Content
+	if t.Source != "" {
+		data, err := os.ReadFile(t.Source)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+
+	tmpl, err := template.New(t.name()).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := project.Variables
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	var b bytes.Buffer
+	err = tmpl.Execute(&b, TemplateData{
+		Project:     project.Name,
+		Environment: project.Environment,
+		Version:     project.Version,
+		Server: TemplateServer{
+			Name: sshConfig.Name,
+			Host: sshConfig.Host,
+			Port: sshConfig.Port,
+			User: sshConfig.User,
+		},
+		Vars: vars,
+	})
+	return b.Bytes(), err
+}
+
+// runTemplates renders and uploads the config templates of a project to a server.
+// It returns the handlers triggered by changed files, in order and without duplicates.
+func (e *Engine) runTemplates(ctx context.Context, deploymentID string, project *Project, sshConfig *SSHConfig, client *ssh.Client) ([]string, error) {
+	if len(project.Templates) == 0 {
+		return nil, nil
+	}
+
+	ftp, err := client.Sftp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start sftp: %w", err)
+	}
+
+	var handlers []string
+	for _, t := range project.Templates {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		content, err := t.render(project, sshConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render template %s: %w", t.name(), err)
+		}
+
+		changed, err := remoteDiffers(ftp, t.Target, content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", t.Target, err)
+		}
+		if !changed {
+			e.serverLog(deploymentID, sshConfig.Name, LogTypeLog, fmt.Sprintf("Template %s -> %s unchanged", t.name(), t.Target))
+			continue
+		}
+
+		if err := e.writeTemplate(ctx, deploymentID, project, sshConfig, client, ftp, t, content); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", t.Target, err)
+		}
+		e.serverLog(deploymentID, sshConfig.Name, LogTypeLog, fmt.Sprintf("Template %s -> %s changed", t.name(), t.Target))
+
+		if t.Handler != "" && !slices.Contains(handlers, t.Handler) {
+			handlers = append(handlers, t.Handler)
+		}
+	}
+	return handlers, nil
+}
+
+// writeTemplate writes rendered content to the target of a template and applies
+// its mode and owner. With sudo the content is uploaded to a temporary file and
+// moved into place by a privileged script.
+func (e *Engine) writeTemplate(ctx context.Context, deploymentID string, project *Project, sshConfig *SSHConfig, client *ssh.Client, ftp *sftp.Client, t Template, content []byte) error {
+	mode, _ := t.mode()
+
+	if !t.Sudo {
+		if err := writeRemoteFile(ftp, t.Target, content); err != nil {
+			return err
+		}
+		if mode != 0 {
+			if err := ftp.Chmod(t.Target, mode); err != nil {
+				return err
+			}
+		}
+		if t.Owner != "" {
+			return e.runScript(ctx, deploymentID, project, sshConfig, client, "chown "+shellQuote(t.Owner)+" "+shellQuote(t.Target))
+		}
+		return nil
+	}
+
+	tmp := "/tmp/.ed-" + uuid.NewString()
+	if err := writePrivateFile(ftp, tmp, content); err != nil {
+		return err
+	}
+	defer ftp.Remove(tmp)
+
+	// cat keeps the mode of an existing target, and does not copy the private
+	// mode of the temporary file to a new one
+	script := []string{"sudo cat " + shellQuote(tmp) + " > " + shellQuote(t.Target)}
+	if mode != 0 {
+		script = append(script, fmt.Sprintf("sudo chmod %o %s", uint32(mode), shellQuote(t.Target)))
+	}
+	if t.Owner != "" {
+		script = append(script, "sudo chown "+shellQuote(t.Owner)+" "+shellQuote(t.Target))
+	}
+	return e.runScript(ctx, deploymentID, project, sshConfig, client, strings.Join(script, "\n"))
+}
+
+// remoteDiffers reports whether a remote file is missing or differs from content.
+// An unreadable file is treated as changed.
+func remoteDiffers(ftp *sftp.Client, remotePath string, content []byte) (bool, error) {
+	f, err := ftp.Open(remotePath)
+	if os.IsNotExist(err) || os.IsPermission(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	current, err := io.ReadAll(f)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(current, content), nil
+}
+
+// writeRemoteFile writes content to a remote path, creating its parent directory
+func writeRemoteFile(ftp *sftp.Client, remotePath string, content []byte) error {
+	if err := ftp.MkdirAll(path.Dir(remotePath)); err != nil {
+		return err
+	}
+	f, err := ftp.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writePrivateFile creates a remote file readable only by its owner and writes
+// content to it. The mode is set before any content is written.
+func writePrivateFile(ftp *sftp.Client, remotePath string, content []byte) error {
+	f, err := ftp.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// runHandlers runs the project handlers triggered by changed templates
+func (e *Engine) runHandlers(ctx context.Context, deploymentID string, project *Project, sshConfig *SSHConfig, client *ssh.Client, handlers []string) error {
+	for _, name := range handlers {
+		e.serverLog(deploymentID, sshConfig.Name, LogTypeStatus, fmt.Sprintf("Handler: %s", name))
+		if err := e.runScript(ctx, deploymentID, project, sshConfig, client, project.Handlers[name]); err != nil {
+			return fmt.Errorf("handler %s failed: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package deploy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateTemplates(t *testing.T) {
+	handlers := map[string]string{"reload": "nginx -s reload"}
+	tests := []struct {
+		name      string
+		templates []Template
+		wantErr   bool
+	}{
+		{"none", nil, false},
+		{"valid", []Template{{Source: "nginx.conf.tmpl", Target: "/etc/nginx/app.conf", Mode: "0644", Handler: "reload"}}, false},
+		{"no source", []Template{{Target: "/etc/app.conf"}}, true},
+		{"no target", []Template{{Content: "x"}}, true},
+		{"invalid mode", []Template{{Content: "x", Target: "/etc/app.conf", Mode: "rw-r--r--"}}, true},
+		{"unknown handler", []Template{{Content: "x", Target: "/etc/app.conf", Handler: "restart"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateTemplates(tt.templates, handlers); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestDeployTemplates(t *testing.T) {
+	local := t.TempDir()
+	writeTestFile(t, filepath.Join(local, "app.env.tmpl"), "PORT={{.Vars.PORT}}\n", 0644)
+
+	remote := t.TempDir()
+	sshConfig := newTestSSHD(t, "web-1", remote)
+	engine := NewEngine()
+
+	project := &Project{
+		Name:          "test-project",
+		DeployServers: []string{"web-1"},
+		Environment:   "production",
+		Version:       "v1",
+		Variables:     map[string]string{"PORT": "8080"},
+		Templates: []Template{
+			{
+				Content: "server {{.Server.Name}} {{.Environment}} {{.Version}}\n",
+				Target:  filepath.Join(remote, "nginx", "app.conf"),
+				Mode:    "0600",
+				Handler: "reload",
+			},
+			{
+				Source:  filepath.Join(local, "app.env.tmpl"),
+				Target:  filepath.Join(remote, "app.env"),
+				Handler: "reload",
+			},
+		},
+		Handlers: map[string]string{"reload": "echo reloaded >> reloads"},
+	}
+
+	output := deployOutput(t, engine, "deploy-1", project, sshConfig)
+	if strings.Count(output, "changed") != 2 {
+		t.Errorf("Expected both templates to change, got %s", output)
+	}
+
+	data, err := os.ReadFile(filepath.Join(remote, "nginx", "app.conf"))
+	if err != nil {
+		t.Fatalf("Expected app.conf to be written: %v", err)
+	}
+	if string(data) != "server web-1 production v1\n" {
+		t.Errorf("Unexpected app.conf %q", data)
+	}
+	if info, _ := os.Stat(filepath.Join(remote, "nginx", "app.conf")); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(filepath.Join(remote, "app.env")); string(data) != "PORT=8080\n" {
+		t.Errorf("Unexpected app.env %q", data)
+	}
+
+	// A handler runs once however many of its templates changed, and not at
+	// all when none did
+	output = deployOutput(t, engine, "deploy-2", project, sshConfig)
+	if strings.Count(output, "unchanged") != 2 || strings.Contains(output, "Handler:") {
+		t.Errorf("Expected templates to be unchanged, got %s", output)
+	}
+
+	project.Variables["PORT"] = "9090"
+	deployOutput(t, engine, "deploy-3", project, sshConfig)
+
+	data, _ = os.ReadFile(filepath.Join(remote, "reloads"))
+	if string(data) != "reloaded\nreloaded\n" {
+		t.Errorf("Expected the handler to run once per changed deployment, got %q", data)
+	}
+}
+
+func TestDeployTemplateMissingVariable(t *testing.T) {
+	sshConfig := newTestSSHD(t, "web-1", t.TempDir())
+	engine := NewEngine()
+
+	project := &Project{
+		Name:          "test-project",
+		DeployServers: []string{"web-1"},
+		Templates:     []Template{{Content: "{{.Vars.MISSING}}", Target: "app.conf"}},
+	}
+	engine.Enqueue("deploy-1", project, map[string]*SSHConfig{"web-1": sshConfig}, nil)
+	waitForDeploymentStatus(t, engine, "deploy-1", "failed")
+}
+
+func TestDeployTemplateWithSudo(t *testing.T) {
+	installFakeSudo(t)
+	t.Setenv("FAKE_SUDO_NOPASSWD", "1")
+
+	remote := t.TempDir()
+	sshConfig := newTestSSHD(t, "web-1", remote)
+	sshConfig.Sudo = &Sudo{Mode: SudoPasswordless}
+	engine := NewEngine()
+
+	target := filepath.Join(remote, "app.conf")
+	project := &Project{
+		Name:          "test-project",
+		DeployServers: []string{"web-1"},
+		Templates:     []Template{{Content: "secret=1\n", Target: target, Sudo: true}},
+	}
+	deployOutput(t, engine, "deploy-1", project, sshConfig)
+
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatalf("Expected app.conf to be written: %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "secret=1\n" {
+		t.Errorf("Unexpected app.conf %q", data)
+	}
+	if info.Mode().Perm()&0044 == 0 {
+		t.Errorf("Expected a new target to get the default mode, got %v", info.Mode().Perm())
+	}
+}
+
+func TestWritePrivateFile(t *testing.T) {
+	remote := t.TempDir()
+	engine := NewEngine()
+	client, release, err := engine.connect(newTestSSHD(t, "web-1", remote))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer release()
+	ftp, err := client.NewSftp()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ftp.Close()
+
+	path := filepath.Join(remote, "tmp")
+	if err := writePrivateFile(ftp, path, []byte("secret")); err != nil {
+		t.Fatalf("writePrivateFile failed: %v", err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// An existing file, possibly planted by another user, is never written
+	if err := writePrivateFile(ftp, path, []byte("secret")); err == nil {
+		t.Error("Expected error when the file exists")
+	}
+}
@@ -0,0 +1,50 @@
+package deploy
+
+import "strings"
+
+// Defaults of pseudo-terminals allocated for deploy commands
+var (
+	DefaultTerm = "xterm-256color"
+	DefaultCols = 80
+	DefaultRows = 24
+)
+
+// TTY allocates a pseudo-terminal for deploy script commands, so tools show
+// their interactive output. Output is a single stream with ANSI sequences intact.
+type TTY struct {
+	Term     string   `json:"term,omitempty"`
+	Cols     int      `json:"cols,omitempty"`
+	Rows     int      `json:"rows,omitempty"`
+	Commands []string `json:"commands,omitempty"` // script lines starting with one of these get a terminal, empty for all
+}
+
+// matches reports whether a deploy script line runs with a terminal
+func (t *TTY) matches(line string) bool {
+	if t == nil {
+		return false
+	}
+	if len(t.Commands) == 0 {
+		return true
+	}
+	for _, prefix := range t.Commands {
+		if line == prefix || strings.HasPrefix(line, prefix+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// size returns the terminal type and size with defaults applied
+func (t *TTY) size() (string, int, int) {
+	term, cols, rows := t.Term, t.Cols, t.Rows
+	if term == "" {
+		term = DefaultTerm
+	}
+	if cols <= 0 {
+		cols = DefaultCols
+	}
+	if rows <= 0 {
+		rows = DefaultRows
+	}
+	return term, cols, rows
+}
@@ -0,0 +1,64 @@
+package deploy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTTYMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		tty      *TTY
+		line     string
+		expected bool
+	}{
+		{"disabled", nil, "npm ci", false},
+		{"all commands", &TTY{}, "ls", true},
+		{"listed command", &TTY{Commands: []string{"npm", "docker compose"}}, "docker compose up -d", true},
+		{"bare command", &TTY{Commands: []string{"npm"}}, "npm", true},
+		{"other command", &TTY{Commands: []string{"npm"}}, "npmx install", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.tty.matches(tt.line); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDeployWithTTY(t *testing.T) {
+	sshConfig := newTestSSHD(t, "web-1", t.TempDir())
+
+	engine := NewEngine()
+	events, cancel := engine.Subscribe("deploy-1")
+	defer cancel()
+
+	project := &Project{
+		Name:          "test-project",
+		DeployScript:  "printf '\\033[32m%s %s\\033[0m\\r\\n' \"$TERM\" \"$COLUMNS\" >&2\necho plain >&2",
+		DeployServers: []string{"web-1"},
+		TTY:           &TTY{Cols: 132, Commands: []string{"printf"}},
+	}
+	engine.Enqueue("deploy-1", project, map[string]*SSHConfig{"web-1": sshConfig}, nil)
+	waitForDeploymentStatus(t, engine, "deploy-1", "success")
+
+	var ttyOutput, stderrOutput string
+	for event := range events {
+		switch event.Stream {
+		case "tty":
+			ttyOutput += event.Data
+		case "stderr":
+			stderrOutput += event.Data
+		}
+	}
+
+	// Terminal output is merged and passed on with ANSI sequences intact
+	if ttyOutput != "\x1b[32mxterm-256color 132\x1b[0m\r\n" {
+		t.Errorf("Unexpected terminal output %q", ttyOutput)
+	}
+	if !strings.Contains(stderrOutput, "plain") {
+		t.Errorf("Expected commands without a terminal to keep stderr, got %q", stderrOutput)
+	}
+}
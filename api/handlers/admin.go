@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+type AdminHandler struct {
+	engine *deploy.Engine
+}
+
+func NewAdminHandler(engine *deploy.Engine) *AdminHandler {
+	return &AdminHandler{engine: engine}
+}
+
+// LogUsage reports the storage used by deployment logs
+func (h *AdminHandler) LogUsage(c *gin.Context) {
+	store := h.logStore(c)
+	if store == nil {
+		return
+	}
+
+	usage, err := store.Usage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to read log storage: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"usage": usage,
+		},
+	})
+}
+
+// Connections reports the SSH connections pooled by the engine
+func (h *AdminHandler) Connections(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": h.engine.PoolStats(),
+	})
+}
+
+// PruneLogs applies the log retention rules now and reports what was removed
+func (h *AdminHandler) PruneLogs(c *gin.Context) {
+	store := h.logStore(c)
+	if store == nil {
+		return
+	}
+
+	removed, err := store.Prune()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to prune logs: %v", err),
+		})
+		return
+	}
+
+	usage, err := store.Usage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to read log storage: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"removed": removed,
+			"usage":   usage,
+		},
+		"message": "Logs pruned successfully",
+	})
+}
+
+// logStore returns the engine's log store, or responds with 404 if log storage is disabled
+func (h *AdminHandler) logStore(c *gin.Context) *deploy.LogStore {
+	store := h.engine.LogStore()
+	if store == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Log storage is not enabled",
+		})
+	}
+	return store
+}
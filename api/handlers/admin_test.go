@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// Unit Tests for Admin API Handlers
+
+func TestLogAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine := deploy.NewEngine()
+	handler := NewAdminHandler(engine)
+	router := gin.New()
+	router.GET("/api/admin/logs", handler.LogUsage)
+	router.POST("/api/admin/logs/prune", handler.PruneLogs)
+
+	// Log storage is disabled by default
+	req := httptest.NewRequest("GET", "/api/admin/logs", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+
+	dir := t.TempDir()
+	store, err := deploy.NewLogStore(dir, deploy.LogRetention{MaxPerProject: 1})
+	if err != nil {
+		t.Fatalf("NewLogStore failed: %v", err)
+	}
+	engine.SetLogStore(store)
+
+	os.MkdirAll(filepath.Join(dir, "project1"), 0755)
+	os.WriteFile(filepath.Join(dir, "project1", "project1-1.log.gz"), []byte("old"), 0644)
+	os.WriteFile(filepath.Join(dir, "project1", "project1-2.log.gz"), []byte("new"), 0644)
+
+	req = httptest.NewRequest("GET", "/api/admin/logs", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	usage := response["data"].(map[string]interface{})["usage"].(map[string]interface{})
+	if usage["files"].(float64) != 2 || usage["bytes"].(float64) != 6 {
+		t.Errorf("Unexpected usage %v", usage)
+	}
+
+	req = httptest.NewRequest("POST", "/api/admin/logs/prune", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	removed := response["data"].(map[string]interface{})["removed"].(map[string]interface{})
+	if removed["files"].(float64) != 1 {
+		t.Errorf("Expected 1 removed log, got %v", removed)
+	}
+}
+
+func TestConnections(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewAdminHandler(deploy.NewEngine())
+	router := gin.New()
+	router.GET("/api/admin/connections", handler.Connections)
+
+	req := httptest.NewRequest("GET", "/api/admin/connections", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response struct {
+		Data map[string]int `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response.Data["open"] != 0 || response.Data["dials"] != 0 {
+		t.Errorf("Expected an empty pool, got %v", response.Data)
+	}
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// ansiColors are the CSS colours of the 16 standard ANSI colours
+var ansiColors = [16]string{
+	"#000000", "#cd3131", "#0dbc79", "#e5e510", "#2472c8", "#bc3fbc", "#11a8cd", "#e5e5e5",
+	"#666666", "#f14c4c", "#23d18b", "#f5f543", "#3b8eea", "#d670d6", "#29b8db", "#ffffff",
+}
+
+// ansiStyle is the text style set by ANSI SGR sequences
+type ansiStyle struct {
+	fg, bg    string
+	bold, dim bool
+	italic    bool
+	underline bool
+}
+
+// css returns the inline style of s, or "" for the default style
+func (s ansiStyle) css() string {
+	var rules []string
+	if s.fg != "" {
+		rules = append(rules, "color:"+s.fg)
+	}
+	if s.bg != "" {
+		rules = append(rules, "background-color:"+s.bg)
+	}
+	if s.bold {
+		rules = append(rules, "font-weight:bold")
+	}
+	if s.dim {
+		rules = append(rules, "opacity:0.7")
+	}
+	if s.italic {
+		rules = append(rules, "font-style:italic")
+	}
+	if s.underline {
+		rules = append(rules, "text-decoration:underline")
+	}
+	return strings.Join(rules, ";")
+}
+
+// apply updates s with the parameters of an SGR sequence
+func (s *ansiStyle) apply(params []int) {
+	if len(params) == 0 {
+		params = []int{0}
+	}
+
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		switch {
+		case p == 0:
+			*s = ansiStyle{}
+		case p == 1:
+			s.bold = true
+		case p == 2:
+			s.dim = true
+		case p == 3:
+			s.italic = true
+		case p == 4:
+			s.underline = true
+		case p == 22:
+			s.bold, s.dim = false, false
+		case p == 23:
+			s.italic = false
+		case p == 24:
+			s.underline = false
+		case p >= 30 && p <= 37:
+			s.fg = ansiColors[p-30]
+		case p >= 90 && p <= 97:
+			s.fg = ansiColors[p-90+8]
+		case p == 39:
+			s.fg = ""
+		case p >= 40 && p <= 47:
+			s.bg = ansiColors[p-40]
+		case p >= 100 && p <= 107:
+			s.bg = ansiColors[p-100+8]
+		case p == 49:
+			s.bg = ""
+		case p == 38 || p == 48:
+			color, n := extendedColor(params[i+1:])
+			i += n
+			if color == "" {
+				continue
+			}
+			if p == 38 {
+				s.fg = color
+			} else {
+				s.bg = color
+			}
+		}
+	}
+}
+
+// extendedColor parses the 256 colour (5;n) or true colour (2;r;g;b) parameters
+// following 38 or 48. It returns the colour and the number of parameters used.
+func extendedColor(params []int) (string, int) {
+	if len(params) >= 2 && params[0] == 5 {
+		return xtermColor(params[1]), 2
+	}
+	if len(params) >= 4 && params[0] == 2 {
+		return fmt.Sprintf("#%02x%02x%02x", params[1]&0xff, params[2]&0xff, params[3]&0xff), 4
+	}
+	return "", len(params)
+}
+
+// xtermColor returns the CSS colour of an xterm 256 colour index
+func xtermColor(n int) string {
+	switch {
+	case n < 0 || n > 255:
+		return ""
+	case n < 16:
+		return ansiColors[n]
+	case n < 232:
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	default:
+		gray := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", gray, gray, gray)
+	}
+}
+
+// ansiToHTML converts text with ANSI escape sequences into escaped HTML, with
+// colours and text styles as styled spans. Other escape sequences are removed.
+func ansiToHTML(text string) string {
+	var b strings.Builder
+	var style ansiStyle
+	open := false
+
+	for len(text) > 0 {
+		esc := strings.IndexByte(text, 0x1b)
+		if esc < 0 {
+			b.WriteString(html.EscapeString(text))
+			break
+		}
+		b.WriteString(html.EscapeString(text[:esc]))
+		text = text[esc+1:]
+
+		if !strings.HasPrefix(text, "[") {
+			// Not a CSI sequence, drop the escape and its next byte
+			if len(text) > 0 {
+				text = text[1:]
+			}
+			continue
+		}
+
+		// CSI sequences end with a byte in the range @ to ~
+		end := strings.IndexFunc(text[1:], func(r rune) bool { return r >= '@' && r <= '~' })
+		if end < 0 {
+			break
+		}
+		params, final := text[1:end+1], text[end+1]
+		text = text[end+2:]
+		if final != 'm' {
+			continue
+		}
+
+		style.apply(parseSGR(params))
+		if open {
+			b.WriteString("</span>")
+			open = false
+		}
+		if css := style.css(); css != "" {
+			fmt.Fprintf(&b, `<span style="%s">`, css)
+			open = true
+		}
+	}
+
+	if open {
+		b.WriteString("</span>")
+	}
+	return b.String()
+}
+
+// parseSGR parses the semicolon separated parameters of an SGR sequence
+func parseSGR(params string) []int {
+	if params == "" {
+		return nil
+	}
+
+	var result []int
+	for _, p := range strings.Split(params, ";") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			n = 0
+		}
+		result = append(result, n)
+	}
+	return result
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import "testing"
+
+func TestAnsiToHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain text", "hello <world>", "hello &lt;world&gt;"},
+		{"foreground colour", "\x1b[31merror\x1b[0m done", `<span style="color:#cd3131">error</span> done`},
+		{"bold bright", "\x1b[1;92mok\x1b[m", `<span style="color:#23d18b;font-weight:bold">ok</span>`},
+		{"256 colour background", "\x1b[48;5;196mx", `<span style="background-color:#ff0000">x</span>`},
+		{"true colour", "\x1b[38;2;1;2;3mx\x1b[39m", `<span style="color:#010203">x</span>`},
+		{"style change", "\x1b[31ma\x1b[4mb", `<span style="color:#cd3131">a</span><span style="color:#cd3131;text-decoration:underline">b</span>`},
+		{"other sequences removed", "\x1b[2Kline\x1b]x", "linex"},
+		{"unterminated sequence", "text\x1b[31", "text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ansiToHTML(tt.input); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
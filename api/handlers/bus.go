@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/diiyw/ed/api/bus"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+type BusHandler struct {
+	bus *bus.Bus
+}
+
+func NewBusHandler(b *bus.Bus) *BusHandler {
+	return &BusHandler{bus: b}
+}
+
+// WebSocket streams all configuration, deployment and server health changes
+// over a WebSocket until the client disconnects
+func (h *BusHandler) WebSocket(c *gin.Context) {
+	events, cancel := h.bus.Subscribe()
+	defer cancel()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Read until the client goes away, so its close frame is handled
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "too slow"))
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// Stream streams all configuration, deployment and server health changes as
+// Server-Sent Events until the client disconnects
+func (h *BusHandler) Stream(c *gin.Context) {
+	events, cancel := h.bus.Subscribe()
+	defer cancel()
+
+	startSSE(c)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(SSEHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			err := sse.Encode(c.Writer, sse.Event{
+				Id:   strconv.FormatUint(event.Seq, 10),
+				Data: event,
+			})
+			if err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+		c.Writer.Flush()
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/ed/api/bus"
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Unit Tests for Live Change Stream
+
+func TestBusWebSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{SSHConfigs: []SSHConfig{}, Projects: []Project{}}
+	events := bus.New(deploy.NewEngine())
+
+	sshHandler := NewSSHHandler(config)
+	sshHandler.SetBus(events)
+	projectHandler := NewProjectHandler(config, deploy.NewEngine())
+	projectHandler.SetBus(events)
+
+	router := gin.New()
+	router.POST("/api/ssh", sshHandler.Create)
+	router.DELETE("/api/projects/:name", projectHandler.Delete)
+	router.POST("/api/projects", projectHandler.Create)
+	router.GET("/ws/events", NewBusHandler(events).WebSocket)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/events"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"POST", "/api/ssh", `{"name":"server1","host":"localhost","user":"root","auth_type":"password"}`},
+		{"POST", "/api/projects", `{"name":"project1"}`},
+		{"DELETE", "/api/projects/project1", ""},
+	}
+	for _, r := range requests {
+		req, _ := http.NewRequest(r.method, server.URL+r.path, bytes.NewBufferString(r.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	expected := []struct{ eventType, name string }{
+		{bus.SSHCreated, "server1"},
+		{bus.ProjectCreated, "project1"},
+		{bus.ProjectDeleted, "project1"},
+	}
+	for _, want := range expected {
+		var event bus.Event
+		if err := conn.ReadJSON(&event); err != nil {
+			t.Fatalf("Failed to read event: %v", err)
+		}
+		if event.Type != want.eventType || event.Name != want.name {
+			t.Errorf("Expected %s %s, got %+v", want.eventType, want.name, event)
+		}
+	}
+}
+
+func TestBusRedactsSSHCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{SSHConfigs: []SSHConfig{}, Projects: []Project{}}
+	b := bus.New(deploy.NewEngine())
+	events, cancel := b.Subscribe()
+	defer cancel()
+
+	handler := NewSSHHandler(config)
+	handler.SetBus(b)
+	router := gin.New()
+	router.POST("/api/ssh", handler.Create)
+	router.PUT("/api/ssh/:name", handler.Update)
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"POST", "/api/ssh", `{"name":"server1","host":"localhost","user":"root","auth_type":"password","password":"secret-password","sudo":{"mode":"password","password":"secret-sudo"}}`},
+		{"PUT", "/api/ssh/server1", `{"name":"server1","host":"localhost","user":"root","auth_type":"key","key_file":"/keys/server1","key_pass":"secret-key-pass","private_key":"secret-private-key","tags":["web"]}`},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.path, bytes.NewBufferString(r.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: got status %d: %s", r.method, r.path, w.Code, w.Body.String())
+		}
+	}
+
+	for _, eventType := range []string{bus.SSHCreated, bus.SSHUpdated} {
+		event := <-events
+		if event.Type != eventType {
+			t.Fatalf("Expected %s, got %+v", eventType, event)
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "secret") {
+			t.Errorf("Expected no credentials in %s, got %s", eventType, data)
+		}
+		if !strings.Contains(string(data), `"host":"localhost"`) {
+			t.Errorf("Expected the server in %s, got %s", eventType, data)
+		}
+	}
+}
+
+func TestBusRedactsWebhookSecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{SSHConfigs: []SSHConfig{}, Projects: []Project{}}
+	b := bus.New(deploy.NewEngine())
+	events, cancel := b.Subscribe()
+	defer cancel()
+
+	handler := NewProjectHandler(config, deploy.NewEngine())
+	handler.SetBus(b)
+	router := gin.New()
+	router.POST("/api/projects", handler.Create)
+	router.PUT("/api/projects/:name", handler.Update)
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{"POST", "/api/projects", `{"name":"project1","deploy_servers":["server1"],"webhook":{"secret":"secret-webhook"}}`},
+		{"PUT", "/api/projects/project1", `{"name":"project1","deploy_servers":["server1"],"webhook":{"secret":"secret-rotated"},"environments":[{"name":"prod","deploy_servers":["server1"]}]}`},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.path, bytes.NewBufferString(r.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: got status %d: %s", r.method, r.path, w.Code, w.Body.String())
+		}
+	}
+
+	for _, eventType := range []string{bus.ProjectCreated, bus.ProjectUpdated} {
+		event := <-events
+		if event.Type != eventType {
+			t.Fatalf("Expected %s, got %+v", eventType, event)
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "secret") {
+			t.Errorf("Expected no webhook secret in %s, got %s", eventType, data)
+		}
+		if !strings.Contains(string(data), `"webhook":true`) {
+			t.Errorf("Expected the webhook to be reported in %s, got %s", eventType, data)
+		}
+	}
+}
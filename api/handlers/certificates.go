@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/diiyw/ed/ssh"
+	"github.com/gin-gonic/gin"
+)
+
+// Certificate states reported by GetCertificate
+const (
+	CertificateValid       = "valid"
+	CertificateExpired     = "expired"
+	CertificateNotYetValid = "not_yet_valid"
+)
+
+// CertificateStatus describes the user certificate of an SSH configuration
+type CertificateStatus struct {
+	ssh.CertificateInfo
+	File   string `json:"file"`
+	Status string `json:"status"`
+}
+
+// certificateStatus reports whether a certificate is valid at t
+func certificateStatus(info ssh.CertificateInfo, t time.Time) string {
+	if info.ValidAfter != nil && t.Before(*info.ValidAfter) {
+		return CertificateNotYetValid
+	}
+	if info.ValidBefore != nil && !t.Before(*info.ValidBefore) {
+		return CertificateExpired
+	}
+	return CertificateValid
+}
+
+// GetCertificate describes the user certificate of a server using certificate
+// auth, with its principals and validity window
+func (h *SSHHandler) GetCertificate(c *gin.Context) {
+	i := h.findSSHConfig(c.Param("name"))
+	if i < 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "SSH configuration not found",
+		})
+		return
+	}
+
+	cfg := deploySSHConfig(h.config.SSHConfigs[i])
+	if !cfg.UsesAuth(deploy.AuthCertificate) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "SSH configuration does not use certificate auth",
+		})
+		return
+	}
+
+	file := cfg.CertificateFile()
+	cert, err := ssh.LoadCertificate(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to load certificate: %v", err),
+		})
+		return
+	}
+
+	info := ssh.CertInfo(cert)
+	c.JSON(http.StatusOK, gin.H{
+		"data": CertificateStatus{
+			CertificateInfo: info,
+			File:            file,
+			Status:          certificateStatus(info, time.Now()),
+		},
+	})
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/diiyw/ed/ssh"
+	"github.com/gin-gonic/gin"
+	xssh "golang.org/x/crypto/ssh"
+)
+
+// Unit Tests for Certificates
+
+// newTestCA returns a signer to sign test certificates with
+func newTestCA(t *testing.T) xssh.Signer {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := xssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// writeTestCertificate writes a key pair and its user certificate, signed by
+// ca, and returns the private key file
+func writeTestCertificate(t *testing.T, ca xssh.Signer, validAfter time.Time, validBefore time.Time) string {
+	t.Helper()
+
+	privateKey, authorizedKey, err := ssh.GenerateKey("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := writeDeployKey(keyFile, privateKey, authorizedKey); err != nil {
+		t.Fatal(err)
+	}
+
+	key, _, _, _, err := xssh.ParseAuthorizedKey(authorizedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := &xssh.Certificate{
+		Key:             key,
+		Serial:          42,
+		CertType:        xssh.UserCert,
+		KeyId:           "deploy@ci",
+		ValidPrincipals: []string{"deploy", "root"},
+		ValidAfter:      uint64(validAfter.Unix()),
+		ValidBefore:     uint64(validBefore.Unix()),
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile+"-cert.pub", xssh.MarshalAuthorizedKey(cert), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return keyFile
+}
+
+func getCertificate(router *gin.Engine, name string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/ssh/"+name+"/certificate", nil))
+	return w
+}
+
+func TestGetCertificate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ca := newTestCA(t)
+	now := time.Now().Truncate(time.Second)
+	config := &Config{
+		SSHConfigs: []SSHConfig{
+			{Name: "valid", Host: "10.0.0.1", AuthType: "certificate", KeyFile: writeTestCertificate(t, ca, now.Add(-time.Hour), now.Add(time.Hour))},
+			{Name: "expired", Host: "10.0.0.2", AuthType: "certificate", KeyFile: writeTestCertificate(t, ca, now.Add(-2*time.Hour), now.Add(-time.Hour))},
+			{Name: "future", Host: "10.0.0.3", AuthMethods: []string{"password", "certificate"}, KeyFile: writeTestCertificate(t, ca, now.Add(time.Hour), now.Add(2*time.Hour))},
+		},
+	}
+	handler := NewSSHHandler(config)
+	router := gin.New()
+	router.GET("/api/ssh/:name/certificate", handler.GetCertificate)
+
+	tests := []struct {
+		name        string
+		status      string
+		validBefore time.Time
+	}{
+		{"valid", CertificateValid, now.Add(time.Hour)},
+		{"expired", CertificateExpired, now.Add(-time.Hour)},
+		{"future", CertificateNotYetValid, now.Add(2 * time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := getCertificate(router, tt.name)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			var response struct {
+				Data CertificateStatus `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to parse response: %v", err)
+			}
+			cert := response.Data
+			if cert.Status != tt.status {
+				t.Errorf("Expected status %s, got %s", tt.status, cert.Status)
+			}
+			if cert.Type != "user" || cert.KeyID != "deploy@ci" || cert.Serial != 42 || len(cert.Principals) != 2 || cert.Authority != ssh.Fingerprint(ca.PublicKey()) {
+				t.Errorf("Unexpected certificate %+v", cert)
+			}
+			if cert.ValidAfter == nil || cert.ValidBefore == nil || !cert.ValidBefore.Equal(tt.validBefore) {
+				t.Errorf("Unexpected validity window %v - %v", cert.ValidAfter, cert.ValidBefore)
+			}
+		})
+	}
+}
+
+func TestGetCertificate_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{
+		SSHConfigs: []SSHConfig{
+			{Name: "password", Host: "10.0.0.1", AuthType: "password", Password: "secret"},
+			{Name: "missing-cert", Host: "10.0.0.2", AuthType: "certificate", KeyFile: filepath.Join(t.TempDir(), "id_ed25519")},
+		},
+	}
+	handler := NewSSHHandler(config)
+	router := gin.New()
+	router.GET("/api/ssh/:name/certificate", handler.GetCertificate)
+
+	tests := []struct {
+		name string
+		code int
+	}{
+		{"password", http.StatusBadRequest},
+		{"missing-cert", http.StatusBadRequest},
+		{"missing", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		if w := getCertificate(router, tt.name); w.Code != tt.code {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.code, w.Code)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+type DeploymentHandler struct {
+	engine *deploy.Engine
+}
+
+func NewDeploymentHandler(engine *deploy.Engine) *DeploymentHandler {
+	return &DeploymentHandler{engine: engine}
+}
+
+// approvalRequest is the body of approve, reject, promote and abort requests
+type approvalRequest struct {
+	User    string `json:"user" binding:"required"`
+	Comment string `json:"comment"`
+}
+
+// GetAll returns the deployment history, optionally filtered by project
+func (h *DeploymentHandler) GetAll(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"deployments": h.engine.ListDeployments(c.Query("project")),
+		},
+	})
+}
+
+// GetByID returns a single deployment
+func (h *DeploymentHandler) GetByID(c *gin.Context) {
+	status, exists := h.engine.GetStatus(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Deployment not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"deployment": status,
+		},
+	})
+}
+
+// Approve approves a deployment that is awaiting approval
+func (h *DeploymentHandler) Approve(c *gin.Context) {
+	h.decide(c, h.engine.Approve, "Deployment approved successfully")
+}
+
+// Reject rejects a deployment that is awaiting approval
+func (h *DeploymentHandler) Reject(c *gin.Context) {
+	h.decide(c, h.engine.Reject, "Deployment rejected successfully")
+}
+
+// Promote promotes a paused canary deployment to the rest of its servers
+func (h *DeploymentHandler) Promote(c *gin.Context) {
+	h.decide(c, func(id, user, _ string) error {
+		return h.engine.Promote(id, user)
+	}, "Deployment promoted successfully")
+}
+
+// Abort aborts a paused canary deployment and rolls back its canary servers
+func (h *DeploymentHandler) Abort(c *gin.Context) {
+	h.decide(c, func(id, user, _ string) error {
+		return h.engine.Abort(id, user)
+	}, "Deployment aborted successfully")
+}
+
+// decide applies an approval or canary decision to the deployment in the request path
+func (h *DeploymentHandler) decide(c *gin.Context, apply func(deploymentID, user, comment string) error, message string) {
+	id := c.Param("id")
+
+	var req approvalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	if _, exists := h.engine.GetStatus(id); !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Deployment not found",
+		})
+		return
+	}
+
+	if err := apply(id, req.User, req.Comment); err != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	status, _ := h.engine.GetStatus(id)
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"deployment": status,
+		},
+		"message": message,
+	})
+}
+
+// variableNamePattern matches valid shell variable names
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// findEnvironment returns the environment of a project with the given name
+func findEnvironment(project *Project, name string) *Environment {
+	for i := range project.Environments {
+		if project.Environments[i].Name == name {
+			return &project.Environments[i]
+		}
+	}
+	return nil
+}
+
+// startDeployment validates a project, or one of its environments if env is not nil,
+// and queues its deployment. Deploys inside a freeze window are rejected
+// unless overridden, see checkFreeze. If an approval is required the deployment
+// is registered for approval instead. An empty version defaults to the trigger commit.
+// It returns the ID and initial status of the new deployment.
+func startDeployment(engine *deploy.Engine, config *Config, project *Project, env *Environment, version string, trigger *deploy.Trigger) (string, string, error) {
+	deployProject := toDeployProject(project)
+	approval := project.Approval
+	canary := project.Canary
+	tty := project.TTY
+
+	if env != nil {
+		for name := range env.Variables {
+			if !variableNamePattern.MatchString(name) {
+				return "", "", fmt.Errorf("Invalid variable name '%s' in environment '%s'", name, env.Name)
+			}
+		}
+
+		deployProject.DeployServers = append([]string(nil), env.DeployServers...)
+		deployProject.Environment = env.Name
+		deployProject.Variables = env.Variables
+		if env.Approval != nil {
+			approval = env.Approval
+		}
+		if env.Canary != nil {
+			canary = env.Canary
+		}
+		if env.TTY != nil {
+			tty = env.TTY
+		}
+	}
+
+	if tty != nil {
+		deployProject.TTY = &deploy.TTY{
+			Term:     tty.Term,
+			Cols:     tty.Cols,
+			Rows:     tty.Rows,
+			Commands: tty.Commands,
+		}
+	}
+
+	if err := checkFreeze(config, project, env, trigger); err != nil {
+		return "", "", err
+	}
+
+	if version == "" && trigger != nil {
+		version = trigger.Commit
+	}
+	deployProject.Version = version
+
+	serverNames, sshConfigs, err := resolveSSHConfigs(config, deployProject.DeployServers)
+	if err != nil {
+		return "", "", err
+	}
+	deployProject.DeployServers = serverNames
+
+	if canary != nil {
+		canaryServers, err := selectCanaryServers(config, canary, serverNames)
+		if err != nil {
+			return "", "", err
+		}
+
+		deployProject.Canary = &deploy.Canary{
+			Servers:        canaryServers,
+			BakeTime:       time.Duration(canary.BakeTime) * time.Minute,
+			HealthCheck:    canary.HealthCheck,
+			RollbackScript: canary.RollbackScript,
+		}
+		if live, exists := engine.LastSuccessful(project.Name, deployProject.Environment); exists {
+			deployProject.Canary.RollbackVersion = live.Version
+		}
+	}
+
+	deploymentID := fmt.Sprintf("%s-%d", project.Name, time.Now().UnixNano())
+
+	if approval != nil && approval.Required {
+		timeout := time.Duration(approval.Timeout) * time.Minute
+		engine.RequestApproval(deploymentID, deployProject, sshConfigs, trigger, timeout)
+		return deploymentID, "awaiting_approval", nil
+	}
+
+	engine.Enqueue(deploymentID, deployProject, sshConfigs, trigger)
+	return deploymentID, "queued", nil
+}
+
+// deploymentMessage returns the response message for a newly created deployment
+func deploymentMessage(status string) string {
+	if status == "awaiting_approval" {
+		return "Deployment awaiting approval"
+	}
+	return "Deployment queued successfully"
+}
+
+// toDeploySSHConfig converts an SSH config to the deployment engine type, with
+// its chain of jump hosts resolved from config
+func toDeploySSHConfig(config *Config, cfg SSHConfig) (*deploy.SSHConfig, error) {
+	result := deploySSHConfig(cfg)
+
+	seen := map[string]bool{cfg.Name: true}
+	last := result
+	for name := cfg.Jump; name != ""; {
+		if seen[name] {
+			return nil, fmt.Errorf("Jump host cycle through '%s'", name)
+		}
+		seen[name] = true
+
+		var hop *SSHConfig
+		for i := range config.SSHConfigs {
+			if config.SSHConfigs[i].Name == name {
+				hop = &config.SSHConfigs[i]
+				break
+			}
+		}
+		if hop == nil {
+			return nil, fmt.Errorf("Jump host '%s' not found in SSH configurations", name)
+		}
+
+		last.Jump = deploySSHConfig(*hop)
+		last = last.Jump
+		name = hop.Jump
+	}
+	return result, nil
+}
+
+// deploySSHConfig converts a single SSH config to the deployment engine type
+func deploySSHConfig(cfg SSHConfig) *deploy.SSHConfig {
+	return &deploy.SSHConfig{
+		Name:     cfg.Name,
+		Host:     cfg.Host,
+		Port:     cfg.Port,
+		User:     cfg.User,
+		AuthType: cfg.AuthType,
+		Password: cfg.Password,
+		KeyFile:  cfg.KeyFile,
+		KeyPass:  cfg.KeyPass,
+		Sudo:     cfg.Sudo,
+
+		PrivateKey:  cfg.PrivateKey,
+		CertFile:    cfg.CertFile,
+		AuthMethods: cfg.AuthMethods,
+	}
+}
+
+// toDeployProject converts a project to the deployment engine type
+func toDeployProject(project *Project) *deploy.Project {
+	return &deploy.Project{
+		Name:              project.Name,
+		BuildInstructions: project.BuildInstructions,
+		DeployScript:      project.DeployScript,
+		DeployServers:     append([]string(nil), project.DeployServers...),
+		Steps:             project.Steps,
+		Templates:         project.Templates,
+		Handlers:          project.Handlers,
+		CreatedAt:         project.CreatedAt,
+		UpdatedAt:         project.UpdatedAt,
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// Unit Tests for Deployment API Handlers
+
+func newDeploymentTestRouter(engine *deploy.Engine) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewDeploymentHandler(engine)
+	router := gin.New()
+	router.GET("/api/deployments", handler.GetAll)
+	router.GET("/api/deployments/:id", handler.GetByID)
+	router.POST("/api/deployments/:id/approve", handler.Approve)
+	router.POST("/api/deployments/:id/reject", handler.Reject)
+	router.POST("/api/deployments/:id/promote", handler.Promote)
+	router.POST("/api/deployments/:id/abort", handler.Abort)
+	return router
+}
+
+func TestDeployProject_RequiresApproval(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{
+		Projects: []Project{
+			{
+				Name:          "project1",
+				DeployServers: []string{},
+				Approval:      &ApprovalConfig{Required: true, Timeout: 30},
+				CreatedAt:     time.Now(),
+				UpdatedAt:     time.Now(),
+			},
+		},
+	}
+
+	engine := deploy.NewEngine()
+	handler := NewProjectHandler(config, engine)
+	router := gin.New()
+	router.POST("/api/projects/:name/deploy", handler.Deploy)
+
+	req := httptest.NewRequest("POST", "/api/projects/project1/deploy", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	data := response["data"].(map[string]interface{})
+	if data["status"] != "awaiting_approval" {
+		t.Errorf("Expected status 'awaiting_approval', got %v", data["status"])
+	}
+
+	status, exists := engine.GetStatus(data["deploymentId"].(string))
+	if !exists {
+		t.Fatal("Deployment not found in engine")
+	}
+	if status.Status != "awaiting_approval" {
+		t.Errorf("Expected engine status 'awaiting_approval', got %s", status.Status)
+	}
+	if status.Approval.ExpiresAt.Sub(status.Approval.RequestedAt) != 30*time.Minute {
+		t.Errorf("Expected 30 minute approval timeout, got %v", status.Approval.ExpiresAt.Sub(status.Approval.RequestedAt))
+	}
+}
+
+func TestApproveDeployment(t *testing.T) {
+	engine := deploy.NewEngine()
+	engine.RequestApproval("project1-1", &deploy.Project{Name: "project1"}, map[string]*deploy.SSHConfig{}, nil, time.Minute)
+	router := newDeploymentTestRouter(engine)
+
+	body := `{"user":"alice","comment":"looks good"}`
+	req := httptest.NewRequest("POST", "/api/deployments/project1-1/approve", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	status, _ := engine.GetStatus("project1-1")
+	if status.Approval.Decision != "approved" || status.Approval.DecidedBy != "alice" {
+		t.Errorf("Expected approval by alice, got %+v", status.Approval)
+	}
+
+	// A second decision conflicts with the first one
+	req = httptest.NewRequest("POST", "/api/deployments/project1-1/reject", bytes.NewBufferString(`{"user":"bob"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+}
+
+func TestRejectDeployment(t *testing.T) {
+	engine := deploy.NewEngine()
+	engine.RequestApproval("project1-1", &deploy.Project{Name: "project1"}, map[string]*deploy.SSHConfig{}, nil, time.Minute)
+	router := newDeploymentTestRouter(engine)
+
+	req := httptest.NewRequest("POST", "/api/deployments/project1-1/reject", bytes.NewBufferString(`{"user":"bob"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	status, _ := engine.GetStatus("project1-1")
+	if status.Status != "rejected" {
+		t.Errorf("Expected status 'rejected', got %s", status.Status)
+	}
+}
+
+func TestApproveDeployment_Errors(t *testing.T) {
+	engine := deploy.NewEngine()
+	engine.RequestApproval("project1-1", &deploy.Project{Name: "project1"}, map[string]*deploy.SSHConfig{}, nil, time.Minute)
+	router := newDeploymentTestRouter(engine)
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expectedStatus int
+	}{
+		{"missing user", "/api/deployments/project1-1/approve", `{}`, http.StatusBadRequest},
+		{"invalid JSON", "/api/deployments/project1-1/approve", `invalid`, http.StatusBadRequest},
+		{"unknown deployment", "/api/deployments/nonexistent/approve", `{"user":"alice"}`, http.StatusNotFound},
+		{"promote without canary", "/api/deployments/project1-1/promote", `{"user":"alice"}`, http.StatusConflict},
+		{"abort without canary", "/api/deployments/project1-1/abort", `{"user":"alice"}`, http.StatusConflict},
+		{"promote unknown deployment", "/api/deployments/nonexistent/promote", `{"user":"alice"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestGetDeployments(t *testing.T) {
+	engine := deploy.NewEngine()
+	engine.RequestApproval("project1-1", &deploy.Project{Name: "project1"}, map[string]*deploy.SSHConfig{}, nil, time.Minute)
+	engine.RequestApproval("project2-1", &deploy.Project{Name: "project2"}, map[string]*deploy.SSHConfig{}, nil, time.Minute)
+	router := newDeploymentTestRouter(engine)
+
+	req := httptest.NewRequest("GET", "/api/deployments?project=project1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	deployments := response["data"].(map[string]interface{})["deployments"].([]interface{})
+	if len(deployments) != 1 {
+		t.Fatalf("Expected 1 deployment, got %d", len(deployments))
+	}
+	deployment := deployments[0].(map[string]interface{})
+	if _, ok := deployment["approval"]; !ok {
+		t.Error("Expected approval in deployment history")
+	}
+
+	req = httptest.NewRequest("GET", "/api/deployments/nonexistent", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// environmentDeployRequest is the optional body of environment deploy requests
+type environmentDeployRequest struct {
+	overrideRequest
+	Version string `json:"version"`
+}
+
+// GetEnvironments returns the environments of a project with the version live in each
+func (h *ProjectHandler) GetEnvironments(c *gin.Context) {
+	project := h.findProject(c.Param("name"))
+	if project == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Project not found",
+		})
+		return
+	}
+
+	environments := make([]gin.H, 0, len(project.Environments))
+	for _, env := range project.Environments {
+		var live *deploy.DeploymentStatus
+		if status, exists := h.engine.LastSuccessful(project.Name, env.Name); exists {
+			live = status
+		}
+		environments = append(environments, gin.H{
+			"environment": env,
+			"live":        live,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"environments": environments,
+		},
+	})
+}
+
+// DeployEnvironment initiates deployment of a project environment
+func (h *ProjectHandler) DeployEnvironment(c *gin.Context) {
+	project, env := h.findEnvironment(c)
+	if env == nil {
+		return
+	}
+
+	var req environmentDeployRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid request: %v", err),
+			})
+			return
+		}
+	}
+
+	trigger := &deploy.Trigger{Source: "manual"}
+	req.apply(trigger)
+
+	deploymentID, status, err := startDeployment(h.engine, h.config, project, env, req.Version, trigger)
+	if err != nil {
+		respondDeployError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"deploymentId": deploymentID,
+			"status":       status,
+			"environment":  env.Name,
+			"version":      req.Version,
+		},
+		"message": deploymentMessage(status),
+	})
+}
+
+// Promote deploys the version last deployed successfully to an environment
+// into the next environment of the project
+func (h *ProjectHandler) Promote(c *gin.Context) {
+	project, env := h.findEnvironment(c)
+	if env == nil {
+		return
+	}
+
+	var req overrideRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid request: %v", err),
+			})
+			return
+		}
+	}
+
+	var next *Environment
+	for i := range project.Environments {
+		if project.Environments[i].Name == env.Name && i+1 < len(project.Environments) {
+			next = &project.Environments[i+1]
+			break
+		}
+	}
+	if next == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Environment '%s' has no next environment to promote to", env.Name),
+		})
+		return
+	}
+
+	live, exists := h.engine.LastSuccessful(project.Name, env.Name)
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Environment '%s' has no successful deployment to promote", env.Name),
+		})
+		return
+	}
+
+	trigger := &deploy.Trigger{
+		Source: "promotion",
+		From:   live.ID,
+	}
+	if live.Trigger != nil {
+		trigger.Ref = live.Trigger.Ref
+		trigger.Commit = live.Trigger.Commit
+	}
+	req.apply(trigger)
+
+	deploymentID, status, err := startDeployment(h.engine, h.config, project, next, live.Version, trigger)
+	if err != nil {
+		respondDeployError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"deploymentId": deploymentID,
+			"status":       status,
+			"environment":  next.Name,
+			"version":      live.Version,
+			"promotedFrom": live.ID,
+		},
+		"message": deploymentMessage(status),
+	})
+}
+
+// findProject returns the project with the given name
+func (h *ProjectHandler) findProject(name string) *Project {
+	for i := range h.config.Projects {
+		if h.config.Projects[i].Name == name {
+			return &h.config.Projects[i]
+		}
+	}
+	return nil
+}
+
+// findEnvironment returns the project and environment in the request path.
+// It writes a not found response and returns a nil environment if either is missing.
+func (h *ProjectHandler) findEnvironment(c *gin.Context) (*Project, *Environment) {
+	project := h.findProject(c.Param("name"))
+	if project == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Project not found",
+		})
+		return nil, nil
+	}
+
+	env := findEnvironment(project, c.Param("env"))
+	if env == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Environment not found",
+		})
+		return nil, nil
+	}
+
+	return project, env
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// Unit Tests for Environment API Handlers
+
+func newEnvironmentTestRouter() (*gin.Engine, *deploy.Engine, *Config) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{
+		SSHConfigs: []SSHConfig{
+			{Name: "server1", Host: "host1.com", Port: 22, User: "user1", AuthType: "password"},
+		},
+		Projects: []Project{
+			{
+				Name:          "project1",
+				DeployServers: []string{},
+				Environments: []Environment{
+					{Name: "staging", DeployServers: []string{}, Variables: map[string]string{"LOG_LEVEL": "debug"}},
+					{Name: "prod", DeployServers: []string{}, Approval: &ApprovalConfig{Required: true}},
+				},
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			},
+		},
+	}
+
+	engine := deploy.NewEngine()
+	handler := NewProjectHandler(config, engine)
+	router := gin.New()
+	router.GET("/api/projects/:name/environments", handler.GetEnvironments)
+	router.POST("/api/projects/:name/environments/:env/deploy", handler.DeployEnvironment)
+	router.POST("/api/projects/:name/environments/:env/promote", handler.Promote)
+	return router, engine, config
+}
+
+func postJSON(router *gin.Engine, path string, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	var req *http.Request
+	if body == "" {
+		req = httptest.NewRequest("POST", path, nil)
+	} else {
+		req = httptest.NewRequest("POST", path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func waitForStatus(t *testing.T, engine *deploy.Engine, deploymentID string, expected string) *deploy.DeploymentStatus {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		if status, exists := engine.GetStatus(deploymentID); exists && status.Status == expected {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Deployment %s did not reach status '%s'", deploymentID, expected)
+	return nil
+}
+
+func TestDeployEnvironment(t *testing.T) {
+	router, engine, _ := newEnvironmentTestRouter()
+
+	w, response := postJSON(router, "/api/projects/project1/environments/staging/deploy", `{"version":"v1.2.0"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	data := response["data"].(map[string]interface{})
+	if data["environment"] != "staging" {
+		t.Errorf("Expected environment 'staging', got %v", data["environment"])
+	}
+
+	status := waitForStatus(t, engine, data["deploymentId"].(string), "success")
+	if status.Environment != "staging" {
+		t.Errorf("Expected deployment environment 'staging', got %s", status.Environment)
+	}
+	if status.Version != "v1.2.0" {
+		t.Errorf("Expected deployment version 'v1.2.0', got %s", status.Version)
+	}
+}
+
+func TestDeployEnvironment_Approval(t *testing.T) {
+	router, _, _ := newEnvironmentTestRouter()
+
+	w, response := postJSON(router, "/api/projects/project1/environments/prod/deploy", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	data := response["data"].(map[string]interface{})
+	if data["status"] != "awaiting_approval" {
+		t.Errorf("Expected status 'awaiting_approval', got %v", data["status"])
+	}
+}
+
+func TestDeployEnvironment_Errors(t *testing.T) {
+	router, _, config := newEnvironmentTestRouter()
+	config.Projects[0].Environments = append(config.Projects[0].Environments,
+		Environment{Name: "broken", DeployServers: []string{"nonexistent"}},
+		Environment{Name: "badvars", DeployServers: []string{}, Variables: map[string]string{"BAD NAME": "x"}},
+	)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+	}{
+		{"unknown project", "/api/projects/nonexistent/environments/staging/deploy", http.StatusNotFound},
+		{"unknown environment", "/api/projects/project1/environments/qa/deploy", http.StatusNotFound},
+		{"unknown server", "/api/projects/project1/environments/broken/deploy", http.StatusBadRequest},
+		{"invalid variable name", "/api/projects/project1/environments/badvars/deploy", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, response := postJSON(router, tt.path, "")
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if _, ok := response["error"]; !ok {
+				t.Error("Response missing 'error' field")
+			}
+		})
+	}
+}
+
+func TestPromote(t *testing.T) {
+	router, engine, _ := newEnvironmentTestRouter()
+
+	// Nothing to promote before staging has a successful deployment
+	w, _ := postJSON(router, "/api/projects/project1/environments/staging/promote", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	_, response := postJSON(router, "/api/projects/project1/environments/staging/deploy", `{"version":"v1.2.0"}`)
+	stagingID := response["data"].(map[string]interface{})["deploymentId"].(string)
+	waitForStatus(t, engine, stagingID, "success")
+
+	w, response = postJSON(router, "/api/projects/project1/environments/staging/promote", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	data := response["data"].(map[string]interface{})
+	if data["environment"] != "prod" {
+		t.Errorf("Expected environment 'prod', got %v", data["environment"])
+	}
+	if data["version"] != "v1.2.0" {
+		t.Errorf("Expected version 'v1.2.0', got %v", data["version"])
+	}
+	if data["promotedFrom"] != stagingID {
+		t.Errorf("Expected promotedFrom '%s', got %v", stagingID, data["promotedFrom"])
+	}
+
+	status, _ := engine.GetStatus(data["deploymentId"].(string))
+	if status.Trigger == nil || status.Trigger.Source != "promotion" || status.Trigger.From != stagingID {
+		t.Errorf("Expected promotion trigger from %s, got %+v", stagingID, status.Trigger)
+	}
+
+	// The last environment cannot be promoted further
+	w, _ = postJSON(router, "/api/projects/project1/environments/prod/promote", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestGetEnvironments(t *testing.T) {
+	router, engine, _ := newEnvironmentTestRouter()
+
+	_, response := postJSON(router, "/api/projects/project1/environments/staging/deploy", `{"version":"v1.2.0"}`)
+	waitForStatus(t, engine, response["data"].(map[string]interface{})["deploymentId"].(string), "success")
+
+	req := httptest.NewRequest("GET", "/api/projects/project1/environments", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	environments := response["data"].(map[string]interface{})["environments"].([]interface{})
+	if len(environments) != 2 {
+		t.Fatalf("Expected 2 environments, got %d", len(environments))
+	}
+
+	staging := environments[0].(map[string]interface{})
+	live, ok := staging["live"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Expected live deployment for staging")
+	}
+	if live["version"] != "v1.2.0" {
+		t.Errorf("Expected live version 'v1.2.0', got %v", live["version"])
+	}
+
+	prod := environments[1].(map[string]interface{})
+	if prod["live"] != nil {
+		t.Errorf("Expected no live deployment for prod, got %v", prod["live"])
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// SSEHeartbeat is how often an idle event stream sends a comment to keep proxies from closing it
+var SSEHeartbeat = 15 * time.Second
+
+// Events streams the events of a deployment as Server-Sent Events until the
+// deployment finishes or the client disconnects. A Last-Event-ID header resumes
+// the stream after the event with that sequence number.
+func (h *DeploymentHandler) Events(c *gin.Context) {
+	deploymentID := c.Param("id")
+	if _, exists := h.engine.GetStatus(deploymentID); !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Deployment not found",
+		})
+		return
+	}
+
+	var after uint64
+	if lastID := c.GetHeader("Last-Event-ID"); lastID != "" {
+		seq, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Invalid Last-Event-ID: %s", lastID),
+			})
+			return
+		}
+		after = seq
+	}
+
+	backlog, events, cancel := h.engine.SubscribeAfter(deploymentID, after)
+	defer cancel()
+
+	startSSE(c)
+	for _, event := range backlog {
+		if err := writeSSE(c, event); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(SSEHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeSSE(c, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+		c.Writer.Flush()
+	}
+}
+
+// startSSE writes the headers of an event stream response
+func startSSE(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+}
+
+// writeSSE writes a deployment event with its sequence number as the event ID
+func writeSSE(c *gin.Context, event deploy.Event) error {
+	return sse.Encode(c.Writer, sse.Event{
+		Id:   strconv.FormatUint(event.Seq, 10),
+		Data: event,
+	})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// Unit Tests for Deployment Event Stream
+
+func newEventsTestRouter(engine *deploy.Engine) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewDeploymentHandler(engine)
+	router := gin.New()
+	router.GET("/api/deployments/:id/events", handler.Events)
+	return router
+}
+
+func TestDeploymentEvents(t *testing.T) {
+	engine := deploy.NewEngine()
+	engine.Enqueue("deploy-1", &deploy.Project{Name: "project1", BuildInstructions: "step 1"}, nil, nil)
+	waitForStatus(t, engine, "deploy-1", "success")
+
+	router := newEventsTestRouter(engine)
+
+	req := httptest.NewRequest("GET", "/api/deployments/deploy-1/events", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected text/event-stream, got %s", ct)
+	}
+	body := w.Body.String()
+	if !strings.HasPrefix(body, "id:1\n") || !strings.Contains(body, "Deployment completed successfully") {
+		t.Errorf("Expected the full event history, got %s", body)
+	}
+
+	// Resume after the first two events
+	req = httptest.NewRequest("GET", "/api/deployments/deploy-1/events", nil)
+	req.Header.Set("Last-Event-ID", "2")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if !strings.HasPrefix(w.Body.String(), "id:3\n") {
+		t.Errorf("Expected stream to resume at event 3, got %s", w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/deployments/deploy-1/events", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/deployments/nonexistent/events", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestDeploymentEvents_Heartbeat(t *testing.T) {
+	heartbeat := SSEHeartbeat
+	SSEHeartbeat = 10 * time.Millisecond
+	defer func() { SSEHeartbeat = heartbeat }()
+
+	// A deployment awaiting approval stays open without events
+	engine := deploy.NewEngine()
+	engine.RequestApproval("deploy-1", &deploy.Project{Name: "project1"}, nil, nil, 0)
+
+	server := httptest.NewServer(newEventsTestRouter(engine))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/deployments/deploy-1/events")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Expected a heartbeat, got error %v", err)
+		}
+		if line == ": heartbeat\n" {
+			break
+		}
+	}
+}
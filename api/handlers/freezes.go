@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// weekdays maps the day names of recurring freeze windows
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// FreezeError is returned when a deploy is blocked by a freeze window
+type FreezeError struct {
+	Scope  string // "global", "project" or "environment"
+	Window FreezeWindow
+}
+
+func (e *FreezeError) Error() string {
+	return fmt.Sprintf("Deploys are frozen by %s freeze window '%s': %s", e.Scope, e.Window.Name, e.Window.Reason)
+}
+
+// errOverrideDenied is returned when a user without the override permission forces a deploy
+var errOverrideDenied = errors.New("User is not allowed to override freeze windows")
+
+// overrideRequest is the optional part of deploy requests that forces a deploy
+// during a freeze. The API has no login, so the user proves the override
+// permission with its token from freeze_override_tokens.
+type overrideRequest struct {
+	Force  bool   `json:"force"`
+	User   string `json:"user"`
+	Token  string `json:"token"`
+	Reason string `json:"reason"`
+}
+
+// apply records the override on the trigger of a forced deploy
+func (r overrideRequest) apply(trigger *deploy.Trigger) {
+	if r.Force {
+		trigger.Override = &deploy.FreezeOverride{User: r.User, Reason: r.Reason, Token: r.Token}
+	}
+}
+
+// overridePermitted reports whether an override is made by a listed override
+// user with its token
+func overridePermitted(config *Config, override *deploy.FreezeOverride) bool {
+	if override.User == "" || !slices.Contains(config.FreezeOverrideUsers, override.User) {
+		return false
+	}
+	token := config.FreezeOverrideTokens[override.User]
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(override.Token)) == 1
+}
+
+// validate checks that a freeze window is either a date range or a recurring window
+func (w FreezeWindow) validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("Freeze window name is required")
+	}
+
+	if w.Start != nil || w.End != nil {
+		if w.Start == nil || w.End == nil || !w.End.After(*w.Start) {
+			return fmt.Errorf("Freeze window '%s' requires a start before its end", w.Name)
+		}
+		if len(w.Days) > 0 || w.From != "" || w.To != "" {
+			return fmt.Errorf("Freeze window '%s' cannot be both a date range and recurring", w.Name)
+		}
+		return nil
+	}
+
+	for _, day := range w.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("Freeze window '%s' has an invalid day '%s'", w.Name, day)
+		}
+	}
+	for _, clock := range []string{w.From, w.To} {
+		if _, err := parseClock(clock); err != nil {
+			return fmt.Errorf("Freeze window '%s' has an invalid time '%s'", w.Name, clock)
+		}
+	}
+	if _, err := w.location(); err != nil {
+		return fmt.Errorf("Freeze window '%s' has an invalid timezone '%s'", w.Name, w.Timezone)
+	}
+	return nil
+}
+
+// activeAt reports whether the freeze window covers t. Recurring windows start
+// on their listed days, and those spanning midnight end on the following day.
+func (w FreezeWindow) activeAt(t time.Time) bool {
+	if w.Start != nil && w.End != nil {
+		return !t.Before(*w.Start) && t.Before(*w.End)
+	}
+
+	loc, err := w.location()
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+
+	from, _ := parseClock(w.From)
+	to, _ := parseClock(w.To)
+	if to == 0 {
+		to = 24 * time.Hour
+	}
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	if from < to {
+		return w.onDay(t.Weekday()) && clock >= from && clock < to
+	}
+	// The window spans midnight
+	return (w.onDay(t.Weekday()) && clock >= from) || (w.onDay((t.Weekday()+6)%7) && clock < to)
+}
+
+// location returns the timezone of a recurring freeze window
+func (w FreezeWindow) location() (*time.Location, error) {
+	if w.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(w.Timezone)
+}
+
+// onDay reports whether a recurring freeze window starts on a weekday
+func (w FreezeWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(w.Days, func(d string) bool {
+		return weekdays[strings.ToLower(d)] == day
+	})
+}
+
+// parseClock parses a "15:04" time of day, an empty string is midnight
+func parseClock(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// validateFreezeWindows validates a list of freeze windows
+func validateFreezeWindows(windows []FreezeWindow) error {
+	for _, w := range windows {
+		if err := w.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateProjectFreezes validates the freeze windows of a project and its environments
+func validateProjectFreezes(project *Project) error {
+	if err := validateFreezeWindows(project.Freezes); err != nil {
+		return err
+	}
+	for _, env := range project.Environments {
+		if err := validateFreezeWindows(env.Freezes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activeFreeze returns the first freeze window covering t that applies to a
+// project environment, checking global, project and then environment windows
+func activeFreeze(config *Config, project *Project, env *Environment, t time.Time) *FreezeError {
+	find := func(scope string, windows []FreezeWindow) *FreezeError {
+		for _, w := range windows {
+			if w.activeAt(t) {
+				return &FreezeError{Scope: scope, Window: w}
+			}
+		}
+		return nil
+	}
+
+	if freeze := find("global", config.Freezes); freeze != nil {
+		return freeze
+	}
+	if freeze := find("project", project.Freezes); freeze != nil {
+		return freeze
+	}
+	if env != nil {
+		return find("environment", env.Freezes)
+	}
+	return nil
+}
+
+// checkFreeze blocks deploys inside a freeze window unless the trigger carries an
+// override by a permitted user, which is then recorded with the window name.
+// Every deploy, whatever its trigger source, starts through startDeployment
+// and so passes this check.
+func checkFreeze(config *Config, project *Project, env *Environment, trigger *deploy.Trigger) error {
+	freeze := activeFreeze(config, project, env, time.Now())
+	if freeze == nil {
+		if trigger != nil {
+			trigger.Override = nil
+		}
+		return nil
+	}
+
+	if trigger == nil || trigger.Override == nil {
+		return freeze
+	}
+	permitted := overridePermitted(config, trigger.Override)
+	trigger.Override.Token = ""
+	if !permitted {
+		return errOverrideDenied
+	}
+
+	trigger.Override.Window = freeze.Window.Name
+	return nil
+}
+
+// respondDeployError writes the response for an error returned by startDeployment
+func respondDeployError(c *gin.Context, err error) {
+	var freeze *FreezeError
+	switch {
+	case errors.As(err, &freeze):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":  err.Error(),
+			"freeze": freeze.Window,
+		})
+	case errors.Is(err, errOverrideDenied):
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	}
+}
+
+type FreezeHandler struct {
+	config *Config
+}
+
+func NewFreezeHandler(config *Config) *FreezeHandler {
+	return &FreezeHandler{config: config}
+}
+
+// freezesRequest is the body of global freeze window updates
+type freezesRequest struct {
+	Freezes       []FreezeWindow `json:"freezes"`
+	OverrideUsers []string       `json:"override_users"`
+}
+
+// GetAll returns the global freeze windows and the users allowed to override them
+func (h *FreezeHandler) GetAll(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"freezes":        h.config.Freezes,
+			"override_users": h.config.FreezeOverrideUsers,
+		},
+	})
+}
+
+// Update replaces the global freeze windows and the users allowed to override
+// them. Override tokens are only read from config.json, a user listed here
+// without a token cannot override.
+func (h *FreezeHandler) Update(c *gin.Context) {
+	var req freezesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	if err := validateFreezeWindows(req.Freezes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	h.config.Freezes = req.Freezes
+	h.config.FreezeOverrideUsers = req.OverrideUsers
+	if err := SaveConfig("config.json", h.config); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to save configuration: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"freezes":        h.config.Freezes,
+			"override_users": h.config.FreezeOverrideUsers,
+		},
+		"message": "Freeze windows updated successfully",
+	})
+}
+
+// Check returns the freeze window currently blocking deploys of a project,
+// or of one of its environments with ?environment=
+func (h *FreezeHandler) Check(c *gin.Context) {
+	var project *Project
+	for i := range h.config.Projects {
+		if h.config.Projects[i].Name == c.Query("project") {
+			project = &h.config.Projects[i]
+			break
+		}
+	}
+	if project == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Project not found",
+		})
+		return
+	}
+
+	var env *Environment
+	if name := c.Query("environment"); name != "" {
+		if env = findEnvironment(project, name); env == nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Environment not found",
+			})
+			return
+		}
+	}
+
+	var active gin.H
+	if freeze := activeFreeze(h.config, project, env, time.Now()); freeze != nil {
+		active = gin.H{
+			"scope":  freeze.Scope,
+			"window": freeze.Window,
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"frozen": active != nil,
+			"freeze": active,
+		},
+	})
+}
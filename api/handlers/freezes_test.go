@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// Unit Tests for Freeze Windows
+
+func TestFreezeWindowActiveAt(t *testing.T) {
+	start := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 3, 8, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		window   FreezeWindow
+		at       time.Time
+		expected bool
+	}{
+		{"inside date range", FreezeWindow{Start: &start, End: &end}, time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC), true},
+		{"end of date range", FreezeWindow{Start: &start, End: &end}, end, false},
+		{"weekend on saturday", FreezeWindow{Days: []string{"sat", "sun"}, Timezone: "UTC"}, time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC), true},
+		{"weekend on monday", FreezeWindow{Days: []string{"Sat", "Sun"}, Timezone: "UTC"}, time.Date(2024, 3, 4, 9, 0, 0, 0, time.UTC), false},
+		{"daily window inside", FreezeWindow{From: "09:00", To: "17:00", Timezone: "UTC"}, time.Date(2024, 3, 4, 16, 59, 0, 0, time.UTC), true},
+		{"daily window after", FreezeWindow{From: "09:00", To: "17:00", Timezone: "UTC"}, time.Date(2024, 3, 4, 17, 0, 0, 0, time.UTC), false},
+		{"overnight window before midnight", FreezeWindow{Days: []string{"fri"}, From: "18:00", To: "06:00", Timezone: "UTC"}, time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC), true},
+		{"overnight window after midnight", FreezeWindow{Days: []string{"fri"}, From: "18:00", To: "06:00", Timezone: "UTC"}, time.Date(2024, 3, 2, 5, 0, 0, 0, time.UTC), true},
+		{"overnight window next morning", FreezeWindow{Days: []string{"fri"}, From: "18:00", To: "06:00", Timezone: "UTC"}, time.Date(2024, 3, 2, 7, 0, 0, 0, time.UTC), false},
+		{"timezone applied", FreezeWindow{Days: []string{"sat"}, Timezone: "Asia/Tokyo"}, time.Date(2024, 3, 1, 20, 0, 0, 0, time.UTC), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.activeAt(tt.at); got != tt.expected {
+				t.Errorf("Expected active=%v at %s, got %v", tt.expected, tt.at, got)
+			}
+		})
+	}
+}
+
+func TestFreezeWindowValidate(t *testing.T) {
+	start := time.Now()
+	end := start.Add(time.Hour)
+
+	valid := []FreezeWindow{
+		{Name: "release", Reason: "release freeze", Start: &start, End: &end},
+		{Name: "weekend", Days: []string{"sat", "sun"}},
+		{Name: "nights", From: "22:00", To: "06:00", Timezone: "Europe/Berlin"},
+	}
+	for _, w := range valid {
+		if err := w.validate(); err != nil {
+			t.Errorf("Expected window %s to be valid, got %v", w.Name, err)
+		}
+	}
+
+	invalid := []FreezeWindow{
+		{Days: []string{"sat"}},
+		{Name: "open range", Start: &start},
+		{Name: "reversed", Start: &end, End: &start},
+		{Name: "mixed", Start: &start, End: &end, Days: []string{"sat"}},
+		{Name: "bad day", Days: []string{"someday"}},
+		{Name: "bad time", From: "25:00"},
+		{Name: "bad timezone", Timezone: "Mars/Olympus"},
+	}
+	for _, w := range invalid {
+		if err := w.validate(); err == nil {
+			t.Errorf("Expected window %+v to be invalid", w)
+		}
+	}
+}
+
+func TestDeployProject_Frozen(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	start := time.Now().Add(-time.Hour)
+	end := time.Now().Add(time.Hour)
+	config := &Config{
+		Projects: []Project{
+			{Name: "project1", DeployServers: []string{}},
+		},
+		Freezes: []FreezeWindow{
+			{Name: "release", Reason: "Release 2.0 freeze", Start: &start, End: &end},
+		},
+		FreezeOverrideUsers:  []string{"alice", "carol"},
+		FreezeOverrideTokens: map[string]string{"alice": "alice-token"},
+	}
+
+	engine := deploy.NewEngine()
+	handler := NewProjectHandler(config, engine)
+	router := gin.New()
+	router.POST("/api/projects/:name/deploy", handler.Deploy)
+
+	deployWith := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/projects/project1/deploy", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := deployWith("")
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response["freeze"] == nil {
+		t.Error("Expected freeze window in response")
+	}
+
+	// Only permitted users with their token can override
+	for _, body := range []string{
+		`{"force":true,"user":"bob"}`,
+		`{"force":true,"user":"alice"}`,
+		`{"force":true,"user":"alice","token":"wrong"}`,
+		`{"force":true,"user":"carol","token":""}`,
+	} {
+		if w := deployWith(body); w.Code != http.StatusForbidden {
+			t.Fatalf("Expected status 403 for %s, got %d", body, w.Code)
+		}
+	}
+
+	w = deployWith(`{"force":true,"user":"alice","token":"alice-token","reason":"hotfix"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	json.Unmarshal(w.Body.Bytes(), &response)
+	deploymentID := response["data"].(map[string]interface{})["deploymentId"].(string)
+	status := waitForStatus(t, engine, deploymentID, "success")
+
+	override := status.Trigger.Override
+	if override == nil || override.User != "alice" || override.Reason != "hotfix" || override.Window != "release" || override.Token != "" {
+		t.Errorf("Expected override to be recorded without its token, got %+v", override)
+	}
+}
+
+func TestFreezeHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{
+		Projects: []Project{
+			{Name: "project1", Environments: []Environment{
+				{Name: "prod", Freezes: []FreezeWindow{{Name: "always", Reason: "prod is frozen"}}},
+			}},
+		},
+	}
+	handler := NewFreezeHandler(config)
+	router := gin.New()
+	router.PUT("/api/freezes", handler.Update)
+	router.GET("/api/freezes/check", handler.Check)
+
+	req := httptest.NewRequest("PUT", "/api/freezes", bytes.NewBufferString(`{"freezes":[{"name":"bad","days":["someday"]}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("PUT", "/api/freezes", bytes.NewBufferString(`{"freezes":[{"name":"weekend","reason":"No weekend deploys","days":["sat","sun"]}],"override_users":["alice"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if len(config.Freezes) != 1 || len(config.FreezeOverrideUsers) != 1 {
+		t.Errorf("Expected freezes to be saved, got %+v", config)
+	}
+
+	req = httptest.NewRequest("GET", "/api/freezes/check?project=project1&environment=prod", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["data"].(map[string]interface{})["frozen"] != true {
+		t.Errorf("Expected prod to be frozen, got %v", response)
+	}
+}
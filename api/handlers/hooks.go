@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// maxHookPayloadSize limits the size of accepted webhook payloads
+const maxHookPayloadSize = 5 << 20
+
+// signatureHeaders lists the HMAC-SHA256 signature headers of supported git servers.
+// X-Hub-Signature-256 carries a "sha256=" prefix, the others are plain hex digests.
+var signatureHeaders = []string{"X-Hub-Signature-256", "X-Gitea-Signature", "X-Gogs-Signature"}
+
+// eventHeaders lists the event type headers of supported git servers
+var eventHeaders = []string{"X-GitHub-Event", "X-Gitea-Event", "X-Gogs-Event"}
+
+// hookPayload holds the fields of a push payload used to describe a deployment
+type hookPayload struct {
+	Ref        string `json:"ref"`
+	After      string `json:"after"`
+	HeadCommit *struct {
+		ID string `json:"id"`
+	} `json:"head_commit"`
+}
+
+type HookHandler struct {
+	config *Config
+	engine *deploy.Engine
+}
+
+func NewHookHandler(config *Config, engine *deploy.Engine) *HookHandler {
+	return &HookHandler{config: config, engine: engine}
+}
+
+// Trigger starts a deployment from a signed webhook request
+func (h *HookHandler) Trigger(c *gin.Context) {
+	name := c.Param("project")
+
+	var project *Project
+	for _, proj := range h.config.Projects {
+		if proj.Name == name {
+			project = &proj
+			break
+		}
+	}
+
+	if project == nil || project.Webhook == nil || project.Webhook.Secret == "" {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Webhook not found",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxHookPayloadSize))
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": "Payload too large",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Failed to read payload: %v", err),
+		})
+		return
+	}
+
+	if !verifySignature(project.Webhook.Secret, body, c.Request.Header) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "Invalid signature",
+		})
+		return
+	}
+
+	event := firstHeader(c.Request.Header, eventHeaders)
+	if event == "ping" {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "pong",
+		})
+		return
+	}
+
+	payload, err := parseHookPayload(c.ContentType(), body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid payload: %v", err),
+		})
+		return
+	}
+
+	if reason := filterHook(project.Webhook, event, payload.Ref); reason != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"data": gin.H{
+				"skipped": true,
+				"reason":  reason,
+			},
+			"message": "Webhook ignored",
+		})
+		return
+	}
+
+	commit := payload.After
+	if commit == "" && payload.HeadCommit != nil {
+		commit = payload.HeadCommit.ID
+	}
+
+	trigger := &deploy.Trigger{
+		Source: "webhook",
+		Event:  event,
+		Ref:    payload.Ref,
+		Commit: commit,
+	}
+
+	var env *Environment
+	if project.Webhook.Environment != "" {
+		if env = findEnvironment(project, project.Webhook.Environment); env == nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("Environment '%s' not found", project.Webhook.Environment),
+			})
+			return
+		}
+	}
+
+	deploymentID, status, err := startDeployment(h.engine, h.config, project, env, "", trigger)
+	if err != nil {
+		respondDeployError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"deploymentId": deploymentID,
+			"status":       status,
+			"ref":          payload.Ref,
+			"commit":       commit,
+		},
+		"message": deploymentMessage(status),
+	})
+}
+
+// verifySignature checks the HMAC-SHA256 signature of a webhook payload
+func verifySignature(secret string, body []byte, header http.Header) bool {
+	signature := firstHeader(header, signatureHeaders)
+	signature = strings.TrimPrefix(signature, "sha256=")
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// parseHookPayload decodes a JSON or form encoded webhook payload
+func parseHookPayload(contentType string, body []byte) (*hookPayload, error) {
+	payload := &hookPayload{}
+
+	if contentType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		body = []byte(values.Get("payload"))
+	}
+
+	if len(body) == 0 {
+		return payload, nil
+	}
+
+	if err := json.Unmarshal(body, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// filterHook returns why a webhook event should not trigger a deployment,
+// or an empty string if it should.
+func filterHook(webhook *WebhookConfig, event string, ref string) string {
+	if len(webhook.Events) > 0 && !slices.Contains(webhook.Events, event) {
+		return fmt.Sprintf("event '%s' is not enabled", event)
+	}
+
+	if len(webhook.Branches) > 0 {
+		branch, ok := strings.CutPrefix(ref, "refs/heads/")
+		if !ok {
+			return fmt.Sprintf("ref '%s' is not a branch", ref)
+		}
+		for _, pattern := range webhook.Branches {
+			if matched, _ := path.Match(pattern, branch); matched {
+				return ""
+			}
+		}
+		return fmt.Sprintf("branch '%s' is not enabled", branch)
+	}
+
+	return ""
+}
+
+// firstHeader returns the first non-empty value of the given headers
+func firstHeader(header http.Header, names []string) string {
+	for _, name := range names {
+		if value := header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
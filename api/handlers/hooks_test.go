@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// Unit Tests for Webhook Trigger Handler
+
+const testPushPayload = `{"ref":"refs/heads/main","after":"0123456789abcdef","head_commit":{"id":"0123456789abcdef"}}`
+
+func newHookTestRouter(webhook *WebhookConfig) (*gin.Engine, *deploy.Engine) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{
+		Projects: []Project{
+			{
+				Name:              "project1",
+				BuildInstructions: "",
+				DeployScript:      "",
+				DeployServers:     []string{},
+				Webhook:           webhook,
+			},
+		},
+	}
+
+	engine := deploy.NewEngine()
+	handler := NewHookHandler(config, engine)
+	router := gin.New()
+	router.POST("/api/hooks/:project", handler.Trigger)
+	return router, engine
+}
+
+func signPayload(secret string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func sendHook(router *gin.Engine, path string, event string, signature string, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	req := httptest.NewRequest("POST", path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-GitHub-Event", event)
+	if signature != "" {
+		req.Header.Set("X-Hub-Signature-256", signature)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]interface{}
+	_ = json.Unmarshal(w.Body.Bytes(), &response)
+	return w, response
+}
+
+func TestHookTrigger_ValidSignature(t *testing.T) {
+	router, engine := newHookTestRouter(&WebhookConfig{Secret: "s3cret"})
+
+	w, response := sendHook(router, "/api/hooks/project1", "push", signPayload("s3cret", testPushPayload), testPushPayload)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+
+	data, ok := response["data"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Response missing 'data' field")
+	}
+
+	deploymentID, ok := data["deploymentId"].(string)
+	if !ok || deploymentID == "" {
+		t.Fatal("Response missing or empty 'deploymentId' field")
+	}
+	if data["ref"] != "refs/heads/main" {
+		t.Errorf("Expected ref 'refs/heads/main', got %v", data["ref"])
+	}
+	if data["commit"] != "0123456789abcdef" {
+		t.Errorf("Expected commit '0123456789abcdef', got %v", data["commit"])
+	}
+
+	// Wait for the deployment to be registered
+	var status *deploy.DeploymentStatus
+	for i := 0; i < 50; i++ {
+		if s, exists := engine.GetStatus(deploymentID); exists {
+			status = s
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status == nil {
+		t.Fatal("Deployment not found in engine")
+	}
+	if status.Trigger == nil || status.Trigger.Source != "webhook" {
+		t.Errorf("Expected webhook trigger, got %+v", status.Trigger)
+	}
+}
+
+func TestHookTrigger_GiteaSignature(t *testing.T) {
+	router, _ := newHookTestRouter(&WebhookConfig{Secret: "s3cret"})
+
+	req := httptest.NewRequest("POST", "/api/hooks/project1", bytes.NewBufferString(testPushPayload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gitea-Event", "push")
+	req.Header.Set("X-Gitea-Signature", signPayload("s3cret", testPushPayload)[len("sha256="):])
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHookTrigger_InvalidSignature(t *testing.T) {
+	router, _ := newHookTestRouter(&WebhookConfig{Secret: "s3cret"})
+
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{"missing signature", ""},
+		{"wrong secret", signPayload("other", testPushPayload)},
+		{"malformed signature", "sha256=not-hex"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, response := sendHook(router, "/api/hooks/project1", "push", tt.signature, testPushPayload)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("Expected status 401, got %d", w.Code)
+			}
+			if _, ok := response["error"]; !ok {
+				t.Error("Response missing 'error' field")
+			}
+		})
+	}
+}
+
+func TestHookTrigger_NotFound(t *testing.T) {
+	tests := []struct {
+		name    string
+		webhook *WebhookConfig
+		path    string
+	}{
+		{"unknown project", &WebhookConfig{Secret: "s3cret"}, "/api/hooks/nonexistent"},
+		{"webhook not configured", nil, "/api/hooks/project1"},
+		{"empty secret", &WebhookConfig{}, "/api/hooks/project1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := newHookTestRouter(tt.webhook)
+
+			w, _ := sendHook(router, tt.path, "push", signPayload("", testPushPayload), testPushPayload)
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("Expected status 404, got %d", w.Code)
+			}
+		})
+	}
+}
+
+func TestHookTrigger_Filters(t *testing.T) {
+	tests := []struct {
+		name        string
+		webhook     *WebhookConfig
+		event       string
+		payload     string
+		expectSkips bool
+	}{
+		{
+			name:    "matching branch",
+			webhook: &WebhookConfig{Secret: "s3cret", Branches: []string{"main"}},
+			event:   "push",
+			payload: testPushPayload,
+		},
+		{
+			name:    "matching branch pattern",
+			webhook: &WebhookConfig{Secret: "s3cret", Branches: []string{"release/*"}},
+			event:   "push",
+			payload: `{"ref":"refs/heads/release/1.2","after":"abc"}`,
+		},
+		{
+			name:        "other branch",
+			webhook:     &WebhookConfig{Secret: "s3cret", Branches: []string{"main"}},
+			event:       "push",
+			payload:     `{"ref":"refs/heads/feature","after":"abc"}`,
+			expectSkips: true,
+		},
+		{
+			name:        "tag ref with branch filter",
+			webhook:     &WebhookConfig{Secret: "s3cret", Branches: []string{"main"}},
+			event:       "push",
+			payload:     `{"ref":"refs/tags/v1.0.0","after":"abc"}`,
+			expectSkips: true,
+		},
+		{
+			name:    "matching event",
+			webhook: &WebhookConfig{Secret: "s3cret", Events: []string{"push"}},
+			event:   "push",
+			payload: testPushPayload,
+		},
+		{
+			name:        "other event",
+			webhook:     &WebhookConfig{Secret: "s3cret", Events: []string{"release"}},
+			event:       "push",
+			payload:     testPushPayload,
+			expectSkips: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := newHookTestRouter(tt.webhook)
+
+			w, response := sendHook(router, "/api/hooks/project1", tt.event, signPayload("s3cret", tt.payload), tt.payload)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+			}
+
+			data, ok := response["data"].(map[string]interface{})
+			if !ok {
+				t.Fatal("Response missing 'data' field")
+			}
+
+			_, skipped := data["skipped"]
+			if skipped != tt.expectSkips {
+				t.Errorf("Expected skipped=%v, got response %v", tt.expectSkips, data)
+			}
+			if !tt.expectSkips && data["deploymentId"] == "" {
+				t.Error("Response missing 'deploymentId' field")
+			}
+		})
+	}
+}
+
+func TestHookTrigger_Ping(t *testing.T) {
+	router, _ := newHookTestRouter(&WebhookConfig{Secret: "s3cret", Events: []string{"push"}})
+
+	body := `{"zen":"Keep it logically awesome."}`
+	w, response := sendHook(router, "/api/hooks/project1", "ping", signPayload("s3cret", body), body)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if response["message"] != "pong" {
+		t.Errorf("Expected 'pong' message, got %v", response["message"])
+	}
+}
+
+func TestHookTrigger_InvalidPayload(t *testing.T) {
+	router, _ := newHookTestRouter(&WebhookConfig{Secret: "s3cret"})
+
+	body := "not json"
+	w, response := sendHook(router, "/api/hooks/project1", "push", signPayload("s3cret", body), body)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+	if _, ok := response["error"]; !ok {
+		t.Error("Response missing 'error' field")
+	}
+}
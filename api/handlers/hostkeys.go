@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/diiyw/ed/ssh"
+	"github.com/gin-gonic/gin"
+	xssh "golang.org/x/crypto/ssh"
+)
+
+// Host key states reported by GetHostKey
+const (
+	HostKeyNew     = "new"     // not pinned yet, verify the fingerprint and accept it
+	HostKeyTrusted = "trusted" // matches the pinned key
+	HostKeyChanged = "changed" // differs from the pinned key
+)
+
+// HostKeyInfo describes the host key presented by a server
+type HostKeyInfo struct {
+	Address     string   `json:"address"`
+	KeyType     string   `json:"key_type"`
+	Fingerprint string   `json:"fingerprint"`
+	Status      string   `json:"status"`
+	Pinned      []string `json:"pinned,omitempty"`    // fingerprints of the pinned keys
+	Authority   string   `json:"authority,omitempty"` // fingerprint of the CA of a host certificate
+}
+
+// hostKeyInfo describes a host key checked against the pinned keys and host
+// CAs. A host certificate is described by the key it certifies.
+func hostKeyInfo(address string, key xssh.PublicKey, checkErr error) HostKeyInfo {
+	info := HostKeyInfo{
+		Address: address,
+		Status:  HostKeyTrusted,
+	}
+	if cert, ok := key.(*xssh.Certificate); ok {
+		info.Authority = ssh.Fingerprint(cert.SignatureKey)
+		key = cert.Key
+	}
+	info.KeyType = key.Type()
+	info.Fingerprint = ssh.Fingerprint(key)
+
+	var hostErr *ssh.HostKeyError
+	if errors.As(checkErr, &hostErr) {
+		info.Status = HostKeyNew
+		if !hostErr.Unknown() {
+			info.Status = HostKeyChanged
+			info.Pinned = hostErr.Want
+		}
+	}
+	return info
+}
+
+// scanHostKey fetches the host key of an SSH configuration through its jump
+// hosts, and checks it against the pinned keys
+func (h *SSHHandler) scanHostKey(name string) (*HostKeyInfo, xssh.PublicKey, int, error) {
+	var sshConfig *SSHConfig
+	for _, cfg := range h.config.SSHConfigs {
+		if cfg.Name == name {
+			sshConfig = &cfg
+			break
+		}
+	}
+	if sshConfig == nil {
+		return nil, nil, http.StatusNotFound, errors.New("SSH configuration not found")
+	}
+
+	target, err := toDeploySSHConfig(h.config, *sshConfig)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, err
+	}
+	callback, err := ssh.HostKeyPins(h.config.KnownHostsFile())
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, fmt.Errorf("Failed to load pinned host keys: %v", err)
+	}
+	clientConfig, err := target.ClientConfig(callback)
+	if err != nil {
+		return nil, nil, http.StatusBadRequest, fmt.Errorf("Auth error: %v", err)
+	}
+
+	key, err := ssh.ScanHostKey(clientConfig)
+	if err != nil {
+		return nil, nil, http.StatusBadGateway, fmt.Errorf("Failed to fetch host key: %v", err)
+	}
+
+	address := net.JoinHostPort(clientConfig.Addr, strconv.Itoa(int(clientConfig.Port)))
+	info := hostKeyInfo(address, key, callback(address, &net.TCPAddr{}, key))
+	return &info, key, http.StatusOK, nil
+}
+
+// GetHostKey fetches the host key of a server and reports whether it is pinned
+func (h *SSHHandler) GetHostKey(c *gin.Context) {
+	info, _, status, err := h.scanHostKey(c.Param("name"))
+	if err != nil {
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": info,
+	})
+}
+
+// AcceptHostKey pins the host key of a server after its fingerprint was
+// verified. The key is fetched again and must match the accepted fingerprint.
+func (h *SSHHandler) AcceptHostKey(c *gin.Context) {
+	var req struct {
+		Fingerprint string `json:"fingerprint" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	info, key, status, err := h.scanHostKey(c.Param("name"))
+	if err != nil {
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if info.Fingerprint != req.Fingerprint {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Host key fingerprint is %s, not the accepted %s", info.Fingerprint, req.Fingerprint),
+			"data":  info,
+		})
+		return
+	}
+
+	if err := ssh.PinHostKey(info.Address, key, h.config.KnownHostsFile()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to pin host key: %v", err),
+		})
+		return
+	}
+	info.Status = HostKeyTrusted
+	info.Pinned = nil
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    info,
+		"message": "Host key accepted",
+	})
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/diiyw/ed/ssh"
+	"github.com/gin-gonic/gin"
+	xssh "golang.org/x/crypto/ssh"
+)
+
+// Unit Tests for Host Key Pinning
+
+// newHostKeyServer starts an SSH server on localhost that accepts any client
+// but rejects its sessions, and returns an SSH config pointing to it and its host key
+func newHostKeyServer(t *testing.T) (SSHConfig, xssh.PublicKey) {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := xssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &xssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_, chans, reqs, err := xssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go xssh.DiscardRequests(reqs)
+				for ch := range chans {
+					ch.Reject(xssh.Prohibited, "no sessions")
+				}
+			}()
+		}
+	}()
+
+	sshConfig := SSHConfig{
+		Name:     "server1",
+		Host:     "127.0.0.1",
+		Port:     listener.Addr().(*net.TCPAddr).Port,
+		User:     "deploy",
+		AuthType: "password",
+	}
+	return sshConfig, signer.PublicKey()
+}
+
+// newHostKeyRouter routes the host key and connection test endpoints
+func newHostKeyRouter(config *Config) *gin.Engine {
+	handler := NewSSHHandler(config)
+	router := gin.New()
+	router.GET("/api/ssh/:name/hostkey", handler.GetHostKey)
+	router.POST("/api/ssh/:name/hostkey", handler.AcceptHostKey)
+	router.POST("/api/ssh/:name/test", handler.Test)
+	return router
+}
+
+// getHostKey fetches the host key info of server1
+func getHostKey(t *testing.T, router *gin.Engine) HostKeyInfo {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/ssh/server1/hostkey", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data HostKeyInfo `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	return response.Data
+}
+
+func TestHostKeyAcceptFlow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sshConfig, key := newHostKeyServer(t)
+	config := &Config{
+		SSHConfigs: []SSHConfig{sshConfig},
+		KnownHosts: filepath.Join(t.TempDir(), "known_hosts"),
+	}
+	router := newHostKeyRouter(config)
+
+	info := getHostKey(t, router)
+	if info.Status != HostKeyNew || info.Fingerprint != ssh.Fingerprint(key) || info.KeyType != "ssh-ed25519" {
+		t.Fatalf("Expected a new ed25519 key, got %+v", info)
+	}
+
+	// Connection tests refuse the unknown key and show its fingerprint
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/ssh/server1/test", nil))
+	var testResponse struct {
+		Success bool        `json:"success"`
+		HostKey HostKeyInfo `json:"host_key"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &testResponse)
+	if testResponse.Success || testResponse.HostKey.Status != HostKeyNew || testResponse.HostKey.Fingerprint != info.Fingerprint {
+		t.Errorf("Expected the test to report the new host key, got %s", w.Body.String())
+	}
+
+	// Accepting another fingerprint is refused
+	body := bytes.NewBufferString(`{"fingerprint":"SHA256:wrong"}`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/ssh/server1/hostkey", body))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+
+	body = bytes.NewBufferString(`{"fingerprint":"` + info.Fingerprint + `"}`)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/ssh/server1/hostkey", body))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if info := getHostKey(t, router); info.Status != HostKeyTrusted {
+		t.Errorf("Expected the key to be trusted, got %+v", info)
+	}
+}
+
+func TestHostKeyChanged(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sshConfig, _ := newHostKeyServer(t)
+	config := &Config{
+		SSHConfigs: []SSHConfig{sshConfig},
+		KnownHosts: filepath.Join(t.TempDir(), "known_hosts"),
+	}
+
+	// Pin the key of another server for the same address
+	_, oldKey := newHostKeyServer(t)
+	address := net.JoinHostPort(sshConfig.Host, strconv.Itoa(sshConfig.Port))
+	if err := ssh.PinHostKey(address, oldKey, config.KnownHosts); err != nil {
+		t.Fatal(err)
+	}
+
+	info := getHostKey(t, newHostKeyRouter(config))
+	if info.Status != HostKeyChanged || len(info.Pinned) != 1 || info.Pinned[0] != ssh.Fingerprint(oldKey) {
+		t.Errorf("Expected a changed key, got %+v", info)
+	}
+}
+
+func TestSSHTest_PooledConnection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sshConfig, key := newHostKeyServer(t)
+	config := &Config{
+		SSHConfigs: []SSHConfig{sshConfig},
+		KnownHosts: filepath.Join(t.TempDir(), "known_hosts"),
+	}
+	address := net.JoinHostPort(sshConfig.Host, strconv.Itoa(sshConfig.Port))
+	if err := ssh.PinHostKey(address, key, config.KnownHosts); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := deploy.NewEngine()
+	engine.SetKnownHosts(config.KnownHosts)
+	handler := NewSSHHandler(config)
+	handler.SetEngine(engine)
+	router := gin.New()
+	router.POST("/api/ssh/:name/test", handler.Test)
+
+	// Both tests run over the connection pooled by the engine
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/api/ssh/server1/test", nil))
+		if !strings.Contains(w.Body.String(), "Command failed") {
+			t.Errorf("Expected the test command to be rejected, got %s", w.Body.String())
+		}
+	}
+	if stats := engine.PoolStats(); stats.Dials != 1 || stats.Open != 1 || stats.InUse != 0 {
+		t.Errorf("Expected one pooled connection dialed once and released, got %+v", stats)
+	}
+}
+
+func TestGetHostKey_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := newHostKeyRouter(&Config{SSHConfigs: []SSHConfig{}})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/ssh/missing/hostkey", nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestHostKeyInfo_Certificate(t *testing.T) {
+	ca := newTestCA(t)
+	hostKey := newTestCA(t).PublicKey()
+	cert := &xssh.Certificate{Key: hostKey, CertType: xssh.HostCert, ValidBefore: xssh.CertTimeInfinity}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+
+	// A host certificate is described by the key it certifies
+	info := hostKeyInfo("10.0.0.1:22", cert, nil)
+	if info.KeyType != hostKey.Type() || info.Fingerprint != ssh.Fingerprint(hostKey) || info.Authority != ssh.Fingerprint(ca.PublicKey()) {
+		t.Errorf("Unexpected host key %+v", info)
+	}
+	if info := hostKeyInfo("10.0.0.1:22", hostKey, nil); info.Authority != "" {
+		t.Errorf("Expected no authority for a plain key, got %+v", info)
+	}
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/diiyw/ed/api/bus"
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/diiyw/ed/ssh"
+	"github.com/gin-gonic/gin"
+	xssh "golang.org/x/crypto/ssh"
+)
+
+// DeployKey describes a generated deploy key
+type DeployKey struct {
+	KeyFile     string `json:"key_file"`
+	PublicKey   string `json:"public_key"` // authorized_keys line
+	Fingerprint string `json:"fingerprint"`
+}
+
+// findSSHConfig returns the index of the SSH configuration called name, or -1
+func (h *SSHHandler) findSSHConfig(name string) int {
+	for i, cfg := range h.config.SSHConfigs {
+		if cfg.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// deployKeyFile returns the private key file of the deploy key of a server,
+// its public key being stored next to it with a .pub suffix
+func (h *SSHHandler) deployKeyFile(name string) (string, error) {
+	if name != filepath.Base(name) || name == "." || name == ".." {
+		return "", fmt.Errorf("SSH configuration name '%s' cannot be used as key file name", name)
+	}
+	return filepath.Join(h.config.KeysDirectory(), name), nil
+}
+
+// GenerateKey creates an ed25519 deploy key pair for a server. The key is
+// only used once installed.
+func (h *SSHHandler) GenerateKey(c *gin.Context) {
+	name := c.Param("name")
+	i := h.findSSHConfig(name)
+	if i < 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "SSH configuration not found",
+		})
+		return
+	}
+
+	keyFile, err := h.deployKeyFile(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Replacing the key the server logs in with would lock it out
+	if cfg := h.config.SSHConfigs[i]; cfg.KeyFile == keyFile {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "The deploy key is in use by this SSH configuration",
+		})
+		return
+	}
+
+	privateKey, authorizedKey, err := ssh.GenerateKey("ed@" + name)
+	if err == nil {
+		err = writeDeployKey(keyFile, privateKey, authorizedKey)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to generate deploy key: %v", err),
+		})
+		return
+	}
+
+	publicKey, _, _, _, err := xssh.ParseAuthorizedKey(authorizedKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to generate deploy key: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": DeployKey{
+			KeyFile:     keyFile,
+			PublicKey:   string(authorizedKey[:len(authorizedKey)-1]),
+			Fingerprint: ssh.Fingerprint(publicKey),
+		},
+		"message": "Deploy key generated",
+	})
+}
+
+// writeDeployKey stores a key pair, the private key readable by its owner only
+func writeDeployKey(keyFile string, privateKey []byte, authorizedKey []byte) error {
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, privateKey, 0600); err != nil {
+		return err
+	}
+	if err := os.Chmod(keyFile, 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(keyFile+".pub", authorizedKey, 0644)
+}
+
+// useDeployKey switches an SSH configuration to key auth with its deploy key,
+// dropping the other credentials. Sudo in password mode falling back to the
+// SSH password keeps it as its own password.
+func useDeployKey(cfg *SSHConfig, keyFile string) {
+	if cfg.Sudo != nil && cfg.Sudo.Mode == deploy.SudoPassword && cfg.Sudo.Password == "" {
+		sudo := *cfg.Sudo
+		sudo.Password = cfg.Password
+		cfg.Sudo = &sudo
+	}
+
+	cfg.AuthType = deploy.AuthKey
+	cfg.KeyFile = keyFile
+	cfg.KeyPass = ""
+	cfg.Password = ""
+	cfg.PrivateKey = ""
+	cfg.CertFile = ""
+	cfg.AuthMethods = nil
+}
+
+// InstallKey authorizes the generated deploy key of a server, logging in
+// with its current auth, and switches the SSH configuration to the key once
+// it logs in
+func (h *SSHHandler) InstallKey(c *gin.Context) {
+	name := c.Param("name")
+	i := h.findSSHConfig(name)
+	if i < 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "SSH configuration not found",
+		})
+		return
+	}
+
+	keyFile, err := h.deployKeyFile(name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if _, err := os.Stat(keyFile + ".pub"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No deploy key generated for this SSH configuration",
+		})
+		return
+	}
+
+	target, err := toDeploySSHConfig(h.config, h.config.SSHConfigs[i])
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := deploy.InstallKey(target, h.config.KnownHostsFile(), keyFile); err != nil {
+		response := gin.H{
+			"error": fmt.Sprintf("Failed to install deploy key: %v", err),
+		}
+
+		// Let the UI ask to verify an unknown or changed host key
+		var hostErr *ssh.HostKeyError
+		if errors.As(err, &hostErr) {
+			response["host_key"] = hostKeyInfo(hostErr.Host, hostErr.Key, hostErr)
+		}
+		c.JSON(http.StatusBadGateway, response)
+		return
+	}
+
+	cfg := &h.config.SSHConfigs[i]
+	useDeployKey(cfg, keyFile)
+	if err := SaveConfig("config.json", h.config); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to save configuration: %v", err),
+		})
+		return
+	}
+
+	h.bus.Publish(bus.SSHUpdated, name, cfg.Summary())
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"config": *cfg,
+		},
+		"message": "Deploy key installed, switched to key auth",
+	})
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// Unit Tests for Deploy Keys
+
+func newKeysRouter(config *Config) *gin.Engine {
+	handler := NewSSHHandler(config)
+	router := gin.New()
+	router.POST("/api/ssh/:name/keys/generate", handler.GenerateKey)
+	router.POST("/api/ssh/:name/keys/install", handler.InstallKey)
+	return router
+}
+
+func TestGenerateKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{
+		SSHConfigs: []SSHConfig{{Name: "server1", Host: "10.0.0.1", AuthType: "password"}},
+		KeysDir:    filepath.Join(t.TempDir(), "keys"),
+	}
+	router := newKeysRouter(config)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/ssh/server1/keys/generate", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data DeployKey `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	key := response.Data
+	if key.KeyFile != filepath.Join(config.KeysDir, "server1") || !strings.HasPrefix(key.PublicKey, "ssh-ed25519 ") || !strings.HasSuffix(key.PublicKey, " ed@server1") || !strings.HasPrefix(key.Fingerprint, "SHA256:") {
+		t.Errorf("Unexpected deploy key %+v", key)
+	}
+
+	info, err := os.Stat(key.KeyFile)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a private key readable by its owner only, got %v %v", info, err)
+	}
+	if data, _ := os.ReadFile(key.KeyFile + ".pub"); strings.TrimSpace(string(data)) != key.PublicKey {
+		t.Errorf("Expected the public key to be stored, got %q", data)
+	}
+	if config.SSHConfigs[0].AuthType != "password" {
+		t.Error("Expected the config to keep its auth until the key is installed")
+	}
+
+	// The key a server logs in with is not replaced
+	config.SSHConfigs[0].AuthType = "key"
+	config.SSHConfigs[0].KeyFile = key.KeyFile
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/ssh/server1/keys/generate", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/ssh/missing/keys/generate", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestInstallKey_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	sshConfig, _ := newHostKeyServer(t)
+	config := &Config{
+		SSHConfigs: []SSHConfig{sshConfig},
+		KnownHosts: filepath.Join(t.TempDir(), "known_hosts"),
+		KeysDir:    t.TempDir(),
+	}
+	router := newKeysRouter(config)
+
+	// A key must be generated first
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/ssh/server1/keys/install", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/ssh/server1/keys/generate", nil))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// The server's host key is not pinned yet
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/api/ssh/server1/keys/install", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("Expected status 502, got %d: %s", w.Code, w.Body.String())
+	}
+	var response struct {
+		HostKey HostKeyInfo `json:"host_key"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.HostKey.Status != HostKeyNew {
+		t.Errorf("Expected the unknown host key to be reported, got %s", w.Body.String())
+	}
+	if config.SSHConfigs[0].AuthType != "password" || config.SSHConfigs[0].KeyFile != "" {
+		t.Errorf("Expected the config to keep its auth, got %+v", config.SSHConfigs[0])
+	}
+}
+
+func TestUseDeployKey(t *testing.T) {
+	tests := []struct {
+		name string
+		sudo *deploy.Sudo
+		want *deploy.Sudo
+	}{
+		{"no sudo", nil, nil},
+		{"passwordless", &deploy.Sudo{Mode: "passwordless"}, &deploy.Sudo{Mode: "passwordless"}},
+		{"own sudo password", &deploy.Sudo{Mode: "password", Password: "sudo-secret"}, &deploy.Sudo{Mode: "password", Password: "sudo-secret"}},
+		{"SSH password for sudo", &deploy.Sudo{Mode: "password"}, &deploy.Sudo{Mode: "password", Password: "ssh-secret"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := SSHConfig{
+				Name:        "server1",
+				AuthType:    "password",
+				Password:    "ssh-secret",
+				AuthMethods: []string{"password"},
+				Sudo:        tt.sudo,
+			}
+			useDeployKey(&cfg, "keys/server1")
+
+			if cfg.AuthType != "key" || cfg.KeyFile != "keys/server1" || cfg.Password != "" || cfg.AuthMethods != nil {
+				t.Errorf("Expected key auth with the deploy key only, got %+v", cfg)
+			}
+			if !reflect.DeepEqual(cfg.Sudo, tt.want) {
+				t.Errorf("Expected sudo %+v, got %+v", tt.want, cfg.Sudo)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// logTimeFormat is the timestamp format of exported log lines
+const logTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// logFormats maps the export formats to their content type and file extension
+var logFormats = map[string]struct {
+	contentType string
+	ext         string
+	render      func(status *deploy.DeploymentStatus, events []deploy.Event) []byte
+}{
+	"txt":    {"text/plain; charset=utf-8", ".log", renderText},
+	"ndjson": {"application/x-ndjson", ".ndjson", renderNDJSON},
+	"html":   {"text/html; charset=utf-8", ".html", renderHTML},
+}
+
+// DownloadLogs exports the log of a deployment as a text, NDJSON or HTML file.
+// Without a log store only the last deploy.HistoryLimit events are kept in
+// memory; an export missing earlier events starts with a truncation note and
+// has the X-Log-Truncated header set to the number of missing events.
+func (h *DeploymentHandler) DownloadLogs(c *gin.Context) {
+	id := c.Param("id")
+	format, ok := logFormats[c.DefaultQuery("format", "txt")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid format, must be one of: txt, ndjson, html",
+		})
+		return
+	}
+
+	events, err := h.engine.Logs(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to read log: %v", err),
+		})
+		return
+	}
+
+	// Deployments forgotten by the engine, e.g. after a restart, are only
+	// known by their stored log
+	status, exists := h.engine.GetStatus(id)
+	if !exists {
+		if len(events) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Deployment not found",
+			})
+			return
+		}
+		status = &deploy.DeploymentStatus{ID: id}
+	}
+
+	// Sequence numbers start at 1, a later first event means older ones are gone
+	if len(events) > 0 && events[0].Seq > 1 {
+		missing := events[0].Seq - 1
+		c.Header("X-Log-Truncated", strconv.FormatUint(missing, 10))
+		events = append([]deploy.Event{{
+			DeploymentID: id,
+			Type:         string(deploy.LogTypeStatus),
+			Data:         fmt.Sprintf("Log truncated: the first %d events are no longer available, configure a log store to keep full logs", missing),
+			Timestamp:    events[0].Timestamp,
+		}}, events...)
+	}
+
+	filename := status.ID + format.ext
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, format.contentType, format.render(status, events))
+}
+
+// logPrefix returns the server and stream prefix of a log line
+func logPrefix(event deploy.Event) string {
+	var prefix string
+	if event.Server != "" {
+		prefix = "[" + event.Server + "] "
+	}
+	if event.Stream != "" {
+		return prefix + "[" + event.Stream + "]"
+	}
+	return prefix + "[" + event.Type + "]"
+}
+
+// renderText renders one line per line of output, prefixed with its timestamp,
+// server and stream
+func renderText(_ *deploy.DeploymentStatus, events []deploy.Event) []byte {
+	var b bytes.Buffer
+	for _, event := range events {
+		prefix := event.Timestamp.Format(logTimeFormat) + " " + logPrefix(event)
+		for _, line := range strings.Split(event.Data, "\n") {
+			fmt.Fprintf(&b, "%s %s\n", prefix, line)
+		}
+	}
+	return b.Bytes()
+}
+
+// renderNDJSON renders one JSON event per line
+func renderNDJSON(_ *deploy.DeploymentStatus, events []deploy.Event) []byte {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	for _, event := range events {
+		encoder.Encode(event)
+	}
+	return b.Bytes()
+}
+
+// renderHTML renders a standalone page with ANSI colours converted to styled spans
+func renderHTML(status *deploy.DeploymentStatus, events []deploy.Event) []byte {
+	title := html.EscapeString(fmt.Sprintf("%s %s", status.ProjectName, status.ID))
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+body { background: #1e1e1e; color: #d4d4d4; font-family: monospace; }
+.time, .prefix { color: #808080; }
+.error { color: #f14c4c; }
+.status { color: #3b8eea; }
+</style>
+</head>
+<body>
+<h1>%s</h1>
+<p>Status: %s</p>
+<pre>
+`, title, title, html.EscapeString(status.Status))
+
+	for _, event := range events {
+		prefix := fmt.Sprintf(`<span class="time">%s</span> <span class="prefix">%s</span>`,
+			event.Timestamp.Format(logTimeFormat), html.EscapeString(logPrefix(event)))
+		for _, line := range strings.Split(event.Data, "\n") {
+			fmt.Fprintf(&b, "%s <span class=\"%s\">%s</span>\n", prefix, html.EscapeString(event.Type), ansiToHTML(line))
+		}
+	}
+
+	b.WriteString("</pre>\n</body>\n</html>\n")
+	return b.Bytes()
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// Unit Tests for Deployment Log Downloads
+
+func TestDownloadLogs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine := deploy.NewEngine()
+	engine.Enqueue("deploy-1", &deploy.Project{Name: "project1", BuildInstructions: "echo <ok>"}, nil, nil)
+	waitForStatus(t, engine, "deploy-1", "success")
+
+	handler := NewDeploymentHandler(engine)
+	router := gin.New()
+	router.GET("/api/deployments/:id/logs/download", handler.DownloadLogs)
+
+	download := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/deployments/deploy-1/logs/download"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := download("")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="deploy-1.log"` {
+		t.Errorf("Unexpected Content-Disposition %s", cd)
+	}
+	if !strings.Contains(w.Body.String(), "[status] Deployment completed successfully\n") {
+		t.Errorf("Expected prefixed text lines, got %s", w.Body.String())
+	}
+
+	w = download("?format=ndjson")
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	var event deploy.Event
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &event); err != nil {
+		t.Fatalf("Invalid NDJSON line: %v", err)
+	}
+	if event.Data != "Deployment completed successfully" {
+		t.Errorf("Unexpected last event %+v", event)
+	}
+
+	w = download("?format=html")
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Expected HTML content type, got %s", ct)
+	}
+	if !strings.Contains(w.Body.String(), "echo &lt;ok&gt;") {
+		t.Errorf("Expected escaped output in HTML, got %s", w.Body.String())
+	}
+
+	w = download("?format=pdf")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestDownloadLogs_Truncated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	historyLimit := deploy.HistoryLimit
+	deploy.HistoryLimit = 3
+	defer func() { deploy.HistoryLimit = historyLimit }()
+
+	engine := deploy.NewEngine()
+	engine.Enqueue("deploy-1", &deploy.Project{Name: "project1", BuildInstructions: "step 1\nstep 2\nstep 3"}, nil, nil)
+	waitForStatus(t, engine, "deploy-1", "success")
+
+	handler := NewDeploymentHandler(engine)
+	router := gin.New()
+	router.GET("/api/deployments/:id/logs/download", handler.DownloadLogs)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/deployments/deploy-1/logs/download", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("X-Log-Truncated") == "" {
+		t.Error("Expected the X-Log-Truncated header to be set")
+	}
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[0], "[status] Log truncated: the first ") {
+		t.Errorf("Expected a truncation note before the last 3 events, got %s", w.Body.String())
+	}
+}
+
+func TestDownloadLogs_AfterRestart(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	store, err := deploy.NewLogStore(dir, deploy.LogRetention{})
+	if err != nil {
+		t.Fatalf("NewLogStore failed: %v", err)
+	}
+
+	engine := deploy.NewEngine()
+	engine.SetLogStore(store)
+	engine.Enqueue("deploy-1", &deploy.Project{Name: "project1", BuildInstructions: "step 1"}, nil, nil)
+	waitForStatus(t, engine, "deploy-1", "success")
+	for i := 0; ; i++ {
+		if _, err := os.Stat(filepath.Join(dir, "project1", "deploy-1.log.gz")); err == nil {
+			break
+		}
+		if i == 100 {
+			t.Fatal("Timed out waiting for the stored log")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// A restarted engine no longer knows the deployment, only its stored log
+	restarted := deploy.NewEngine()
+	restarted.SetLogStore(store)
+	handler := NewDeploymentHandler(restarted)
+	router := gin.New()
+	router.GET("/api/deployments/:id/logs/download", handler.DownloadLogs)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/deployments/deploy-1/logs/download", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if cd := w.Header().Get("Content-Disposition"); cd != `attachment; filename="deploy-1.log"` {
+		t.Errorf("Unexpected Content-Disposition %s", cd)
+	}
+	if !strings.Contains(w.Body.String(), "[status] Deployment completed successfully") {
+		t.Errorf("Expected the stored log, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/deployments/deploy-2/logs/download", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown deployment, got %d", w.Code)
+	}
+}
+
+func TestRenderText(t *testing.T) {
+	events := []deploy.Event{
+		{Type: "log", Data: "line 1\nline 2", Server: "web1", Stream: "stdout"},
+		{Type: "error", Data: "failed", Server: "web1"},
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(renderText(nil, events))), "\n")
+	expected := []string{"[web1] [stdout] line 1", "[web1] [stdout] line 2", "[web1] [error] failed"}
+	for i, want := range expected {
+		if !strings.HasSuffix(lines[i], want) {
+			t.Errorf("Expected line %d to end with %q, got %q", i, want, lines[i])
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+// TestMain runs the tests in a temporary directory, so handlers saving
+// config.json or generating keys do not write into the source tree
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "ed-handlers-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/diiyw/ed/api/notify"
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationHandler struct {
+	config   *Config
+	notifier *notify.Notifier
+}
+
+func NewNotificationHandler(config *Config, notifier *notify.Notifier) *NotificationHandler {
+	return &NotificationHandler{config: config, notifier: notifier}
+}
+
+// GetAll returns all notification channels
+func (h *NotificationHandler) GetAll(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"channels": h.config.Notifications,
+		},
+	})
+}
+
+// Create creates a new notification channel
+func (h *NotificationHandler) Create(c *gin.Context) {
+	var newChannel notify.ChannelConfig
+	if err := c.ShouldBindJSON(&newChannel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	if err := notify.Validate(newChannel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid channel: %v", err),
+		})
+		return
+	}
+
+	// Check if name already exists
+	for _, ch := range h.config.Notifications {
+		if ch.Name == newChannel.Name {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Notification channel with this name already exists",
+			})
+			return
+		}
+	}
+
+	h.config.Notifications = append(h.config.Notifications, newChannel)
+	if err := SaveConfig("config.json", h.config); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("Failed to save configuration: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": gin.H{
+			"channel": newChannel,
+		},
+		"message": "Notification channel created successfully",
+	})
+}
+
+// Update updates an existing notification channel
+func (h *NotificationHandler) Update(c *gin.Context) {
+	name := c.Param("name")
+	var updatedChannel notify.ChannelConfig
+	if err := c.ShouldBindJSON(&updatedChannel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	if err := notify.Validate(updatedChannel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid channel: %v", err),
+		})
+		return
+	}
+
+	for i, ch := range h.config.Notifications {
+		if ch.Name == name {
+			h.config.Notifications[i] = updatedChannel
+			if err := SaveConfig("config.json", h.config); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("Failed to save configuration: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"data": gin.H{
+					"channel": updatedChannel,
+				},
+				"message": "Notification channel updated successfully",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": "Notification channel not found",
+	})
+}
+
+// Delete deletes a notification channel
+func (h *NotificationHandler) Delete(c *gin.Context) {
+	name := c.Param("name")
+
+	for i, ch := range h.config.Notifications {
+		if ch.Name == name {
+			h.config.Notifications = append(h.config.Notifications[:i], h.config.Notifications[i+1:]...)
+			if err := SaveConfig("config.json", h.config); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": fmt.Sprintf("Failed to save configuration: %v", err),
+				})
+				return
+			}
+
+			c.JSON(http.StatusOK, gin.H{
+				"message": "Notification channel deleted successfully",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": "Notification channel not found",
+	})
+}
+
+// Test sends a sample notification through a channel
+func (h *NotificationHandler) Test(c *gin.Context) {
+	name := c.Param("name")
+
+	var channel *notify.ChannelConfig
+	for _, ch := range h.config.Notifications {
+		if ch.Name == name {
+			channel = &ch
+			break
+		}
+	}
+
+	if channel == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Notification channel not found",
+		})
+		return
+	}
+
+	event := notify.Event{
+		Event:   "test",
+		ID:      "test",
+		Project: "ed",
+		Status:  "success",
+		Time:    time.Now(),
+	}
+
+	if err := h.notifier.Send(*channel, event); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("Delivery failed: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Notification sent successfully",
+	})
+}
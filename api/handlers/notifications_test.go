@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/diiyw/ed/api/notify"
+	"github.com/gin-gonic/gin"
+)
+
+// Unit Tests for Notification Channel API Handlers
+
+func newNotificationTestRouter(config *Config) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewNotificationHandler(config, notify.New(deploy.NewEngine(), config))
+	router := gin.New()
+	router.GET("/api/notifications", handler.GetAll)
+	router.POST("/api/notifications", handler.Create)
+	router.PUT("/api/notifications/:name", handler.Update)
+	router.DELETE("/api/notifications/:name", handler.Delete)
+	router.POST("/api/notifications/:name/test", handler.Test)
+	return router
+}
+
+func TestCreateNotificationChannel(t *testing.T) {
+	config := &Config{}
+	router := newNotificationTestRouter(config)
+
+	body, _ := json.Marshal(notify.ChannelConfig{Name: "slack", Type: notify.TypeSlack, URL: "http://example.com/hook"})
+	req := httptest.NewRequest("POST", "/api/notifications", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if len(config.Notifications) != 1 {
+		t.Fatalf("Expected 1 channel, got %d", len(config.Notifications))
+	}
+
+	// Duplicate names conflict
+	req = httptest.NewRequest("POST", "/api/notifications", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+}
+
+func TestCreateNotificationChannel_Invalid(t *testing.T) {
+	router := newNotificationTestRouter(&Config{})
+
+	body, _ := json.Marshal(notify.ChannelConfig{Name: "pager", Type: "pager"})
+	req := httptest.NewRequest("POST", "/api/notifications", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestUpdateAndDeleteNotificationChannel(t *testing.T) {
+	config := &Config{
+		Notifications: []notify.ChannelConfig{
+			{Name: "hook", Type: notify.TypeWebhook, URL: "http://example.com/a"},
+		},
+	}
+	router := newNotificationTestRouter(config)
+
+	body, _ := json.Marshal(notify.ChannelConfig{Name: "hook", Type: notify.TypeWebhook, URL: "http://example.com/b"})
+	req := httptest.NewRequest("PUT", "/api/notifications/hook", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d. Body: %s", w.Code, w.Body.String())
+	}
+	if config.Notifications[0].URL != "http://example.com/b" {
+		t.Errorf("Channel was not updated: %+v", config.Notifications[0])
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/notifications/hook", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if len(config.Notifications) != 0 {
+		t.Errorf("Expected channel to be deleted, got %d channels", len(config.Notifications))
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/notifications/hook", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestTestNotificationChannel(t *testing.T) {
+	received := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = true
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Notifications: []notify.ChannelConfig{
+			{Name: "hook", Type: notify.TypeWebhook, URL: server.URL},
+		},
+	}
+	router := newNotificationTestRouter(config)
+
+	req := httptest.NewRequest("POST", "/api/notifications/hook/test", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	if response["success"] != true {
+		t.Errorf("Expected success, got %v", response)
+	}
+	if !received {
+		t.Error("Expected test notification to be delivered")
+	}
+}
+
+func TestProjectSubscriptions(t *testing.T) {
+	config := &Config{
+		Projects: []Project{
+			{Name: "api", Notify: []notify.Subscription{{Channel: "slack"}}},
+		},
+	}
+
+	if subs := config.ProjectSubscriptions("api"); len(subs) != 1 || subs[0].Channel != "slack" {
+		t.Errorf("Expected slack subscription, got %v", subs)
+	}
+	if subs := config.ProjectSubscriptions("other"); subs != nil {
+		t.Errorf("Expected no subscriptions, got %v", subs)
+	}
+}
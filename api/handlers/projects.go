@@ -5,15 +5,24 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/diiyw/ed/api/bus"
+	"github.com/diiyw/ed/api/deploy"
 	"github.com/gin-gonic/gin"
 )
 
 type ProjectHandler struct {
 	config *Config
+	engine *deploy.Engine
+	bus    *bus.Bus
 }
 
-func NewProjectHandler(config *Config) *ProjectHandler {
-	return &ProjectHandler{config: config}
+func NewProjectHandler(config *Config, engine *deploy.Engine) *ProjectHandler {
+	return &ProjectHandler{config: config, engine: engine}
+}
+
+// SetBus publishes project changes on b
+func (h *ProjectHandler) SetBus(b *bus.Bus) {
+	h.bus = b
 }
 
 // GetAll returns all projects
@@ -55,6 +64,27 @@ func (h *ProjectHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if err := validateProjectFreezes(&newProject); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := deploy.ValidateSteps(newProject.Steps); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := deploy.ValidateTemplates(newProject.Templates, newProject.Handlers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	// Check if name already exists
 	for _, proj := range h.config.Projects {
 		if proj.Name == newProject.Name {
@@ -78,6 +108,8 @@ func (h *ProjectHandler) Create(c *gin.Context) {
 		return
 	}
 
+	h.bus.Publish(bus.ProjectCreated, newProject.Name, newProject.Summary())
+
 	c.JSON(http.StatusCreated, gin.H{
 		"data": gin.H{
 			"project": newProject,
@@ -97,6 +129,27 @@ func (h *ProjectHandler) Update(c *gin.Context) {
 		return
 	}
 
+	if err := validateProjectFreezes(&updatedProject); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := deploy.ValidateSteps(updatedProject.Steps); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := deploy.ValidateTemplates(updatedProject.Templates, updatedProject.Handlers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	for i, proj := range h.config.Projects {
 		if proj.Name == name {
 			// Preserve CreatedAt, update UpdatedAt
@@ -111,6 +164,8 @@ func (h *ProjectHandler) Update(c *gin.Context) {
 				return
 			}
 
+			h.bus.Publish(bus.ProjectUpdated, name, updatedProject.Summary())
+
 			c.JSON(http.StatusOK, gin.H{
 				"data": gin.H{
 					"project": updatedProject,
@@ -140,6 +195,8 @@ func (h *ProjectHandler) Delete(c *gin.Context) {
 				return
 			}
 
+			h.bus.Publish(bus.ProjectDeleted, name, nil)
+
 			c.JSON(http.StatusOK, gin.H{
 				"message": "Project deleted successfully",
 			})
@@ -172,33 +229,30 @@ func (h *ProjectHandler) Deploy(c *gin.Context) {
 		return
 	}
 
-	// Validate that all deploy servers exist
-	for _, serverName := range project.DeployServers {
-		found := false
-		for _, sshConfig := range h.config.SSHConfigs {
-			if sshConfig.Name == serverName {
-				found = true
-				break
-			}
-		}
-		if !found {
+	var req overrideRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
-				"error": fmt.Sprintf("Deploy server '%s' not found in SSH configurations", serverName),
+				"error": fmt.Sprintf("Invalid request: %v", err),
 			})
 			return
 		}
 	}
 
-	// Generate a deployment ID
-	deploymentID := fmt.Sprintf("%s-%d", project.Name, time.Now().Unix())
+	trigger := &deploy.Trigger{Source: "manual"}
+	req.apply(trigger)
 
-	// TODO: Actual deployment logic will be implemented in the deployment engine
-	// For now, just return success with deployment ID
+	deploymentID, status, err := startDeployment(h.engine, h.config, project, nil, "", trigger)
+	if err != nil {
+		respondDeployError(c, err)
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": gin.H{
 			"deploymentId": deploymentID,
+			"status":       status,
 		},
-		"message": "Deployment initiated successfully",
+		"message": deploymentMessage(status),
 	})
 }
@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/diiyw/ed/api/deploy"
 	"github.com/gin-gonic/gin"
 )
 
@@ -38,7 +41,7 @@ func TestGetAllProjects(t *testing.T) {
 		},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.GET("/api/projects", handler.GetAll)
 
@@ -77,7 +80,7 @@ func TestCreateProject(t *testing.T) {
 		Projects: []Project{},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.POST("/api/projects", handler.Create)
 
@@ -142,7 +145,7 @@ func TestUpdateProject(t *testing.T) {
 		},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.PUT("/api/projects/:name", handler.Update)
 
@@ -212,7 +215,7 @@ func TestDeleteProject(t *testing.T) {
 		},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.DELETE("/api/projects/:name", handler.Delete)
 
@@ -246,11 +249,14 @@ func TestDeleteProject(t *testing.T) {
 func TestDeployProject(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
+	// Servers on localhost whose host keys are not pinned, the deployment
+	// fails without leaving the machine
+	server1, _ := newHostKeyServer(t)
+	server2, _ := newHostKeyServer(t)
+	server2.Name = "server2"
+
 	config := &Config{
-		SSHConfigs: []SSHConfig{
-			{Name: "server1", Host: "host1.com", Port: 22, User: "user1", AuthType: "password"},
-			{Name: "server2", Host: "host2.com", Port: 22, User: "user2", AuthType: "key"},
-		},
+		SSHConfigs: []SSHConfig{server1, server2},
 		Projects: []Project{
 			{
 				Name:              "project1",
@@ -263,7 +269,9 @@ func TestDeployProject(t *testing.T) {
 		},
 	}
 
-	handler := NewProjectHandler(config)
+	engine := deploy.NewEngine()
+	engine.SetKnownHosts(filepath.Join(t.TempDir(), "known_hosts"))
+	handler := NewProjectHandler(config, engine)
 	router := gin.New()
 	router.POST("/api/projects/:name/deploy", handler.Deploy)
 
@@ -291,7 +299,12 @@ func TestDeployProject(t *testing.T) {
 
 	deploymentID, ok := data["deploymentId"].(string)
 	if !ok || deploymentID == "" {
-		t.Error("Response missing or empty 'deploymentId' field")
+		t.Fatal("Response missing or empty 'deploymentId' field")
+	}
+
+	status := waitForStatus(t, engine, deploymentID, "failed")
+	if !strings.Contains(status.Error, "unknown host key") {
+		t.Errorf("Expected the deployment to reach the test servers, got %q", status.Error)
 	}
 }
 
@@ -311,7 +324,7 @@ func TestGetProjectByName_Found(t *testing.T) {
 		},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.GET("/api/projects/:name", handler.GetByName)
 
@@ -350,7 +363,7 @@ func TestGetProjectByName_NotFound(t *testing.T) {
 		Projects: []Project{},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.GET("/api/projects/:name", handler.GetByName)
 
@@ -388,7 +401,7 @@ func TestCreateProject_DuplicateName(t *testing.T) {
 		},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.POST("/api/projects", handler.Create)
 
@@ -426,7 +439,7 @@ func TestCreateProject_InvalidJSON(t *testing.T) {
 		Projects: []Project{},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.POST("/api/projects", handler.Create)
 
@@ -449,6 +462,59 @@ func TestCreateProject_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestCreateProject_InvalidSteps(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{
+		Projects: []Project{},
+	}
+
+	handler := NewProjectHandler(config, deploy.NewEngine())
+	router := gin.New()
+	router.POST("/api/projects", handler.Create)
+
+	body, _ := json.Marshal(Project{
+		Name:  "site",
+		Steps: []deploy.Step{{Type: deploy.StepSync, Source: "dist"}},
+	})
+	req := httptest.NewRequest("POST", "/api/projects", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+	if len(config.Projects) != 0 {
+		t.Errorf("Expected project not to be created, got %d projects", len(config.Projects))
+	}
+}
+
+func TestCreateProject_UnknownTemplateHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{
+		Projects: []Project{},
+	}
+
+	handler := NewProjectHandler(config, deploy.NewEngine())
+	router := gin.New()
+	router.POST("/api/projects", handler.Create)
+
+	body, _ := json.Marshal(Project{
+		Name:      "site",
+		Templates: []deploy.Template{{Content: "listen 80;", Target: "/etc/nginx/app.conf", Handler: "reload"}},
+	})
+	req := httptest.NewRequest("POST", "/api/projects", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
 func TestUpdateProject_InvalidJSON(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -465,7 +531,7 @@ func TestUpdateProject_InvalidJSON(t *testing.T) {
 		},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.PUT("/api/projects/:name", handler.Update)
 
@@ -495,7 +561,7 @@ func TestUpdateProject_NotFound(t *testing.T) {
 		Projects: []Project{},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.PUT("/api/projects/:name", handler.Update)
 
@@ -533,7 +599,7 @@ func TestDeleteProject_NotFound(t *testing.T) {
 		Projects: []Project{},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.DELETE("/api/projects/:name", handler.Delete)
 
@@ -562,7 +628,7 @@ func TestDeployProject_NotFound(t *testing.T) {
 		Projects: []Project{},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.POST("/api/projects/:name/deploy", handler.Deploy)
 
@@ -603,7 +669,7 @@ func TestDeployProject_InvalidServer(t *testing.T) {
 		},
 	}
 
-	handler := NewProjectHandler(config)
+	handler := NewProjectHandler(config, deploy.NewEngine())
 	router := gin.New()
 	router.POST("/api/projects/:name/deploy", handler.Deploy)
 
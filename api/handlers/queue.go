@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+type QueueHandler struct {
+	engine *deploy.Engine
+}
+
+func NewQueueHandler(engine *deploy.Engine) *QueueHandler {
+	return &QueueHandler{engine: engine}
+}
+
+// moveRequest is the body of queue reorder requests
+type moveRequest struct {
+	Position *int `json:"position" binding:"required"`
+}
+
+// GetAll returns the deployment queue and the worker usage
+func (h *QueueHandler) GetAll(c *gin.Context) {
+	workers, busy := h.engine.Workers()
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"jobs":    h.engine.Queue(),
+			"workers": workers,
+			"busy":    busy,
+		},
+	})
+}
+
+// Move moves a queued deployment to a new position
+func (h *QueueHandler) Move(c *gin.Context) {
+	var req moveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	if err := h.engine.MoveJob(c.Param("id"), *req.Position); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"jobs": h.engine.Queue(),
+		},
+		"message": "Deployment moved successfully",
+	})
+}
+
+// Remove removes a deployment from the queue
+func (h *QueueHandler) Remove(c *gin.Context) {
+	if err := h.engine.RemoveJob(c.Param("id")); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Deployment removed from the queue",
+	})
+}
+
+// respondError writes a queue error, 404 for unknown deployments and 409 otherwise
+func (h *QueueHandler) respondError(c *gin.Context, err error) {
+	if _, exists := h.engine.GetStatus(c.Param("id")); !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Deployment not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"error": err.Error(),
+	})
+}
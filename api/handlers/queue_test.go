@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+)
+
+// Unit Tests for Deployment Queue API Handlers
+
+func newQueueTestRouter() (*gin.Engine, *deploy.Engine) {
+	gin.SetMode(gin.TestMode)
+
+	// A single worker kept busy by a slow build, so later deployments stay queued
+	engine := deploy.NewEngine()
+	engine.SetWorkers(1)
+	engine.Enqueue("slow-1", &deploy.Project{Name: "slow", BuildInstructions: strings.Repeat("step\n", 10)}, nil, nil)
+	engine.Enqueue("a-1", &deploy.Project{Name: "a"}, nil, &deploy.Trigger{Source: "webhook"})
+	engine.Enqueue("a-2", &deploy.Project{Name: "a"}, nil, &deploy.Trigger{Source: "webhook"})
+	engine.Enqueue("b-1", &deploy.Project{Name: "b"}, nil, &deploy.Trigger{Source: "webhook"})
+
+	handler := NewQueueHandler(engine)
+	router := gin.New()
+	router.GET("/api/queue", handler.GetAll)
+	router.PUT("/api/queue/:id", handler.Move)
+	router.DELETE("/api/queue/:id", handler.Remove)
+	return router, engine
+}
+
+func TestGetQueue(t *testing.T) {
+	router, _ := newQueueTestRouter()
+
+	req := httptest.NewRequest("GET", "/api/queue", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	data := response["data"].(map[string]interface{})
+	if jobs := data["jobs"].([]interface{}); len(jobs) != 3 {
+		t.Errorf("Expected 3 queued jobs, got %d", len(jobs))
+	}
+	if data["busy"].(float64) != 1 {
+		t.Errorf("Expected 1 busy worker, got %v", data["busy"])
+	}
+}
+
+func TestMoveQueuedDeployment(t *testing.T) {
+	router, engine := newQueueTestRouter()
+
+	tests := []struct {
+		name           string
+		id             string
+		body           string
+		expectedStatus int
+	}{
+		{"move ahead", "b-1", `{"position":0}`, http.StatusOK},
+		{"missing position", "b-1", `{}`, http.StatusBadRequest},
+		{"past same project", "a-2", `{"position":0}`, http.StatusConflict},
+		{"unknown deployment", "nonexistent", `{"position":0}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/api/queue/"+tt.id, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d. Body: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if queue := engine.Queue(); queue[0].ID != "b-1" {
+		t.Errorf("Expected b-1 at the front of the queue, got %s", queue[0].ID)
+	}
+}
+
+func TestRemoveQueuedDeployment(t *testing.T) {
+	router, engine := newQueueTestRouter()
+
+	req := httptest.NewRequest("DELETE", "/api/queue/a-1", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if status, _ := engine.GetStatus("a-1"); status.Status != "removed" {
+		t.Errorf("Expected status 'removed', got %s", status.Status)
+	}
+
+	// The running deployment is not in the queue
+	req = httptest.NewRequest("DELETE", "/api/queue/slow-1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409, got %d", w.Code)
+	}
+}
@@ -1,19 +1,36 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 
+	"github.com/diiyw/ed/api/bus"
+	"github.com/diiyw/ed/api/deploy"
 	"github.com/diiyw/ed/ssh"
 	"github.com/gin-gonic/gin"
 )
 
 type SSHHandler struct {
 	config *Config
+	engine *deploy.Engine // pools the connections of connection tests
+	bus    *bus.Bus
 }
 
 func NewSSHHandler(config *Config) *SSHHandler {
-	return &SSHHandler{config: config}
+	engine := deploy.NewEngine()
+	engine.SetKnownHosts(config.KnownHostsFile())
+	return &SSHHandler{config: config, engine: engine}
+}
+
+// SetEngine runs connection tests over the pooled connections of engine
+func (h *SSHHandler) SetEngine(engine *deploy.Engine) {
+	h.engine = engine
+}
+
+// SetBus publishes SSH configuration and server health changes on b
+func (h *SSHHandler) SetBus(b *bus.Bus) {
+	h.bus = b
 }
 
 // GetAll returns all SSH configurations
@@ -55,6 +72,27 @@ func (h *SSHHandler) Create(c *gin.Context) {
 		return
 	}
 
+	if err := deploy.ValidateSudo(newConfig.Sudo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := deploySSHConfig(newConfig).ValidateAuth(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.validateJump("", newConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	// Check if name already exists
 	for _, cfg := range h.config.SSHConfigs {
 		if cfg.Name == newConfig.Name {
@@ -73,6 +111,8 @@ func (h *SSHHandler) Create(c *gin.Context) {
 		return
 	}
 
+	h.bus.Publish(bus.SSHCreated, newConfig.Name, newConfig.Summary())
+
 	c.JSON(http.StatusCreated, gin.H{
 		"data": gin.H{
 			"config": newConfig,
@@ -81,6 +121,23 @@ func (h *SSHHandler) Create(c *gin.Context) {
 	})
 }
 
+// validateJump checks that the jump host chain of cfg resolves once it replaces
+// the SSH configuration called name, or is added if name is empty
+func (h *SSHHandler) validateJump(name string, cfg SSHConfig) error {
+	if cfg.Jump == "" {
+		return nil
+	}
+
+	configs := []SSHConfig{cfg}
+	for _, existing := range h.config.SSHConfigs {
+		if existing.Name != name && existing.Name != cfg.Name {
+			configs = append(configs, existing)
+		}
+	}
+	_, err := toDeploySSHConfig(&Config{SSHConfigs: configs}, cfg)
+	return err
+}
+
 // Update updates an existing SSH configuration
 func (h *SSHHandler) Update(c *gin.Context) {
 	name := c.Param("name")
@@ -92,6 +149,27 @@ func (h *SSHHandler) Update(c *gin.Context) {
 		return
 	}
 
+	if err := deploy.ValidateSudo(updatedConfig.Sudo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := deploySSHConfig(updatedConfig).ValidateAuth(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.validateJump(name, updatedConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	for i, cfg := range h.config.SSHConfigs {
 		if cfg.Name == name {
 			h.config.SSHConfigs[i] = updatedConfig
@@ -102,6 +180,8 @@ func (h *SSHHandler) Update(c *gin.Context) {
 				return
 			}
 
+			h.bus.Publish(bus.SSHUpdated, name, updatedConfig.Summary())
+
 			c.JSON(http.StatusOK, gin.H{
 				"data": gin.H{
 					"config": updatedConfig,
@@ -131,6 +211,8 @@ func (h *SSHHandler) Delete(c *gin.Context) {
 				return
 			}
 
+			h.bus.Publish(bus.SSHDeleted, name, nil)
+
 			c.JSON(http.StatusOK, gin.H{
 				"message": "SSH configuration deleted successfully",
 			})
@@ -162,9 +244,16 @@ func (h *SSHHandler) Test(c *gin.Context) {
 		return
 	}
 
-	// Get auth method
-	auth, err := sshConfig.GetAuthMethod()
+	// Resolve jump hosts and auth methods
+	target, err := toDeploySSHConfig(h.config, *sshConfig)
 	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if _, err := target.ClientConfig(nil); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": fmt.Sprintf("Auth error: %v", err),
@@ -172,26 +261,38 @@ func (h *SSHHandler) Test(c *gin.Context) {
 		return
 	}
 
-	// Create SSH client
-	client, err := ssh.New(sshConfig.User, sshConfig.Host, auth)
+	// Reuse the pooled connection deployments use, dialing it on the configured
+	// port through any jump hosts if there is none. Every hop must present its
+	// pinned host key.
+	client, release, err := h.engine.Connect(target)
 	if err != nil {
-		c.JSON(http.StatusOK, gin.H{
+		h.bus.ReportHealth(name, false, err.Error())
+		response := gin.H{
 			"success": false,
 			"message": fmt.Sprintf("Connection failed: %v", err),
-		})
+		}
+
+		// Let the UI ask to verify an unknown or changed host key
+		var hostErr *ssh.HostKeyError
+		if errors.As(err, &hostErr) {
+			response["host_key"] = hostKeyInfo(hostErr.Host, hostErr.Key, hostErr)
+		}
+		c.JSON(http.StatusOK, response)
 		return
 	}
-	defer client.Close()
+	defer release()
 
 	// Run test command
 	output, err := client.Run("echo 'SSH connection successful'")
 	if err != nil {
+		h.bus.ReportHealth(name, false, err.Error())
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": fmt.Sprintf("Command failed: %v", err),
 		})
 		return
 	}
+	h.bus.ReportHealth(name, true, "")
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
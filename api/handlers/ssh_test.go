@@ -596,3 +596,101 @@ func TestUpdateSSHConfig_InvalidJSON(t *testing.T) {
 		t.Error("Response missing 'error' field")
 	}
 }
+
+func TestCreateSSHConfig_InvalidSudo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{
+		SSHConfigs: []SSHConfig{},
+	}
+
+	handler := NewSSHHandler(config)
+	router := gin.New()
+	router.POST("/api/ssh", handler.Create)
+
+	body := `{"name":"server1","host":"host1.com","user":"deploy","auth_type":"password","sudo":{"mode":"always"}}`
+	req := httptest.NewRequest("POST", "/api/ssh", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+	if len(config.SSHConfigs) != 0 {
+		t.Error("Expected invalid config not to be saved")
+	}
+}
+
+func TestCreateSSHConfig_InvalidJump(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unknown jump host", `{"name":"server2","host":"10.0.0.2","jump":"missing"}`},
+		{"jump through itself", `{"name":"server2","host":"10.0.0.2","jump":"server2"}`},
+		{"cycle", `{"name":"server2","host":"10.0.0.2","jump":"server1"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				SSHConfigs: []SSHConfig{{Name: "server1", Host: "10.0.0.1", Jump: "server2"}},
+			}
+
+			handler := NewSSHHandler(config)
+			router := gin.New()
+			router.POST("/api/ssh", handler.Create)
+
+			req := httptest.NewRequest("POST", "/api/ssh", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", w.Code)
+			}
+			if len(config.SSHConfigs) != 1 {
+				t.Error("Expected invalid config not to be saved")
+			}
+		})
+	}
+}
+
+func TestCreateSSHConfig_InvalidAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"unknown auth type", `{"name":"server2","host":"10.0.0.2","auth_type":"kerberos"}`},
+		{"unknown auth method", `{"name":"server2","host":"10.0.0.2","auth_methods":["key","kerberos"],"key_file":"/keys/id"}`},
+		{"inline key without key", `{"name":"server2","host":"10.0.0.2","auth_type":"inline-key"}`},
+		{"invalid inline key", `{"name":"server2","host":"10.0.0.2","auth_type":"inline-key","private_key":"not a key"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{SSHConfigs: []SSHConfig{}}
+
+			handler := NewSSHHandler(config)
+			router := gin.New()
+			router.POST("/api/ssh", handler.Create)
+
+			req := httptest.NewRequest("POST", "/api/ssh", bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("Expected status 400, got %d", w.Code)
+			}
+			if len(config.SSHConfigs) != 0 {
+				t.Error("Expected invalid config not to be saved")
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/diiyw/ed/api/bus"
+	"github.com/diiyw/ed/ssh"
+	"github.com/gin-gonic/gin"
+)
+
+// ImportHost is a host of an OpenSSH config offered for import
+type ImportHost struct {
+	Config   SSHConfig `json:"config"`
+	Conflict bool      `json:"conflict,omitempty"` // an SSH configuration with this name exists
+	Warnings []string  `json:"warnings,omitempty"`
+}
+
+// ImportResult reports the outcome of an import
+type ImportResult struct {
+	Imported  []string          `json:"imported"`
+	Conflicts []string          `json:"conflicts,omitempty"` // existing names, left untouched
+	Skipped   map[string]string `json:"skipped,omitempty"`   // reason by host
+}
+
+// ImportRequest holds an uploaded OpenSSH config and the hosts to import.
+// Uploaded content may not use Include. Without content, ~/.ssh/config of the
+// server user is read, following its includes.
+type ImportRequest struct {
+	Content string   `json:"content,omitempty"`
+	Hosts   []string `json:"hosts,omitempty"`
+}
+
+// ImportHosts converts the hosts of an OpenSSH config to SSH configurations,
+// flagging the ones whose name is taken
+func ImportHosts(config *Config, hosts []ssh.HostConfig) []ImportHost {
+	aliases := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		aliases[host.Alias] = true
+	}
+	existing := make(map[string]bool, len(config.SSHConfigs))
+	for _, cfg := range config.SSHConfigs {
+		existing[cfg.Name] = true
+	}
+
+	result := make([]ImportHost, len(hosts))
+	for i, host := range hosts {
+		port := host.Port
+		if port == 0 {
+			port = ssh.DefaultPort
+		}
+		cfg := SSHConfig{
+			Name:     host.Alias,
+			Host:     host.HostName,
+			Port:     port,
+			User:     host.User,
+			AuthType: "agent",
+		}
+		if host.IdentityFile != "" {
+			cfg.AuthType = "key"
+			cfg.KeyFile = host.IdentityFile
+		}
+
+		var warnings []string
+		if cfg.User == "" {
+			warnings = append(warnings, "No User set, add one before deploying")
+		}
+		if host.ProxyJump != "" {
+			jump, err := importJump(host.ProxyJump)
+			if err == nil && !aliases[jump] && !existing[jump] {
+				err = fmt.Errorf("ProxyJump '%s' is not a known host", jump)
+			}
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%v, set the jump host manually", err))
+			} else {
+				cfg.Jump = jump
+			}
+		}
+
+		result[i] = ImportHost{Config: cfg, Conflict: existing[cfg.Name], Warnings: warnings}
+	}
+	return result
+}
+
+// importJump returns the host named by a single hop ProxyJump
+func importJump(proxyJump string) (string, error) {
+	if strings.Contains(proxyJump, ",") {
+		return "", fmt.Errorf("ProxyJump chain '%s' is not supported", proxyJump)
+	}
+	jump := proxyJump
+	if at := strings.LastIndex(jump, "@"); at >= 0 {
+		jump = jump[at+1:]
+	}
+	if host, _, err := net.SplitHostPort(jump); err == nil {
+		jump = host
+	}
+	return jump, nil
+}
+
+// ApplyImport adds the picked hosts to the SSH configurations. Hosts whose
+// name is taken are reported as conflicts, and hosts whose jump host is
+// neither configured nor imported along are skipped.
+func ApplyImport(config *Config, candidates []ImportHost, names []string) ImportResult {
+	result := ImportResult{Imported: []string{}, Skipped: map[string]string{}}
+
+	byName := make(map[string]ImportHost, len(candidates))
+	for _, candidate := range candidates {
+		byName[candidate.Config.Name] = candidate
+	}
+
+	var picked []SSHConfig
+	pickedNames := make(map[string]bool)
+	for _, name := range names {
+		candidate, exists := byName[name]
+		switch {
+		case !exists:
+			result.Skipped[name] = "Host not found in SSH config"
+		case candidate.Conflict:
+			result.Conflicts = append(result.Conflicts, name)
+		case !pickedNames[name]:
+			picked = append(picked, candidate.Config)
+			pickedNames[name] = true
+		}
+	}
+
+	// Jump hosts must resolve once the picked hosts are added, skipping a host
+	// may break the hosts jumping through it
+	for changed := true; changed; {
+		changed = false
+		configs := append([]SSHConfig{}, config.SSHConfigs...)
+		for _, cfg := range picked {
+			if _, skipped := result.Skipped[cfg.Name]; !skipped {
+				configs = append(configs, cfg)
+			}
+		}
+		for _, cfg := range picked {
+			if _, skipped := result.Skipped[cfg.Name]; skipped {
+				continue
+			}
+			if _, err := toDeploySSHConfig(&Config{SSHConfigs: configs}, cfg); err != nil {
+				result.Skipped[cfg.Name] = err.Error()
+				changed = true
+			}
+		}
+	}
+
+	for _, cfg := range picked {
+		if _, skipped := result.Skipped[cfg.Name]; skipped {
+			continue
+		}
+		config.SSHConfigs = append(config.SSHConfigs, cfg)
+		result.Imported = append(result.Imported, cfg.Name)
+	}
+	if len(result.Skipped) == 0 {
+		result.Skipped = nil
+	}
+	return result
+}
+
+// importCandidates parses the OpenSSH config of a request
+func (h *SSHHandler) importCandidates(req ImportRequest) ([]ImportHost, error) {
+	var hosts []ssh.HostConfig
+	var err error
+	if req.Content != "" {
+		// Uploaded content must not read files of the server
+		hosts, err = ssh.ParseConfigWithoutIncludes(strings.NewReader(req.Content))
+	} else {
+		var file string
+		if file, err = ssh.DefaultConfigFile(); err == nil {
+			hosts, err = ssh.ReadConfigFile(file)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read SSH config: %v", err)
+	}
+	return ImportHosts(h.config, hosts), nil
+}
+
+// PreviewImport lists the hosts of an OpenSSH config that can be imported
+func (h *SSHHandler) PreviewImport(c *gin.Context) {
+	var req ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+
+	candidates, err := h.importCandidates(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": gin.H{
+			"hosts": candidates,
+		},
+	})
+}
+
+// Import adds the picked hosts of an OpenSSH config as SSH configurations
+func (h *SSHHandler) Import(c *gin.Context) {
+	var req ImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
+	}
+	if len(req.Hosts) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "No hosts picked for import",
+		})
+		return
+	}
+
+	candidates, err := h.importCandidates(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	result := ApplyImport(h.config, candidates, req.Hosts)
+	if len(result.Imported) > 0 {
+		if err := SaveConfig("config.json", h.config); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("Failed to save configuration: %v", err),
+			})
+			return
+		}
+	}
+
+	for _, name := range result.Imported {
+		for _, cfg := range h.config.SSHConfigs {
+			if cfg.Name == name {
+				h.bus.Publish(bus.SSHCreated, name, cfg.Summary())
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":    result,
+		"message": fmt.Sprintf("Imported %d SSH configurations", len(result.Imported)),
+	})
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Unit Tests for OpenSSH Config Import
+
+// testSSHConfigFile is an OpenSSH config including the files of DIR
+const testSSHConfigFile = `
+# bastion in front of the web servers
+Host bastion
+    HostName bastion.example.com
+    User admin
+    Port 2222
+
+Host web-* !web-old
+    User deploy
+    ProxyJump admin@bastion:2222
+    IdentityFile /keys/%h
+
+Include DIR/*.conf
+
+Host *
+    User fallback
+`
+
+func newImportRouter(config *Config) *gin.Engine {
+	handler := NewSSHHandler(config)
+	router := gin.New()
+	router.POST("/api/ssh/import", handler.Import)
+	router.POST("/api/ssh/import/preview", handler.PreviewImport)
+	return router
+}
+
+func postImport(router *gin.Engine, url string, req ImportRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", url, bytes.NewReader(body)))
+	return w
+}
+
+// testIncludedConfig is the config file included from DIR
+const testIncludedConfig = "Host web-1 web-old\n    HostName = \"%h.internal\"\nHost db\n    ProxyJump a,b\n"
+
+// testImportRequest uploads testSSHConfigFile with its include inlined, as
+// uploaded content may not use Include
+func testImportRequest(t *testing.T) ImportRequest {
+	t.Helper()
+
+	return ImportRequest{Content: strings.ReplaceAll(testSSHConfigFile, "Include DIR/*.conf\n", testIncludedConfig)}
+}
+
+func TestPreviewImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// The config file of the server user follows its includes
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".ssh")
+	if err := os.MkdirAll(filepath.Join(dir, "conf.d"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "conf.d", "web.conf"), []byte(testIncludedConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config"), []byte(strings.ReplaceAll(testSSHConfigFile, "DIR", "conf.d")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, req := range []ImportRequest{testImportRequest(t), {}} {
+		testPreviewImport(t, req)
+	}
+}
+
+// testPreviewImport checks the preview of testSSHConfigFile
+func testPreviewImport(t *testing.T, req ImportRequest) {
+	t.Helper()
+
+	config := &Config{SSHConfigs: []SSHConfig{{Name: "db", Host: "10.0.0.5"}}}
+	w := postImport(newImportRouter(config), "/api/ssh/import/preview", req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data struct {
+			Hosts []ImportHost `json:"hosts"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+
+	hosts := map[string]ImportHost{}
+	var names []string
+	for _, host := range response.Data.Hosts {
+		hosts[host.Config.Name] = host
+		names = append(names, host.Config.Name)
+	}
+	if len(names) != 4 || names[0] != "bastion" || names[1] != "web-1" || names[2] != "web-old" || names[3] != "db" {
+		t.Fatalf("Expected the named hosts in file order, got %v", names)
+	}
+
+	bastion := hosts["bastion"].Config
+	if bastion.Host != "bastion.example.com" || bastion.Port != 2222 || bastion.User != "admin" || bastion.AuthType != "agent" {
+		t.Errorf("Unexpected bastion config %+v", bastion)
+	}
+	web := hosts["web-1"].Config
+	if web.Host != "web-1.internal" || web.Port != 22 || web.User != "deploy" || web.AuthType != "key" || web.KeyFile != "/keys/web-1.internal" || web.Jump != "bastion" {
+		t.Errorf("Unexpected web-1 config %+v", web)
+	}
+	if old := hosts["web-old"].Config; old.User != "fallback" || old.Jump != "" {
+		t.Errorf("Expected the negated host to only get the defaults, got %+v", old)
+	}
+	if db := hosts["db"]; !db.Conflict || db.Config.Jump != "" || len(db.Warnings) != 1 {
+		t.Errorf("Expected db to conflict and warn about its jump chain, got %+v", db)
+	}
+}
+
+func TestImport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	config := &Config{SSHConfigs: []SSHConfig{{Name: "db", Host: "10.0.0.5"}}}
+	router := newImportRouter(config)
+
+	// A host is skipped when its jump host is not imported along
+	req := testImportRequest(t)
+	req.Hosts = []string{"web-1", "db", "missing"}
+	w := postImport(router, "/api/ssh/import", req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var response struct {
+		Data ImportResult `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to parse response: %v", err)
+	}
+	result := response.Data
+	if len(result.Imported) != 0 || len(result.Conflicts) != 1 || result.Conflicts[0] != "db" || len(result.Skipped) != 2 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+
+	req.Hosts = []string{"web-1", "bastion"}
+	w = postImport(router, "/api/ssh/import", req)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if len(response.Data.Imported) != 2 || len(config.SSHConfigs) != 3 {
+		t.Fatalf("Expected web-1 and bastion to be imported, got %+v", response.Data)
+	}
+	if config.SSHConfigs[0].Host != "10.0.0.5" {
+		t.Errorf("Expected the existing db config to be kept, got %+v", config.SSHConfigs[0])
+	}
+}
+
+func TestImport_NoHosts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := postImport(newImportRouter(&Config{}), "/api/ssh/import", ImportRequest{Content: "Host a\n"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestPreviewImport_UploadedInclude(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	secret := filepath.Join(t.TempDir(), "passwd")
+	if err := os.WriteFile(secret, []byte("root:x:0:0:secret-entry:/root:/bin/bash\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{secret, "/etc/passwd"} {
+		req := ImportRequest{Content: "Host a\n    HostName 10.0.0.1\nInclude " + file + "\n"}
+		w := postImport(newImportRouter(&Config{}), "/api/ssh/import/preview", req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for an uploaded Include of %s, got %d", file, w.Code)
+		}
+		if strings.Contains(w.Body.String(), "root") {
+			t.Errorf("Expected the included file not to be read, got %s", w.Body.String())
+		}
+	}
+}
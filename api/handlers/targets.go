@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/diiyw/ed/api/deploy"
+)
+
+// resolveSSHConfigs resolves deploy server entries into SSH configs at deploy time.
+// An entry is either the name of an SSH config or a selector:
+//
+//	tag:web            servers tagged "web"
+//	group:eu-west      servers with the label group=eu-west
+//	label:role=worker  servers with the label role=worker
+//
+// It returns the resolved server names in order without duplicates, and their SSH configs.
+func resolveSSHConfigs(config *Config, entries []string) ([]string, map[string]*deploy.SSHConfig, error) {
+	serverNames := make([]string, 0, len(entries))
+	sshConfigs := make(map[string]*deploy.SSHConfig, len(entries))
+
+	add := func(cfg SSHConfig) error {
+		if _, exists := sshConfigs[cfg.Name]; exists {
+			return nil
+		}
+		sshConfig, err := toDeploySSHConfig(config, cfg)
+		if err != nil {
+			return err
+		}
+		serverNames = append(serverNames, cfg.Name)
+		sshConfigs[cfg.Name] = sshConfig
+		return nil
+	}
+
+	for _, entry := range entries {
+		match, isSelector, err := parseSelector(entry)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		found := false
+		for _, cfg := range config.SSHConfigs {
+			if (isSelector && match(cfg)) || (!isSelector && cfg.Name == entry) {
+				if err := add(cfg); err != nil {
+					return nil, nil, err
+				}
+				found = true
+				if !isSelector {
+					break
+				}
+			}
+		}
+
+		if !found {
+			if isSelector {
+				return nil, nil, fmt.Errorf("Deploy selector '%s' matches no SSH configurations", entry)
+			}
+			return nil, nil, fmt.Errorf("Deploy server '%s' not found in SSH configurations", entry)
+		}
+	}
+
+	return serverNames, sshConfigs, nil
+}
+
+// parseSelector parses a deploy server selector. It reports whether entry is a
+// selector at all, entries without a known selector prefix are server names.
+func parseSelector(entry string) (func(SSHConfig) bool, bool, error) {
+	kind, value, ok := strings.Cut(entry, ":")
+	if !ok || (kind != "tag" && kind != "group" && kind != "label") {
+		return nil, false, nil
+	}
+
+	if value == "" {
+		return nil, true, fmt.Errorf("Invalid deploy selector '%s': empty value", entry)
+	}
+
+	switch kind {
+	case "tag":
+		return func(cfg SSHConfig) bool {
+			return slices.Contains(cfg.Tags, value)
+		}, true, nil
+	case "group":
+		return func(cfg SSHConfig) bool {
+			return cfg.Labels["group"] == value
+		}, true, nil
+	default:
+		key, labelValue, ok := strings.Cut(value, "=")
+		if !ok || key == "" {
+			return nil, true, fmt.Errorf("Invalid deploy selector '%s': expected label:key=value", entry)
+		}
+		return func(cfg SSHConfig) bool {
+			v, exists := cfg.Labels[key]
+			return exists && v == labelValue
+		}, true, nil
+	}
+}
+
+// selectCanaryServers selects the canary servers among resolved server names,
+// either the first canary.Count servers or the servers tagged canary.Tag.
+func selectCanaryServers(config *Config, canary *CanaryConfig, serverNames []string) ([]string, error) {
+	if (canary.Count > 0) == (canary.Tag != "") {
+		return nil, fmt.Errorf("Canary requires either a positive count or a tag")
+	}
+
+	if canary.Count > 0 {
+		return serverNames[:min(canary.Count, len(serverNames))], nil
+	}
+
+	var selected []string
+	for _, name := range serverNames {
+		idx := slices.IndexFunc(config.SSHConfigs, func(cfg SSHConfig) bool {
+			return cfg.Name == name
+		})
+		if idx >= 0 && slices.Contains(config.SSHConfigs[idx].Tags, canary.Tag) {
+			selected = append(selected, name)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("Canary tag '%s' matches none of the deploy servers", canary.Tag)
+	}
+	return selected, nil
+}
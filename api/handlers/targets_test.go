@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Unit Tests for Deploy Target Resolution
+
+func newTargetTestConfig() *Config {
+	return &Config{
+		SSHConfigs: []SSHConfig{
+			{Name: "web1", Host: "web1.example.com", Tags: []string{"web"}, Labels: map[string]string{"group": "eu-west"}},
+			{Name: "web2", Host: "web2.example.com", Tags: []string{"web", "canary"}, Labels: map[string]string{"group": "us-east"}},
+			{Name: "db1", Host: "db1.example.com", Tags: []string{"db"}, Labels: map[string]string{"group": "eu-west", "role": "primary"}},
+			{Name: "legacy:22", Host: "legacy.example.com"},
+		},
+	}
+}
+
+func TestResolveSSHConfigs(t *testing.T) {
+	config := newTargetTestConfig()
+
+	tests := []struct {
+		name     string
+		entries  []string
+		expected []string
+	}{
+		{"plain names", []string{"db1", "web1"}, []string{"db1", "web1"}},
+		{"tag selector", []string{"tag:web"}, []string{"web1", "web2"}},
+		{"group selector", []string{"group:eu-west"}, []string{"web1", "db1"}},
+		{"label selector", []string{"label:role=primary"}, []string{"db1"}},
+		{"duplicates removed", []string{"web2", "tag:web", "group:eu-west"}, []string{"web2", "web1", "db1"}},
+		{"name containing a colon", []string{"legacy:22"}, []string{"legacy:22"}},
+		{"no entries", []string{}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			names, sshConfigs, err := resolveSSHConfigs(config, tt.entries)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("Expected servers %v, got %v", tt.expected, names)
+			}
+			if len(sshConfigs) != len(tt.expected) {
+				t.Errorf("Expected %d SSH configs, got %d", len(tt.expected), len(sshConfigs))
+			}
+			for _, name := range names {
+				if sshConfigs[name] == nil || sshConfigs[name].Name != name {
+					t.Errorf("Missing SSH config for %s", name)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveSSHConfigs_Errors(t *testing.T) {
+	config := newTargetTestConfig()
+
+	tests := []struct {
+		name    string
+		entries []string
+	}{
+		{"unknown server", []string{"web1", "nonexistent"}},
+		{"selector without matches", []string{"tag:cache"}},
+		{"empty selector value", []string{"tag:"}},
+		{"malformed label selector", []string{"label:role"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := resolveSSHConfigs(config, tt.entries); err == nil {
+				t.Error("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestSelectCanaryServers(t *testing.T) {
+	config := newTargetTestConfig()
+	servers := []string{"db1", "web2", "web1"}
+
+	tests := []struct {
+		name     string
+		canary   CanaryConfig
+		expected []string
+	}{
+		{"by count", CanaryConfig{Count: 2}, []string{"db1", "web2"}},
+		{"count larger than fleet", CanaryConfig{Count: 5}, []string{"db1", "web2", "web1"}},
+		{"by tag", CanaryConfig{Tag: "web"}, []string{"web2", "web1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected, err := selectCanaryServers(config, &tt.canary, servers)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(selected, tt.expected) {
+				t.Errorf("Expected canary servers %v, got %v", tt.expected, selected)
+			}
+		})
+	}
+
+	for _, canary := range []CanaryConfig{{}, {Count: 1, Tag: "web"}, {Tag: "cache"}} {
+		if _, err := selectCanaryServers(config, &canary, servers); err == nil {
+			t.Errorf("Expected error for canary %+v", canary)
+		}
+	}
+}
+
+func TestResolveSSHConfigs_JumpHosts(t *testing.T) {
+	config := &Config{
+		SSHConfigs: []SSHConfig{
+			{Name: "bastion", Host: "bastion.example.com"},
+			{Name: "inner", Host: "10.0.0.1", Jump: "bastion"},
+			{Name: "web1", Host: "10.0.1.1", Jump: "inner"},
+			{Name: "lost", Host: "10.0.1.2", Jump: "missing"},
+			{Name: "loop1", Host: "10.0.1.3", Jump: "loop2"},
+			{Name: "loop2", Host: "10.0.1.4", Jump: "loop1"},
+		},
+	}
+
+	_, sshConfigs, err := resolveSSHConfigs(config, []string{"web1"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	jump := sshConfigs["web1"].Jump
+	if jump == nil || jump.Name != "inner" || jump.Jump == nil || jump.Jump.Name != "bastion" || jump.Jump.Jump != nil {
+		t.Errorf("Expected chain web1 -> inner -> bastion, got %+v", jump)
+	}
+
+	for _, entry := range []string{"lost", "loop1"} {
+		if _, _, err := resolveSSHConfigs(config, []string{entry}); err == nil {
+			t.Errorf("Expected an error resolving %s", entry)
+		}
+	}
+}
@@ -5,35 +5,196 @@ import (
 	"os"
 	"time"
 
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/diiyw/ed/api/notify"
 	"github.com/diiyw/ed/ssh"
 )
 
 // SSHConfig represents an SSH server configuration
 type SSHConfig struct {
-	Name     string `json:"name"`
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	User     string `json:"user"`
-	AuthType string `json:"auth_type"` // "password", "key", "agent"
-	Password string `json:"password,omitempty"`
-	KeyFile  string `json:"key_file,omitempty"`
-	KeyPass  string `json:"key_pass,omitempty"`
+	Name     string            `json:"name"`
+	Host     string            `json:"host"`
+	Port     int               `json:"port"`
+	User     string            `json:"user"`
+	AuthType string            `json:"auth_type"` // "password", "keyboard-interactive", "key", "inline-key", "certificate", "agent"
+	Password string            `json:"password,omitempty"`
+	KeyFile  string            `json:"key_file,omitempty"`
+	KeyPass  string            `json:"key_pass,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"` // e.g. "group": "eu-west"
+	Sudo     *deploy.Sudo      `json:"sudo,omitempty"`
+	Jump     string            `json:"jump,omitempty"` // name of the SSH config to connect through
+
+	PrivateKey  string   `json:"private_key,omitempty"`  // PEM private key of the inline-key auth type
+	CertFile    string   `json:"cert_file,omitempty"`    // certificate of the certificate auth type, key_file-cert.pub if empty
+	AuthMethods []string `json:"auth_methods,omitempty"` // auth types tried in order, e.g. "key" then "password"
+}
+
+// SSHSummary is an SSH configuration without its credentials, as published
+// to every subscriber of the live change stream
+type SSHSummary struct {
+	Name     string   `json:"name"`
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	User     string   `json:"user"`
+	Tags     []string `json:"tags,omitempty"`
+	AuthType string   `json:"auth_type"`
+}
+
+// Summary returns the SSH configuration without its credentials
+func (c SSHConfig) Summary() SSHSummary {
+	return SSHSummary{
+		Name:     c.Name,
+		Host:     c.Host,
+		Port:     c.Port,
+		User:     c.User,
+		Tags:     c.Tags,
+		AuthType: c.AuthType,
+	}
 }
 
 // Project represents a deployable project
 type Project struct {
-	Name              string    `json:"name"`
-	BuildInstructions string    `json:"build_instructions"`
-	DeployScript      string    `json:"deploy_script"`
-	DeployServers     []string  `json:"deploy_servers"` // names of SSH configs
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	Name              string                `json:"name"`
+	BuildInstructions string                `json:"build_instructions"`
+	DeployScript      string                `json:"deploy_script"`
+	DeployServers     []string              `json:"deploy_servers"` // names of SSH configs or selectors, see resolveSSHConfigs
+	Webhook           *WebhookConfig        `json:"webhook,omitempty"`
+	Approval          *ApprovalConfig       `json:"approval,omitempty"`
+	Canary            *CanaryConfig         `json:"canary,omitempty"`
+	TTY               *TTYConfig            `json:"tty,omitempty"`
+	Steps             []deploy.Step         `json:"steps,omitempty"` // run after the deploy script
+	Templates         []deploy.Template     `json:"templates,omitempty"`
+	Handlers          map[string]string     `json:"handlers,omitempty"` // scripts run when a template changes, by name
+	Freezes           []FreezeWindow        `json:"freezes,omitempty"`
+	Environments      []Environment         `json:"environments,omitempty"` // in promotion order
+	Notify            []notify.Subscription `json:"notify,omitempty"`
+	CreatedAt         time.Time             `json:"created_at"`
+	UpdatedAt         time.Time             `json:"updated_at"`
+}
+
+// ProjectSummary is a project without its webhook secret and scripts, as
+// published to every subscriber of the live change stream
+type ProjectSummary struct {
+	Name          string    `json:"name"`
+	DeployServers []string  `json:"deploy_servers"`
+	Environments  []string  `json:"environments,omitempty"` // names, in promotion order
+	Webhook       bool      `json:"webhook"`                // whether webhook triggers are configured
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Summary returns the project without its webhook secret and scripts
+func (p Project) Summary() ProjectSummary {
+	summary := ProjectSummary{
+		Name:          p.Name,
+		DeployServers: p.DeployServers,
+		Webhook:       p.Webhook != nil,
+		CreatedAt:     p.CreatedAt,
+		UpdatedAt:     p.UpdatedAt,
+	}
+	for _, env := range p.Environments {
+		summary.Environments = append(summary.Environments, env.Name)
+	}
+	return summary
+}
+
+// WebhookConfig configures inbound webhook triggers for a project
+type WebhookConfig struct {
+	Secret      string   `json:"secret"`
+	Branches    []string `json:"branches,omitempty"`    // branch name patterns, empty allows all
+	Events      []string `json:"events,omitempty"`      // e.g. "push", "release"; empty allows all
+	Environment string   `json:"environment,omitempty"` // environment to deploy, empty uses the project servers
+}
+
+// Environment is a named deployment target of a project, e.g. "staging" or "prod"
+type Environment struct {
+	Name          string            `json:"name"`
+	DeployServers []string          `json:"deploy_servers"` // names of SSH configs or selectors
+	Variables     map[string]string `json:"variables,omitempty"`
+	Approval      *ApprovalConfig   `json:"approval,omitempty"` // overrides the project approval
+	Canary        *CanaryConfig     `json:"canary,omitempty"`   // overrides the project canary
+	TTY           *TTYConfig        `json:"tty,omitempty"`      // overrides the project terminal
+	Freezes       []FreezeWindow    `json:"freezes,omitempty"`  // in addition to the project freezes
+}
+
+// ApprovalConfig configures the approval gate of a project
+type ApprovalConfig struct {
+	Required bool `json:"required"`
+	Timeout  int  `json:"timeout,omitempty"` // minutes until a pending request expires, 0 uses the default
+}
+
+// CanaryConfig configures a canary stage for project deployments. The canary
+// servers are selected either by count or by tag.
+type CanaryConfig struct {
+	Count          int    `json:"count,omitempty"`           // deploy first to this many servers
+	Tag            string `json:"tag,omitempty"`             // or to the servers with this tag
+	BakeTime       int    `json:"bake_time,omitempty"`       // minutes until automatic promotion, 0 waits for a manual promotion
+	HealthCheck    string `json:"health_check,omitempty"`    // command that must keep succeeding on the canary servers
+	RollbackScript string `json:"rollback_script,omitempty"` // run on the canary servers when aborted, defaults to redeploying the live version
+}
+
+// TTYConfig runs deploy script commands in a pseudo-terminal, so tools show their
+// interactive output. Their stdout and stderr arrive merged with ANSI sequences intact.
+type TTYConfig struct {
+	Term     string   `json:"term,omitempty"`     // terminal type, defaults to xterm-256color
+	Cols     int      `json:"cols,omitempty"`     // defaults to 80
+	Rows     int      `json:"rows,omitempty"`     // defaults to 24
+	Commands []string `json:"commands,omitempty"` // script lines starting with one of these get a terminal, empty for all
+}
+
+// FreezeWindow blocks deploys during a one-off date range, or during a recurring
+// window on given weekdays if Start and End are not set
+type FreezeWindow struct {
+	Name     string     `json:"name"`
+	Reason   string     `json:"reason"`
+	Start    *time.Time `json:"start,omitempty"`
+	End      *time.Time `json:"end,omitempty"`
+	Days     []string   `json:"days,omitempty"`     // e.g. "sat", "sun"; empty means every day
+	From     string     `json:"from,omitempty"`     // "15:04" time of day, empty means midnight
+	To       string     `json:"to,omitempty"`       // "15:04" time of day, before From if the window spans midnight
+	Timezone string     `json:"timezone,omitempty"` // IANA name, empty uses the server timezone
 }
 
 // Config holds all application data
 type Config struct {
-	SSHConfigs []SSHConfig `json:"ssh_configs"`
-	Projects   []Project   `json:"projects"`
+	SSHConfigs           []SSHConfig            `json:"ssh_configs"`
+	Projects             []Project              `json:"projects"`
+	Notifications        []notify.ChannelConfig `json:"notifications,omitempty"`
+	Freezes              []FreezeWindow         `json:"freezes,omitempty"`                // global freeze windows
+	FreezeOverrideUsers  []string               `json:"freeze_override_users,omitempty"`  // users allowed to deploy during a freeze
+	FreezeOverrideTokens map[string]string      `json:"freeze_override_tokens,omitempty"` // secret token of each override user, only set in config.json
+	Workers              int                    `json:"workers,omitempty"`                // deployments run at once, 0 uses the default
+	Logs                 *LogConfig             `json:"logs,omitempty"`
+	KnownHosts           string                 `json:"known_hosts,omitempty"` // file server host keys are pinned in
+	KeysDir              string                 `json:"keys_dir,omitempty"`    // directory generated deploy keys are stored in
+}
+
+// KnownHostsFile returns the file server host keys are pinned in
+func (c *Config) KnownHostsFile() string {
+	if c.KnownHosts == "" {
+		return deploy.DefaultKnownHostsFile
+	}
+	return c.KnownHosts
+}
+
+// DefaultKeysDir is the directory generated deploy keys are stored in
+const DefaultKeysDir = "keys"
+
+// KeysDirectory returns the directory generated deploy keys are stored in
+func (c *Config) KeysDirectory() string {
+	if c.KeysDir == "" {
+		return DefaultKeysDir
+	}
+	return c.KeysDir
+}
+
+// LogConfig configures the storage of deployment logs
+type LogConfig struct {
+	Dir           string `json:"dir,omitempty"`             // logs are stored on disk when set
+	MaxSize       int    `json:"max_size,omitempty"`        // MB of output kept per deployment, 0 uses the default
+	MaxAge        int    `json:"max_age,omitempty"`         // days finished logs are kept, 0 keeps them forever
+	MaxPerProject int    `json:"max_per_project,omitempty"` // finished logs kept per project, 0 keeps all
 }
 
 // LoadConfig loads configuration from JSON file
@@ -63,16 +224,22 @@ func SaveConfig(filename string, config *Config) error {
 	return os.WriteFile(filename, data, 0644)
 }
 
-// GetAuthMethod returns the SSH auth method for this config
-func (sc *SSHConfig) GetAuthMethod() (ssh.Auth, error) {
-	switch sc.AuthType {
-	case "password":
-		return ssh.Password(sc.Password), nil
-	case "key":
-		return ssh.Key(sc.KeyFile, sc.KeyPass)
-	case "agent":
-		return ssh.UseAgent()
-	default:
-		return nil, nil
+// NotificationChannels returns the configured notification channels
+func (c *Config) NotificationChannels() []notify.ChannelConfig {
+	return c.Notifications
+}
+
+// ProjectSubscriptions returns the notification subscriptions of a project
+func (c *Config) ProjectSubscriptions(project string) []notify.Subscription {
+	for _, proj := range c.Projects {
+		if proj.Name == project {
+			return proj.Notify
+		}
 	}
+	return nil
+}
+
+// GetAuthMethod returns the SSH auth methods for this config
+func (sc *SSHConfig) GetAuthMethod() (ssh.Auth, error) {
+	return deploySSHConfig(*sc).GetAuthMethod()
 }
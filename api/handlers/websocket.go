@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/diiyw/ed/api/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// upgrader upgrades deployment log requests to WebSocket connections
+var upgrader = websocket.Upgrader{
+	CheckOrigin: middleware.CheckOrigin,
+}
+
+type WebSocketHandler struct {
+	engine *deploy.Engine
+}
+
+func NewWebSocketHandler(engine *deploy.Engine) *WebSocketHandler {
+	return &WebSocketHandler{engine: engine}
+}
+
+// HandleDeployment streams the events of a deployment over a WebSocket until
+// the deployment finishes or the client disconnects
+func (h *WebSocketHandler) HandleDeployment(c *gin.Context) {
+	deploymentID := c.Param("deploymentId")
+	if _, exists := h.engine.GetStatus(deploymentID); !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Deployment not found",
+		})
+		return
+	}
+
+	// Subscribe before the upgrade so no event after the handshake is missed
+	events, cancel := h.engine.Subscribe(deploymentID)
+	defer cancel()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Read until the client goes away, so its close frame is handled
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
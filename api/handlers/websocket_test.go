@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Unit Tests for WebSocket Handler
+
+func TestHandleDeployment(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine := deploy.NewEngine()
+	engine.RequestApproval("deploy-1", &deploy.Project{Name: "project1", BuildInstructions: "step 1"}, nil, nil, 0)
+
+	handler := NewWebSocketHandler(engine)
+	router := gin.New()
+	router.GET("/ws/deploy/:deploymentId", handler.HandleDeployment)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws/deploy/deploy-1"
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+
+	// The handler subscribes before the handshake completes
+	if err := engine.Approve("deploy-1", "alice", ""); err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+
+	var events []deploy.Event
+	for {
+		var event deploy.Event
+		if err := conn.ReadJSON(&event); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			break
+		}
+		events = append(events, event)
+	}
+
+	if len(events) == 0 {
+		t.Fatal("Expected deployment events")
+	}
+	if last := events[len(events)-1]; last.Data != "Deployment completed successfully" {
+		t.Errorf("Expected completion as last event, got %+v", last)
+	}
+}
+
+func TestHandleDeployment_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := NewWebSocketHandler(deploy.NewEngine())
+	router := gin.New()
+	router.GET("/ws/deploy/:deploymentId", handler.HandleDeployment)
+
+	req := httptest.NewRequest("GET", "/ws/deploy/nonexistent", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
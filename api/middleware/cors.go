@@ -1,21 +1,42 @@
 package middleware
 
 import (
+	"net/http"
+	"net/url"
+	"slices"
+
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+// AllowedOrigins are the cross-origin frontends allowed to call the API
+var AllowedOrigins = []string{
+	"http://localhost:5173", // Vite dev server
+	"http://localhost:3000", // Alternative dev port
+	"http://localhost:8080", // Same origin
+}
+
 // SetupCORS configures CORS middleware for the API
 func SetupCORS() gin.HandlerFunc {
 	config := cors.DefaultConfig()
-	config.AllowOrigins = []string{
-		"http://localhost:5173", // Vite dev server
-		"http://localhost:3000", // Alternative dev port
-		"http://localhost:8080", // Same origin
-	}
+	config.AllowOrigins = AllowedOrigins
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
 	config.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
 	config.AllowCredentials = true
 
 	return cors.New(config)
 }
+
+// CheckOrigin reports whether a WebSocket upgrade request comes from the same
+// host or from one of the allowed origins
+func CheckOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if slices.Contains(AllowedOrigins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && u.Host == r.Host
+}
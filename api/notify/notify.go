@@ -0,0 +1,238 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"text/template"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+)
+
+// Channel types
+const (
+	TypeWebhook    = "webhook"
+	TypeSlack      = "slack"
+	TypeMattermost = "mattermost"
+	TypeEmail      = "email"
+)
+
+// DefaultTemplate is the message template used when a channel has none
+const DefaultTemplate = `Deployment {{.ID}} of {{.Project}}{{if .Environment}} ({{.Environment}}){{end}} {{.Event}}{{if .Error}}: {{.Error}}{{end}}`
+
+// ChannelConfig configures a notification channel
+type ChannelConfig struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"` // "webhook", "slack", "mattermost", "email"
+	URL      string      `json:"url,omitempty"`
+	Channel  string      `json:"channel,omitempty"`  // slack/mattermost channel override
+	Username string      `json:"username,omitempty"` // slack/mattermost username override
+	SMTP     *SMTPConfig `json:"smtp,omitempty"`
+	Template string      `json:"template,omitempty"`
+}
+
+// SMTPConfig configures delivery of email notifications
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// Subscription subscribes a project to the events of a notification channel
+type Subscription struct {
+	Channel  string   `json:"channel"`
+	Events   []string `json:"events,omitempty"`   // "started", "succeeded", "failed", "cancelled"; empty subscribes all
+	Template string   `json:"template,omitempty"` // overrides the channel template
+}
+
+// Source provides the notification channels and project subscriptions
+type Source interface {
+	NotificationChannels() []ChannelConfig
+	ProjectSubscriptions(project string) []Subscription
+}
+
+// Event is the data rendered into message templates and sent to webhooks
+type Event struct {
+	Event       string    `json:"event"`
+	ID          string    `json:"deploymentId"`
+	Project     string    `json:"project"`
+	Environment string    `json:"environment,omitempty"`
+	Version     string    `json:"version,omitempty"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Notifier delivers deployment lifecycle events to subscribed channels
+type Notifier struct {
+	// Attempts is the number of delivery attempts per notification
+	Attempts int
+	// Backoff is the delay before the first retry, doubled on each further retry
+	Backoff time.Duration
+	// Timeout limits a single delivery attempt
+	Timeout time.Duration
+
+	engine *deploy.Engine
+	source Source
+	client *http.Client
+}
+
+// New creates a notifier delivering the lifecycle events of engine
+func New(engine *deploy.Engine, source Source) *Notifier {
+	n := &Notifier{
+		Attempts: 3,
+		Backoff:  time.Second,
+		Timeout:  10 * time.Second,
+		engine:   engine,
+		source:   source,
+		client:   &http.Client{},
+	}
+	engine.AddHook(n.handle)
+	return n
+}
+
+// handle dispatches a lifecycle event to all subscribed channels in the background
+func (n *Notifier) handle(event string, status deploy.DeploymentStatus) {
+	if event == deploy.EventQueued {
+		return
+	}
+
+	channels := n.source.NotificationChannels()
+
+	for _, sub := range n.source.ProjectSubscriptions(status.ProjectName) {
+		if len(sub.Events) > 0 && !slices.Contains(sub.Events, event) {
+			continue
+		}
+
+		idx := slices.IndexFunc(channels, func(ch ChannelConfig) bool {
+			return ch.Name == sub.Channel
+		})
+		if idx < 0 {
+			continue
+		}
+
+		channel := channels[idx]
+		if sub.Template != "" {
+			channel.Template = sub.Template
+		}
+
+		go n.deliver(channel, newEvent(event, status))
+	}
+}
+
+// deliver sends an event to a channel with retries and records the result
+func (n *Notifier) deliver(channel ChannelConfig, event Event) {
+	result := deploy.NotificationResult{
+		Channel: channel.Name,
+		Event:   event.Event,
+	}
+
+	var err error
+	backoff := n.Backoff
+	for result.Attempts < n.Attempts {
+		if result.Attempts > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+		result.Attempts++
+
+		if err = n.Send(channel, event); err == nil {
+			break
+		}
+	}
+
+	result.Success = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	result.SentAt = time.Now()
+
+	n.engine.RecordNotification(event.ID, result)
+}
+
+// Send renders and sends an event to a channel once
+func (n *Notifier) Send(channel ChannelConfig, event Event) error {
+	message, err := Render(channel.Template, event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.Timeout)
+	defer cancel()
+
+	switch channel.Type {
+	case TypeWebhook:
+		return n.postJSON(ctx, channel.URL, webhookPayload{Event: event, Message: message})
+	case TypeSlack:
+		return n.postJSON(ctx, channel.URL, chatPayload{Text: message, Channel: channel.Channel, Username: channel.Username})
+	case TypeMattermost:
+		return n.postJSON(ctx, channel.URL, chatPayload{Text: message, Channel: channel.Channel, Username: channel.Username})
+	case TypeEmail:
+		return sendEmail(ctx, channel.SMTP, event, message)
+	default:
+		return fmt.Errorf("unknown channel type: %s", channel.Type)
+	}
+}
+
+// Validate checks that a channel config is complete
+func Validate(channel ChannelConfig) error {
+	if channel.Name == "" {
+		return fmt.Errorf("channel name is required")
+	}
+
+	switch channel.Type {
+	case TypeWebhook, TypeSlack, TypeMattermost:
+		if channel.URL == "" {
+			return fmt.Errorf("channel '%s' requires a url", channel.Name)
+		}
+	case TypeEmail:
+		if channel.SMTP == nil || channel.SMTP.Host == "" || channel.SMTP.From == "" || len(channel.SMTP.To) == 0 {
+			return fmt.Errorf("channel '%s' requires smtp host, from and to", channel.Name)
+		}
+	default:
+		return fmt.Errorf("unknown channel type: %s", channel.Type)
+	}
+
+	if _, err := template.New(channel.Name).Parse(channel.Template); err != nil {
+		return fmt.Errorf("channel '%s' has an invalid template: %w", channel.Name, err)
+	}
+	return nil
+}
+
+// Render renders an event with a message template, or DefaultTemplate if tmpl is empty
+func Render(tmpl string, event Event) (string, error) {
+	if tmpl == "" {
+		tmpl = DefaultTemplate
+	}
+
+	t, err := template.New("message").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("invalid template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, event); err != nil {
+		return "", fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// newEvent builds the template data of a lifecycle event
+func newEvent(event string, status deploy.DeploymentStatus) Event {
+	return Event{
+		Event:       event,
+		ID:          status.ID,
+		Project:     status.ProjectName,
+		Environment: status.Environment,
+		Version:     status.Version,
+		Status:      status.Status,
+		Error:       status.Error,
+		Time:        time.Now(),
+	}
+}
@@ -0,0 +1,387 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/diiyw/ed/api/deploy"
+)
+
+// staticSource is a Source with fixed channels and subscriptions
+type staticSource struct {
+	channels      []ChannelConfig
+	subscriptions map[string][]Subscription
+}
+
+func (s *staticSource) NotificationChannels() []ChannelConfig {
+	return s.channels
+}
+
+func (s *staticSource) ProjectSubscriptions(project string) []Subscription {
+	return s.subscriptions[project]
+}
+
+// smtpStandIn is a minimal SMTP server that records received messages
+type smtpStandIn struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []string
+	rcpts    []string
+}
+
+func newSMTPStandIn(t *testing.T) *smtpStandIn {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	s := &smtpStandIn{listener: listener}
+	go s.serve()
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *smtpStandIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *smtpStandIn) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = io.WriteString(conn, line+"\r\n") }
+
+	reply("220 localhost ESMTP stand-in")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM"):
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO"):
+			s.mu.Lock()
+			s.rcpts = append(s.rcpts, strings.TrimSpace(line[len("RCPT TO:"):]))
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			s.mu.Lock()
+			s.messages = append(s.messages, data.String())
+			s.mu.Unlock()
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func (s *smtpStandIn) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func testEvent() Event {
+	return Event{
+		Event:       deploy.EventFailed,
+		ID:          "api-1",
+		Project:     "api",
+		Environment: "prod",
+		Status:      "failed",
+		Error:       "Build failed",
+		Time:        time.Now(),
+	}
+}
+
+func TestRender(t *testing.T) {
+	message, err := Render("", testEvent())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	expected := "Deployment api-1 of api (prod) failed: Build failed"
+	if message != expected {
+		t.Errorf("Expected %q, got %q", expected, message)
+	}
+
+	message, err = Render("{{.Project}} is {{.Status}}", testEvent())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if message != "api is failed" {
+		t.Errorf("Expected custom template output, got %q", message)
+	}
+
+	if _, err := Render("{{.Project", testEvent()); err == nil {
+		t.Error("Expected error for invalid template")
+	}
+}
+
+func TestSend_HTTPChannels(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel ChannelConfig
+		check   func(t *testing.T, body map[string]interface{})
+	}{
+		{
+			name:    "webhook",
+			channel: ChannelConfig{Name: "hook", Type: TypeWebhook},
+			check: func(t *testing.T, body map[string]interface{}) {
+				if body["deploymentId"] != "api-1" || body["event"] != "failed" || body["project"] != "api" {
+					t.Errorf("Unexpected webhook payload: %v", body)
+				}
+				if body["message"] != "Deployment api-1 of api (prod) failed: Build failed" {
+					t.Errorf("Unexpected webhook message: %v", body["message"])
+				}
+			},
+		},
+		{
+			name:    "slack",
+			channel: ChannelConfig{Name: "slack", Type: TypeSlack, Channel: "#deploys", Template: ":x: {{.Project}}"},
+			check: func(t *testing.T, body map[string]interface{}) {
+				if body["text"] != ":x: api" || body["channel"] != "#deploys" {
+					t.Errorf("Unexpected slack payload: %v", body)
+				}
+			},
+		},
+		{
+			name:    "mattermost",
+			channel: ChannelConfig{Name: "mm", Type: TypeMattermost, Username: "ed"},
+			check: func(t *testing.T, body map[string]interface{}) {
+				if body["text"] == "" || body["username"] != "ed" {
+					t.Errorf("Unexpected mattermost payload: %v", body)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Content-Type") != "application/json" {
+					t.Errorf("Expected JSON content type, got %s", r.Header.Get("Content-Type"))
+				}
+				_ = json.NewDecoder(r.Body).Decode(&body)
+			}))
+			defer server.Close()
+
+			n := New(deploy.NewEngine(), &staticSource{})
+			tt.channel.URL = server.URL
+			if err := n.Send(tt.channel, testEvent()); err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+			tt.check(t, body)
+		})
+	}
+}
+
+func TestSend_HTTPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such hook", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	n := New(deploy.NewEngine(), &staticSource{})
+	err := n.Send(ChannelConfig{Name: "hook", Type: TypeWebhook, URL: server.URL}, testEvent())
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected 404 error, got %v", err)
+	}
+}
+
+func TestSend_Email(t *testing.T) {
+	smtpServer := newSMTPStandIn(t)
+
+	n := New(deploy.NewEngine(), &staticSource{})
+	channel := ChannelConfig{
+		Name: "mail",
+		Type: TypeEmail,
+		SMTP: &SMTPConfig{
+			Host: "127.0.0.1",
+			Port: smtpServer.port(),
+			From: "ed@example.com",
+			To:   []string{"ops@example.com", "dev@example.com"},
+		},
+	}
+
+	if err := n.Send(channel, testEvent()); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	smtpServer.mu.Lock()
+	defer smtpServer.mu.Unlock()
+
+	if len(smtpServer.messages) != 1 {
+		t.Fatalf("Expected 1 message, got %d", len(smtpServer.messages))
+	}
+	if len(smtpServer.rcpts) != 2 {
+		t.Errorf("Expected 2 recipients, got %d", len(smtpServer.rcpts))
+	}
+	message := smtpServer.messages[0]
+	if !strings.Contains(message, "Subject: [ed] api deployment failed") {
+		t.Errorf("Message missing subject: %s", message)
+	}
+	if !strings.Contains(message, "Deployment api-1 of api (prod) failed: Build failed") {
+		t.Errorf("Message missing body: %s", message)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		channel   ChannelConfig
+		expectErr bool
+	}{
+		{"valid webhook", ChannelConfig{Name: "a", Type: TypeWebhook, URL: "http://example.com"}, false},
+		{"valid email", ChannelConfig{Name: "a", Type: TypeEmail, SMTP: &SMTPConfig{Host: "mail", From: "a@b", To: []string{"c@d"}}}, false},
+		{"missing name", ChannelConfig{Type: TypeWebhook, URL: "http://example.com"}, true},
+		{"missing url", ChannelConfig{Name: "a", Type: TypeSlack}, true},
+		{"missing smtp", ChannelConfig{Name: "a", Type: TypeEmail}, true},
+		{"unknown type", ChannelConfig{Name: "a", Type: "pager"}, true},
+		{"invalid template", ChannelConfig{Name: "a", Type: TypeWebhook, URL: "http://example.com", Template: "{{"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.channel)
+			if tt.expectErr && err == nil {
+				t.Error("Expected error but got none")
+			}
+			if !tt.expectErr && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func waitForNotifications(t *testing.T, engine *deploy.Engine, deploymentID string, count int) []deploy.NotificationResult {
+	t.Helper()
+	for i := 0; i < 200; i++ {
+		for _, d := range engine.ListDeployments("") {
+			if d.ID == deploymentID && len(d.Notifications) >= count {
+				return d.Notifications
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Deployment %s did not record %d notifications", deploymentID, count)
+	return nil
+}
+
+func TestNotifier_DeliversLifecycleEvents(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		events = append(events, body["event"].(string))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	engine := deploy.NewEngine()
+	New(engine, &staticSource{
+		channels: []ChannelConfig{{Name: "hook", Type: TypeWebhook, URL: server.URL}},
+		subscriptions: map[string][]Subscription{
+			"api": {{Channel: "hook", Events: []string{deploy.EventSucceeded, deploy.EventFailed}}},
+		},
+	})
+
+	project := &deploy.Project{Name: "api", DeployServers: []string{}}
+	if err := engine.Deploy(context.Background(), "api-1", project, map[string]*deploy.SSHConfig{}); err != nil {
+		t.Fatalf("Deploy failed: %v", err)
+	}
+
+	results := waitForNotifications(t, engine, "api-1", 1)
+	if !results[0].Success || results[0].Channel != "hook" || results[0].Event != deploy.EventSucceeded {
+		t.Errorf("Unexpected notification result: %+v", results[0])
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(events) != 1 || events[0] != deploy.EventSucceeded {
+		t.Errorf("Expected only the succeeded event to be delivered, got %v", events)
+	}
+}
+
+func TestNotifier_RetriesWithBackoff(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	engine := deploy.NewEngine()
+	n := New(engine, &staticSource{
+		channels:      []ChannelConfig{{Name: "hook", Type: TypeWebhook, URL: server.URL}},
+		subscriptions: map[string][]Subscription{"api": {{Channel: "hook", Events: []string{deploy.EventSucceeded}}}},
+	})
+	n.Backoff = 10 * time.Millisecond
+
+	project := &deploy.Project{Name: "api", DeployServers: []string{}}
+	_ = engine.Deploy(context.Background(), "api-1", project, map[string]*deploy.SSHConfig{})
+
+	results := waitForNotifications(t, engine, "api-1", 1)
+	if !results[0].Success || results[0].Attempts != 3 {
+		t.Errorf("Expected success after 3 attempts, got %+v", results[0])
+	}
+}
+
+func TestNotifier_RecordsFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	engine := deploy.NewEngine()
+	n := New(engine, &staticSource{
+		channels:      []ChannelConfig{{Name: "hook", Type: TypeWebhook, URL: server.URL}},
+		subscriptions: map[string][]Subscription{"api": {{Channel: "hook", Events: []string{deploy.EventSucceeded}}}},
+	})
+	n.Attempts = 2
+	n.Backoff = time.Millisecond
+
+	project := &deploy.Project{Name: "api", DeployServers: []string{}}
+	_ = engine.Deploy(context.Background(), "api-1", project, map[string]*deploy.SSHConfig{})
+
+	results := waitForNotifications(t, engine, "api-1", 1)
+	if results[0].Success || results[0].Attempts != 2 || results[0].Error == "" {
+		t.Errorf("Expected failure after 2 attempts, got %+v", results[0])
+	}
+}
@@ -3,10 +3,15 @@ package api
 import (
 	"embed"
 	"io/fs"
+	"log"
 	"net/http"
+	"time"
 
+	"github.com/diiyw/ed/api/bus"
+	"github.com/diiyw/ed/api/deploy"
 	"github.com/diiyw/ed/api/handlers"
 	"github.com/diiyw/ed/api/middleware"
+	"github.com/diiyw/ed/api/notify"
 	"github.com/gin-gonic/gin"
 )
 
@@ -20,9 +25,30 @@ func SetupRouter(config *handlers.Config, embeddedFS *embed.FS) *gin.Engine {
 	router.Use(middleware.ErrorHandler())
 	router.Use(middleware.RequestLogger())
 
+	// Create deployment engine, notifier, event bus and worker pool
+	engine := deploy.NewEngine()
+	notifier := notify.New(engine, config)
+	events := bus.New(engine)
+	if config.Workers > 0 {
+		engine.SetWorkers(config.Workers)
+	}
+	setupLogs(engine, config.Logs)
+	engine.SetKnownHosts(config.KnownHostsFile())
+
 	// Create handlers
 	sshHandler := handlers.NewSSHHandler(config)
-	projectHandler := handlers.NewProjectHandler(config)
+	projectHandler := handlers.NewProjectHandler(config, engine)
+	hookHandler := handlers.NewHookHandler(config, engine)
+	deploymentHandler := handlers.NewDeploymentHandler(engine)
+	notificationHandler := handlers.NewNotificationHandler(config, notifier)
+	freezeHandler := handlers.NewFreezeHandler(config)
+	queueHandler := handlers.NewQueueHandler(engine)
+	adminHandler := handlers.NewAdminHandler(engine)
+	websocketHandler := handlers.NewWebSocketHandler(engine)
+	busHandler := handlers.NewBusHandler(events)
+	sshHandler.SetEngine(engine)
+	sshHandler.SetBus(events)
+	projectHandler.SetBus(events)
 
 	// API routes
 	api := router.Group("/api")
@@ -33,9 +59,16 @@ func SetupRouter(config *handlers.Config, embeddedFS *embed.FS) *gin.Engine {
 			ssh.GET("", sshHandler.GetAll)
 			ssh.GET("/:name", sshHandler.GetByName)
 			ssh.POST("", sshHandler.Create)
+			ssh.POST("/import", sshHandler.Import)
+			ssh.POST("/import/preview", sshHandler.PreviewImport)
 			ssh.PUT("/:name", sshHandler.Update)
 			ssh.DELETE("/:name", sshHandler.Delete)
 			ssh.POST("/:name/test", sshHandler.Test)
+			ssh.GET("/:name/hostkey", sshHandler.GetHostKey)
+			ssh.POST("/:name/hostkey", sshHandler.AcceptHostKey)
+			ssh.POST("/:name/keys/generate", sshHandler.GenerateKey)
+			ssh.POST("/:name/keys/install", sshHandler.InstallKey)
+			ssh.GET("/:name/certificate", sshHandler.GetCertificate)
 		}
 
 		// Project routes
@@ -47,12 +80,68 @@ func SetupRouter(config *handlers.Config, embeddedFS *embed.FS) *gin.Engine {
 			projects.PUT("/:name", projectHandler.Update)
 			projects.DELETE("/:name", projectHandler.Delete)
 			projects.POST("/:name/deploy", projectHandler.Deploy)
+			projects.GET("/:name/environments", projectHandler.GetEnvironments)
+			projects.POST("/:name/environments/:env/deploy", projectHandler.DeployEnvironment)
+			projects.POST("/:name/environments/:env/promote", projectHandler.Promote)
+		}
+
+		// Deployment routes
+		deployments := api.Group("/deployments")
+		{
+			deployments.GET("", deploymentHandler.GetAll)
+			deployments.GET("/:id", deploymentHandler.GetByID)
+			deployments.GET("/:id/events", deploymentHandler.Events)
+			deployments.GET("/:id/logs/download", deploymentHandler.DownloadLogs)
+			deployments.POST("/:id/approve", deploymentHandler.Approve)
+			deployments.POST("/:id/reject", deploymentHandler.Reject)
+			deployments.POST("/:id/promote", deploymentHandler.Promote)
+			deployments.POST("/:id/abort", deploymentHandler.Abort)
+		}
+
+		// Deployment queue routes
+		queue := api.Group("/queue")
+		{
+			queue.GET("", queueHandler.GetAll)
+			queue.PUT("/:id", queueHandler.Move)
+			queue.DELETE("/:id", queueHandler.Remove)
+		}
+
+		// Notification channel routes
+		notifications := api.Group("/notifications")
+		{
+			notifications.GET("", notificationHandler.GetAll)
+			notifications.POST("", notificationHandler.Create)
+			notifications.PUT("/:name", notificationHandler.Update)
+			notifications.DELETE("/:name", notificationHandler.Delete)
+			notifications.POST("/:name/test", notificationHandler.Test)
+		}
+
+		// Freeze window routes
+		freezes := api.Group("/freezes")
+		{
+			freezes.GET("", freezeHandler.GetAll)
+			freezes.PUT("", freezeHandler.Update)
+			freezes.GET("/check", freezeHandler.Check)
 		}
+
+		// Admin routes
+		admin := api.Group("/admin")
+		{
+			admin.GET("/logs", adminHandler.LogUsage)
+			admin.POST("/logs/prune", adminHandler.PruneLogs)
+			admin.GET("/connections", adminHandler.Connections)
+		}
+
+		// Webhook trigger routes
+		api.POST("/hooks/:project", hookHandler.Trigger)
+
+		// Live change stream
+		api.GET("/events", busHandler.Stream)
 	}
 
 	// WebSocket routes
-	// TODO: Add WebSocket handler for deployment logs
-	// router.GET("/ws/deploy/:deploymentId", websocketHandler.HandleDeployment)
+	router.GET("/ws/deploy/:deploymentId", websocketHandler.HandleDeployment)
+	router.GET("/ws/events", busHandler.WebSocket)
 
 	// Serve embedded frontend files if provided
 	if embeddedFS != nil {
@@ -62,6 +151,29 @@ func SetupRouter(config *handlers.Config, embeddedFS *embed.FS) *gin.Engine {
 	return router
 }
 
+// setupLogs applies the log size limit and enables log storage if configured
+func setupLogs(engine *deploy.Engine, config *handlers.LogConfig) {
+	if config == nil {
+		return
+	}
+	if config.MaxSize > 0 {
+		engine.SetLogLimit(int64(config.MaxSize) << 20)
+	}
+	if config.Dir == "" {
+		return
+	}
+
+	store, err := deploy.NewLogStore(config.Dir, deploy.LogRetention{
+		MaxAge:        time.Duration(config.MaxAge) * 24 * time.Hour,
+		MaxPerProject: config.MaxPerProject,
+	})
+	if err != nil {
+		log.Printf("Log storage disabled: %v", err)
+		return
+	}
+	engine.SetLogStore(store)
+}
+
 // setupStaticRoutes configures routes to serve the embedded frontend
 func setupStaticRoutes(router *gin.Engine, embeddedFS *embed.FS) {
 	// Try to get the embedded filesystem starting from frontend/dist
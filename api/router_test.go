@@ -3,8 +3,10 @@ package api
 import (
 	"embed"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"strings"
 	"testing"
 
@@ -14,6 +16,24 @@ import (
 //go:embed all:testdata/dist
 var testEmbeddedFS embed.FS
 
+// TestMain runs the tests in a temporary directory, so handlers saving
+// config.json do not write into the source tree
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "ed-api-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 // TestSetupRouter_AllRoutesRegistered tests that all expected routes are registered
 func TestSetupRouter_AllRoutesRegistered(t *testing.T) {
 	config := &handlers.Config{
@@ -44,6 +64,48 @@ func TestSetupRouter_AllRoutesRegistered(t *testing.T) {
 		{"PUT update project", "PUT", "/api/projects/test", http.StatusBadRequest},
 		{"DELETE project", "DELETE", "/api/projects/test", http.StatusNotFound},
 		{"POST deploy project", "POST", "/api/projects/test/deploy", http.StatusNotFound},
+		{"GET project environments", "GET", "/api/projects/test/environments", http.StatusNotFound},
+		{"POST deploy environment", "POST", "/api/projects/test/environments/prod/deploy", http.StatusNotFound},
+		{"POST promote environment", "POST", "/api/projects/test/environments/staging/promote", http.StatusNotFound},
+
+		// Deployment routes
+		{"GET all deployments", "GET", "/api/deployments", http.StatusOK},
+		{"GET deployment by ID", "GET", "/api/deployments/test", http.StatusNotFound},
+		{"POST approve deployment", "POST", "/api/deployments/test/approve", http.StatusBadRequest},
+		{"POST reject deployment", "POST", "/api/deployments/test/reject", http.StatusBadRequest},
+		{"POST promote deployment", "POST", "/api/deployments/test/promote", http.StatusBadRequest},
+		{"POST abort deployment", "POST", "/api/deployments/test/abort", http.StatusBadRequest},
+
+		// Queue routes
+		{"GET deployment queue", "GET", "/api/queue", http.StatusOK},
+		{"PUT move queued deployment", "PUT", "/api/queue/test", http.StatusBadRequest},
+		{"DELETE queued deployment", "DELETE", "/api/queue/test", http.StatusNotFound},
+
+		// Notification routes
+		{"GET all notification channels", "GET", "/api/notifications", http.StatusOK},
+		{"POST create notification channel", "POST", "/api/notifications", http.StatusBadRequest},
+		{"PUT update notification channel", "PUT", "/api/notifications/test", http.StatusBadRequest},
+		{"DELETE notification channel", "DELETE", "/api/notifications/test", http.StatusNotFound},
+		{"POST test notification channel", "POST", "/api/notifications/test/test", http.StatusNotFound},
+
+		// Freeze window routes
+		{"GET freeze windows", "GET", "/api/freezes", http.StatusOK},
+		{"PUT freeze windows", "PUT", "/api/freezes", http.StatusBadRequest},
+		{"GET freeze check", "GET", "/api/freezes/check?project=test", http.StatusNotFound},
+
+		// Admin routes
+		{"GET log storage usage", "GET", "/api/admin/logs", http.StatusNotFound},
+		{"POST prune logs", "POST", "/api/admin/logs/prune", http.StatusNotFound},
+
+		// Webhook routes
+		{"POST webhook trigger", "POST", "/api/hooks/test", http.StatusNotFound},
+
+		// Deployment event stream
+		{"GET deployment events", "GET", "/api/deployments/test/events", http.StatusNotFound},
+		{"GET deployment log download", "GET", "/api/deployments/test/logs/download", http.StatusNotFound},
+
+		// WebSocket routes
+		{"GET deployment log stream", "GET", "/ws/deploy/test", http.StatusNotFound},
 	}
 
 	for _, tt := range tests {
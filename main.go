@@ -16,8 +16,17 @@ func main() {
 	// Command-line flags
 	apiMode := flag.Bool("api", false, "Run in API mode (web server)")
 	port := flag.String("port", "8080", "API server port")
+	importFile := flag.String("import-ssh-config", "", "Import hosts of an OpenSSH config file, e.g. ~/.ssh/config")
+	importHosts := flag.String("hosts", "", "Comma separated hosts to import, lists the hosts when empty")
 	flag.Parse()
 
+	if *importFile != "" {
+		if err := importSSHConfig("config.json", *importFile, *importHosts); err != nil {
+			log.Fatal("Failed to import SSH config: ", err)
+		}
+		return
+	}
+
 	// Load or create config
 	config, err := LoadConfig("config.json")
 	if err != nil {
@@ -30,8 +39,16 @@ func main() {
 
 		// Convert main.Config to handlers.Config
 		handlerConfig := &handlers.Config{
-			SSHConfigs: convertSSHConfigs(config.SSHConfigs),
-			Projects:   convertProjects(config.Projects),
+			SSHConfigs:           convertSSHConfigs(config.SSHConfigs),
+			Projects:             convertProjects(config.Projects),
+			Notifications:        config.Notifications,
+			Freezes:              config.Freezes,
+			FreezeOverrideUsers:  config.FreezeOverrideUsers,
+			FreezeOverrideTokens: config.FreezeOverrideTokens,
+			Workers:              config.Workers,
+			Logs:                 config.Logs,
+			KnownHosts:           config.KnownHosts,
+			KeysDir:              config.KeysDir,
 		}
 
 		router := api.SetupRouter(handlerConfig, &embeddedFiles)
@@ -57,6 +74,14 @@ func convertSSHConfigs(configs []SSHConfig) []handlers.SSHConfig {
 			Password: cfg.Password,
 			KeyFile:  cfg.KeyFile,
 			KeyPass:  cfg.KeyPass,
+			Tags:     cfg.Tags,
+			Labels:   cfg.Labels,
+			Sudo:     cfg.Sudo,
+			Jump:     cfg.Jump,
+
+			PrivateKey:  cfg.PrivateKey,
+			CertFile:    cfg.CertFile,
+			AuthMethods: cfg.AuthMethods,
 		}
 	}
 	return result
@@ -70,6 +95,16 @@ func convertProjects(projects []Project) []handlers.Project {
 			BuildInstructions: proj.BuildInstructions,
 			DeployScript:      proj.DeployScript,
 			DeployServers:     proj.DeployServers,
+			Webhook:           proj.Webhook,
+			Approval:          proj.Approval,
+			Canary:            proj.Canary,
+			TTY:               proj.TTY,
+			Steps:             proj.Steps,
+			Templates:         proj.Templates,
+			Handlers:          proj.Handlers,
+			Freezes:           proj.Freezes,
+			Environments:      proj.Environments,
+			Notify:            proj.Notify,
 			CreatedAt:         proj.CreatedAt,
 			UpdatedAt:         proj.UpdatedAt,
 		}
@@ -0,0 +1,117 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// CertificateInfo describes an OpenSSH certificate.
+type CertificateInfo struct {
+	Type        string     `json:"type"` // "user" or "host"
+	KeyID       string     `json:"key_id"`
+	Serial      uint64     `json:"serial"`
+	Principals  []string   `json:"principals"`
+	ValidAfter  *time.Time `json:"valid_after,omitempty"`  // nil if valid since forever
+	ValidBefore *time.Time `json:"valid_before,omitempty"` // nil if valid forever
+	Authority   string     `json:"authority"`              // fingerprint of the signing CA key
+}
+
+// Certificate returns auth method from a private key file and its OpenSSH user
+// certificate. The certificate must belong to the key and be valid now.
+func Certificate(prvFile string, certFile string, passphrase string) (Auth, error) {
+	signer, err := GetSigner(prvFile, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := LoadCertificate(certFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if cert.CertType != ssh.UserCert {
+		return nil, errors.Errorf("%s is not a user certificate", certFile)
+	}
+	if !bytes.Equal(cert.Key.Marshal(), signer.PublicKey().Marshal()) {
+		return nil, errors.Errorf("certificate %s does not belong to key %s", certFile, prvFile)
+	}
+	if err := CheckCertificateTime(cert, time.Now()); err != nil {
+		return nil, err
+	}
+
+	certSigner, err := ssh.NewCertSigner(cert, signer)
+	if err != nil {
+		return nil, err
+	}
+	return Auth{
+		ssh.PublicKeys(certSigner),
+	}, nil
+}
+
+// LoadCertificate reads an OpenSSH certificate, as written by ssh-keygen -s.
+func LoadCertificate(certFile string) (*ssh.Certificate, error) {
+	data, err := os.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse certificate %s", certFile)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, errors.Errorf("%s is a %s key, not a certificate", certFile, key.Type())
+	}
+	return cert, nil
+}
+
+// CheckCertificateTime returns an error if a certificate is expired or not yet
+// valid at t.
+func CheckCertificateTime(cert *ssh.Certificate, t time.Time) error {
+	info := CertInfo(cert)
+	if info.ValidAfter != nil && t.Before(*info.ValidAfter) {
+		return fmt.Errorf("certificate %q is not valid before %s", cert.KeyId, info.ValidAfter.Format(time.RFC3339))
+	}
+	if info.ValidBefore != nil && !t.Before(*info.ValidBefore) {
+		return fmt.Errorf("certificate %q expired at %s", cert.KeyId, info.ValidBefore.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// CertInfo describes a certificate.
+func CertInfo(cert *ssh.Certificate) CertificateInfo {
+	info := CertificateInfo{
+		Type:       "user",
+		KeyID:      cert.KeyId,
+		Serial:     cert.Serial,
+		Principals: cert.ValidPrincipals,
+		Authority:  Fingerprint(cert.SignatureKey),
+	}
+	if cert.CertType == ssh.HostCert {
+		info.Type = "host"
+	}
+	if info.Principals == nil {
+		info.Principals = []string{}
+	}
+	if cert.ValidAfter != 0 {
+		after := certTime(cert.ValidAfter)
+		info.ValidAfter = &after
+	}
+	if cert.ValidBefore != ssh.CertTimeInfinity {
+		before := certTime(cert.ValidBefore)
+		info.ValidBefore = &before
+	}
+	return info
+}
+
+// certTime converts a certificate timestamp, which is unsigned, to a time.
+func certTime(t uint64) time.Time {
+	if t > 1<<63-1 {
+		t = 1<<63 - 1
+	}
+	return time.Unix(int64(t), 0).UTC()
+}
@@ -6,8 +6,11 @@ import (
 	"io"
 	"net"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/pkg/sftp"
 	"golang.org/x/crypto/ssh"
 )
@@ -16,6 +19,15 @@ import (
 type Client struct {
 	*ssh.Client
 	Config *Config
+
+	// SFTP session shared by Sftp, Upload and Download.
+	ftp *sharedSftp
+}
+
+// sharedSftp is an SFTP session opened on first use.
+type sharedSftp struct {
+	mu     sync.Mutex
+	client *sftp.Client
 }
 
 // Config for Client.
@@ -27,12 +39,19 @@ type Config struct {
 	Timeout        time.Duration
 	Callback       ssh.HostKeyCallback
 	BannerCallback ssh.BannerCallback
+
+	// Jump hosts to connect through, in the order they are dialed.
+	Jump []*Config
 }
 
 // DefaultTimeout is the timeout of ssh client connection.
 var DefaultTimeout = 20 * time.Second
 
+// DefaultPort is the port dialed when an address has none.
+const DefaultPort = 22
+
 // New starts a new ssh connection, the host public key must be in known hosts.
+// addr is a host, or host:port to dial a port other than 22.
 func New(user string, addr string, auth Auth) (c *Client, err error) {
 
 	callback, err := DefaultKnownHosts()
@@ -41,10 +60,11 @@ func New(user string, addr string, auth Auth) (c *Client, err error) {
 		return
 	}
 
+	host, port := splitAddr(addr)
 	c, err = NewConn(&Config{
 		User:     user,
-		Addr:     addr,
-		Port:     22,
+		Addr:     host,
+		Port:     port,
 		Auth:     auth,
 		Timeout:  DefaultTimeout,
 		Callback: callback,
@@ -57,10 +77,11 @@ func New(user string, addr string, auth Auth) (c *Client, err error) {
 // if there a "man in the middle proxy", this can harm you!
 // You can add the key to know hosts and use New() func instead!
 func NewUnknown(user string, addr string, auth Auth) (*Client, error) {
+	host, port := splitAddr(addr)
 	return NewConn(&Config{
 		User:     user,
-		Addr:     addr,
-		Port:     22,
+		Addr:     host,
+		Port:     port,
 		Auth:     auth,
 		Timeout:  DefaultTimeout,
 		Callback: ssh.InsecureIgnoreHostKey(),
@@ -72,21 +93,97 @@ func NewConn(config *Config) (c *Client, err error) {
 
 	c = &Client{
 		Config: config,
+		ftp:    &sharedSftp{},
 	}
 
 	c.Client, err = Dial("tcp", config)
 	return
 }
 
-// Dial starts a client connection to SSH server based on config.
-func Dial(proto string, c *Config) (*ssh.Client, error) {
-	return ssh.Dial(proto, net.JoinHostPort(c.Addr, fmt.Sprint(c.Port)), &ssh.ClientConfig{
+// splitAddr splits an address into host and port, defaulting to DefaultPort.
+func splitAddr(addr string) (string, uint) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, DefaultPort
+	}
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return addr, DefaultPort
+	}
+	return host, uint(n)
+}
+
+// address returns the host:port to dial.
+func (c *Config) address() string {
+	port := c.Port
+	if port == 0 {
+		port = DefaultPort
+	}
+	return net.JoinHostPort(c.Addr, fmt.Sprint(port))
+}
+
+// clientConfig returns the x/crypto/ssh config of c.
+func (c *Config) clientConfig() *ssh.ClientConfig {
+	return &ssh.ClientConfig{
 		User:            c.User,
 		Auth:            c.Auth,
 		Timeout:         c.Timeout,
 		HostKeyCallback: c.Callback,
 		BannerCallback:  c.BannerCallback,
-	})
+	}
+}
+
+// Dial starts a client connection to SSH server based on config. With jump
+// hosts, the first is dialed directly and every next hop is tunnelled through
+// the previous one. The hops are closed when the returned client is.
+func Dial(proto string, c *Config) (*ssh.Client, error) {
+	if len(c.Jump) == 0 {
+		return ssh.Dial(proto, c.address(), c.clientConfig())
+	}
+
+	hop, err := Dial(proto, c.Jump[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "jump host %s", c.Jump[0].address())
+	}
+
+	for _, next := range append(c.Jump[1:len(c.Jump):len(c.Jump)], c) {
+		client, err := dialThrough(hop, proto, next)
+		if err != nil {
+			hop.Close()
+			if next != c {
+				err = errors.Wrapf(err, "jump host %s", next.address())
+			}
+			return nil, err
+		}
+
+		// Close the previous hop along with the client tunnelled through it
+		go func(prev *ssh.Client) {
+			client.Wait()
+			prev.Close()
+		}(hop)
+		hop = client
+	}
+	return hop, nil
+}
+
+// dialThrough opens an SSH connection tunnelled through an established client.
+func dialThrough(via *ssh.Client, proto string, c *Config) (*ssh.Client, error) {
+	conn, err := via.Dial(proto, c.address())
+	if err != nil {
+		return nil, err
+	}
+
+	// Tunnelled connections have no deadlines, close the tunnel on timeout instead
+	if c.Timeout > 0 {
+		timer := time.AfterFunc(c.Timeout, func() { conn.Close() })
+		defer timer.Stop()
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, c.address(), c.clientConfig())
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(sshConn, chans, reqs), nil
 }
 
 // Run starts a new SSH session and runs the cmd, it returns CombinedOutput and err if any.
@@ -153,8 +250,50 @@ func (c Client) NewSftp(opts ...sftp.ClientOption) (*sftp.Client, error) {
 	return sftp.NewClient(c.Client, opts...)
 }
 
+// Sftp returns the SFTP session of the client, opening it on first use. It is
+// safe for concurrent use and closed with the client, callers must not close it.
+func (c Client) Sftp() (*sftp.Client, error) {
+	if c.ftp == nil {
+		return nil, errors.New("client has no shared sftp session, use NewConn")
+	}
+
+	c.ftp.mu.Lock()
+	defer c.ftp.mu.Unlock()
+
+	if c.ftp.client == nil {
+		client, err := c.NewSftp()
+		if err != nil {
+			return nil, err
+		}
+		c.ftp.client = client
+	}
+	return c.ftp.client, nil
+}
+
+// sftpSession returns the shared SFTP session, or a new one for clients not
+// created by NewConn. done releases it.
+func (c Client) sftpSession() (*sftp.Client, func(), error) {
+	if c.ftp != nil {
+		ftp, err := c.Sftp()
+		return ftp, func() {}, err
+	}
+	ftp, err := c.NewSftp()
+	if err != nil {
+		return nil, nil, err
+	}
+	return ftp, func() { ftp.Close() }, nil
+}
+
 // Close client net connection.
 func (c Client) Close() error {
+	if c.ftp != nil {
+		c.ftp.mu.Lock()
+		if c.ftp.client != nil {
+			c.ftp.client.Close()
+			c.ftp.client = nil
+		}
+		c.ftp.mu.Unlock()
+	}
 	return c.Client.Close()
 }
 
@@ -167,11 +306,11 @@ func (c Client) Upload(localPath string, remotePath string) (err error) {
 	}
 	defer local.Close()
 
-	ftp, err := c.NewSftp()
+	ftp, done, err := c.sftpSession()
 	if err != nil {
 		return
 	}
-	defer ftp.Close()
+	defer done()
 
 	remote, err := ftp.Create(remotePath)
 	if err != nil {
@@ -192,11 +331,11 @@ func (c Client) Download(remotePath string, localPath string) (err error) {
 	}
 	defer local.Close()
 
-	ftp, err := c.NewSftp()
+	ftp, done, err := c.sftpSession()
 	if err != nil {
 		return
 	}
-	defer ftp.Close()
+	defer done()
 
 	remote, err := ftp.Open(remotePath)
 	if err != nil {
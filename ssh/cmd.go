@@ -71,6 +71,17 @@ func (c *Cmd) Start() error {
 	return c.Session.Start(c.String())
 }
 
+// Pty requests a pseudo-terminal of the given type and size for the command.
+// It must be called before the command starts. Output is then merged into stdout.
+func (c *Cmd) Pty(term string, cols, rows int) error {
+	modes := ssh.TerminalModes{
+		ssh.ECHO:          0,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	}
+	return c.RequestPty(term, rows, cols, modes)
+}
+
 // String return the command line string.
 func (c *Cmd) String() string {
 	return fmt.Sprintf("%s %s", c.Path, strings.Join(c.Args, " "))
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"slices"
+	"strings"
+	"sync"
 
 	"golang.org/x/crypto/ssh"
 	"golang.org/x/crypto/ssh/knownhosts"
@@ -43,6 +46,11 @@ func CheckKnownHost(host string, remote net.Addr, key ssh.PublicKey, knownFile s
 		knownFile = path
 	}
 
+	// A missing file knows no hosts
+	if _, err := os.Stat(knownFile); os.IsNotExist(err) {
+		return false, nil
+	}
+
 	// Get host key callback
 	callback, err := KnownHosts(knownFile)
 
@@ -74,6 +82,7 @@ func CheckKnownHost(host string, remote net.Addr, key ssh.PublicKey, knownFile s
 }
 
 // AddKnownHost add a a host to known hosts file.
+// A nil remote adds the host alone.
 func AddKnownHost(host string, remote net.Addr, key ssh.PublicKey, knownFile string) (err error) {
 
 	// Fallback to default known_hosts file
@@ -93,12 +102,16 @@ func AddKnownHost(host string, remote net.Addr, key ssh.PublicKey, knownFile str
 
 	defer f.Close()
 
-	remoteNormalized := knownhosts.Normalize(remote.String())
 	hostNormalized := knownhosts.Normalize(host)
-	addresses := []string{remoteNormalized}
+	addresses := []string{hostNormalized}
 
-	if hostNormalized != remoteNormalized {
-		addresses = append(addresses, hostNormalized)
+	if remote != nil {
+		remoteNormalized := knownhosts.Normalize(remote.String())
+		addresses = []string{remoteNormalized}
+
+		if hostNormalized != remoteNormalized {
+			addresses = append(addresses, hostNormalized)
+		}
 	}
 
 	_, err = f.WriteString(knownhosts.Line(addresses, key) + "\n")
@@ -116,3 +129,150 @@ func DefaultKnownHostsPath() (string, error) {
 
 	return fmt.Sprintf("%s/.ssh/known_hosts", home), err
 }
+
+// HostKeyError is returned by the HostKeyPins callback for a host key that is
+// not pinned yet, or that does not match the pinned keys.
+type HostKeyError struct {
+	Host string
+	Key  ssh.PublicKey
+
+	// Fingerprints of the pinned keys, empty for an unknown host.
+	Want []string
+}
+
+// Unknown reports whether the host has no pinned key yet.
+func (e *HostKeyError) Unknown() bool {
+	return len(e.Want) == 0
+}
+
+// Error implements error.
+func (e *HostKeyError) Error() string {
+	if e.Unknown() {
+		return fmt.Sprintf("unknown host key for %s: %s %s, verify and accept the fingerprint first",
+			e.Host, e.Key.Type(), Fingerprint(e.Key))
+	}
+	return fmt.Sprintf("host key mismatch for %s: got %s, pinned %s; refusing to connect, the host key changed or the connection is intercepted",
+		e.Host, Fingerprint(e.Key), strings.Join(e.Want, ", "))
+}
+
+// Fingerprint returns the SHA256 fingerprint of a key, as printed by ssh-keygen -l.
+func Fingerprint(key ssh.PublicKey) string {
+	return ssh.FingerprintSHA256(key)
+}
+
+// pinsMu serializes the rewrites of pinned host key files.
+var pinsMu sync.Mutex
+
+// HostKeyPins returns a host key callback accepting only the keys pinned in
+// file, checked with CheckKnownHost. A missing file pins no keys. Unknown and
+// mismatched keys fail with a *HostKeyError.
+//
+// Host certificates signed by a CA of an @cert-authority line matching the
+// host are accepted too. Other host certificates are checked as the plain key
+// they certify.
+func HostKeyPins(file string) (ssh.HostKeyCallback, error) {
+	if _, err := os.Stat(file); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return func(host string, remote net.Addr, key ssh.PublicKey) error {
+		if cert, ok := key.(*ssh.Certificate); ok {
+			if found, err := CheckKnownHost(host, remote, cert, file); found && err == nil {
+				return nil
+			}
+			key = cert.Key
+		}
+
+		found, err := CheckKnownHost(host, remote, key, file)
+
+		var keyErr *knownhosts.KeyError
+		switch {
+		case errors.As(err, &keyErr):
+			hostErr := &HostKeyError{Host: host, Key: key}
+			for _, known := range keyErr.Want {
+				hostErr.Want = append(hostErr.Want, Fingerprint(known.Key))
+			}
+			return hostErr
+		case err != nil:
+			return err
+		case !found:
+			return &HostKeyError{Host: host, Key: key}
+		}
+		return nil
+	}, nil
+}
+
+// errHostKeyScanned aborts the handshake of ScanHostKey once the key is known.
+var errHostKeyScanned = errors.New("host key scanned")
+
+// ScanHostKey returns the host key of the server of config without
+// authenticating. Jump hosts are verified with their own callbacks.
+func ScanHostKey(config *Config) (ssh.PublicKey, error) {
+	var key ssh.PublicKey
+
+	scan := *config
+	scan.Callback = func(host string, remote net.Addr, k ssh.PublicKey) error {
+		key = k
+		return errHostKeyScanned
+	}
+
+	client, err := Dial("tcp", &scan)
+	if err == nil {
+		client.Close()
+	}
+	if key != nil {
+		return key, nil
+	}
+	return nil, err
+}
+
+// PinHostKey pins key as the only key of the server at address ("host:port")
+// in file, replacing the keys pinned before. A host certificate pins the key
+// it certifies.
+func PinHostKey(address string, key ssh.PublicKey, file string) error {
+	if cert, ok := key.(*ssh.Certificate); ok {
+		key = cert.Key
+	}
+
+	pinsMu.Lock()
+	defer pinsMu.Unlock()
+
+	found, err := CheckKnownHost(address, &net.TCPAddr{}, key, file)
+	var keyErr *knownhosts.KeyError
+	switch {
+	case found && err == nil:
+		return nil
+	case errors.As(err, &keyErr):
+		if err := removeKnownHost(address, file); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	}
+	return AddKnownHost(address, nil, key, file)
+}
+
+// removeKnownHost removes the lines of a host from a known hosts file. The
+// file is replaced at once so concurrent readers never see a partial file.
+func removeKnownHost(host string, knownFile string) error {
+	data, err := os.ReadFile(knownFile)
+	if err != nil {
+		return err
+	}
+
+	normalized := knownhosts.Normalize(host)
+	var lines []string
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		_, hosts, _, _, _, err := ssh.ParseKnownHosts([]byte(line))
+		if err == nil && slices.Contains(hosts, normalized) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	tmp := knownFile + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, knownFile)
+}
@@ -0,0 +1,61 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// GenerateKey creates an ed25519 key pair. It returns the private key in
+// OpenSSH PEM format and the public key as an authorized_keys line.
+func GenerateKey(comment string) (privateKey []byte, authorizedKey []byte, err error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, err := ssh.MarshalPrivateKey(private, comment)
+	if err != nil {
+		return nil, nil, err
+	}
+	publicKey, err := ssh.NewPublicKey(public)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	authorizedKey = bytes.TrimSuffix(ssh.MarshalAuthorizedKey(publicKey), []byte("\n"))
+	if comment != "" {
+		authorizedKey = append(authorizedKey, ' ')
+		authorizedKey = append(authorizedKey, comment...)
+	}
+	return pem.EncodeToMemory(block), append(authorizedKey, '\n'), nil
+}
+
+// AuthorizeKey appends an authorized_keys line to ~/.ssh/authorized_keys of
+// the remote user unless it is there already. The directory and file are
+// created if needed and restricted to the user, as sshd requires.
+func (c Client) AuthorizeKey(authorizedKey []byte) error {
+	line := strings.TrimSpace(string(authorizedKey))
+	if _, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line)); err != nil {
+		return errors.Wrap(err, "invalid authorized key")
+	}
+	quoted := "'" + strings.ReplaceAll(line, "'", `'\''`) + "'"
+
+	// A last line without newline is terminated before appending
+	script := "umask 077 && mkdir -p ~/.ssh && chmod 700 ~/.ssh && " +
+		"touch ~/.ssh/authorized_keys && chmod 600 ~/.ssh/authorized_keys && " +
+		"{ grep -qxF " + quoted + " ~/.ssh/authorized_keys || { " +
+		"[ -z \"$(tail -c 1 ~/.ssh/authorized_keys)\" ] || echo >> ~/.ssh/authorized_keys; " +
+		"echo " + quoted + " >> ~/.ssh/authorized_keys; }; }"
+
+	output, err := c.Run(script)
+	if err != nil {
+		return errors.Wrapf(err, "authorize key: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
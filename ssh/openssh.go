@@ -0,0 +1,309 @@
+package ssh
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// HostConfig is a host read from an OpenSSH client config file.
+type HostConfig struct {
+	Alias        string `json:"alias"`
+	HostName     string `json:"host_name,omitempty"`
+	Port         int    `json:"port,omitempty"`
+	User         string `json:"user,omitempty"`
+	IdentityFile string `json:"identity_file,omitempty"`
+	ProxyJump    string `json:"proxy_jump,omitempty"`
+}
+
+// maxIncludeDepth limits nested Include directives.
+const maxIncludeDepth = 16
+
+// hostBlock holds the options of a Host section in file order.
+type hostBlock struct {
+	patterns []string
+	options  [][2]string // keyword and value
+}
+
+// DefaultConfigFile returns the path of ~/.ssh/config.
+func DefaultConfigFile() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".ssh", "config"), nil
+}
+
+// ReadConfigFile reads the hosts of an OpenSSH client config file.
+func ReadConfigFile(file string) ([]HostConfig, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseConfig(f)
+}
+
+// ParseConfig reads the hosts of an OpenSSH client config. Only hosts named
+// without wildcards are returned, in the order they first appear, with the
+// options of every matching Host section applied, the first value winning as
+// in ssh(1). Relative Include paths are resolved against ~/.ssh and Match
+// sections are skipped.
+func ParseConfig(r io.Reader) ([]HostConfig, error) {
+	return parseConfig(r, true)
+}
+
+// ParseConfigWithoutIncludes is like ParseConfig, but fails on Include
+// directives. It parses configs from untrusted sources, which must not read
+// local files.
+func ParseConfigWithoutIncludes(r io.Reader) ([]HostConfig, error) {
+	return parseConfig(r, false)
+}
+
+// parseConfig reads the hosts of an OpenSSH client config, following its
+// Include directives if includes is set.
+func parseConfig(r io.Reader, includes bool) ([]HostConfig, error) {
+	p := &configParser{includes: includes}
+	if home, err := os.UserHomeDir(); err == nil {
+		p.home = home
+	}
+	if err := p.parse(r, "", nil, 0); err != nil {
+		return nil, err
+	}
+
+	var hosts []HostConfig
+	seen := make(map[string]bool)
+	for _, block := range p.blocks {
+		for _, alias := range block.patterns {
+			if seen[alias] || strings.HasPrefix(alias, "!") || strings.ContainsAny(alias, "*?") {
+				continue
+			}
+			seen[alias] = true
+			hosts = append(hosts, p.host(alias))
+		}
+	}
+	return hosts, nil
+}
+
+// configParser collects the Host sections of a config and its includes.
+type configParser struct {
+	home     string
+	includes bool // follow Include directives
+	blocks   []*hostBlock
+}
+
+// parse reads a config file into host blocks. Options before the first Host
+// line of a file belong to the block the file was included from, nil applying
+// to every host.
+func (p *configParser) parse(r io.Reader, name string, block *hostBlock, depth int) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		keyword, args, err := splitConfigLine(scanner.Text())
+		if err != nil {
+			return errors.Wrapf(err, "%s line %d", name, line)
+		}
+		if keyword == "" {
+			continue
+		}
+
+		switch keyword {
+		case "host":
+			block = &hostBlock{patterns: args}
+			p.blocks = append(p.blocks, block)
+		case "match":
+			// Match criteria are not evaluated, its options apply to no host
+			block = &hostBlock{}
+			p.blocks = append(p.blocks, block)
+		case "include":
+			if !p.includes {
+				return errors.Errorf("%s line %d: Include is not allowed here", name, line)
+			}
+			if depth == maxIncludeDepth {
+				return errors.Errorf("%s line %d: too many nested includes", name, line)
+			}
+			for _, pattern := range args {
+				if err := p.include(pattern, block, depth+1); err != nil {
+					return err
+				}
+			}
+		default:
+			if len(args) == 0 {
+				// The line is not echoed, it may come from any file an Include matched
+				return errors.Errorf("%s line %d: missing argument", name, line)
+			}
+			if block == nil {
+				block = &hostBlock{patterns: []string{"*"}}
+				p.blocks = append(p.blocks, block)
+			}
+			block.options = append(block.options, [2]string{keyword, strings.Join(args, " ")})
+		}
+	}
+	return scanner.Err()
+}
+
+// include parses the files matching an Include pattern in lexical order.
+func (p *configParser) include(pattern string, block *hostBlock, depth int) error {
+	pattern = p.expandHome(pattern)
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(p.home, ".ssh", pattern)
+	}
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return errors.Wrapf(err, "include %s", pattern)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return errors.Wrapf(err, "include %s", file)
+		}
+		err = p.parse(f, file, block, depth)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// host resolves the options of an alias.
+func (p *configParser) host(alias string) HostConfig {
+	values := make(map[string]string)
+	for _, block := range p.blocks {
+		if !matchHost(alias, block.patterns) {
+			continue
+		}
+		for _, option := range block.options {
+			if _, exists := values[option[0]]; !exists {
+				values[option[0]] = option[1]
+			}
+		}
+	}
+
+	host := HostConfig{
+		Alias:    alias,
+		HostName: alias,
+		User:     values["user"],
+	}
+	if hostName, exists := values["hostname"]; exists {
+		host.HostName = expandTokens(hostName, map[byte]string{'h': alias})
+	}
+	if port, err := strconv.Atoi(values["port"]); err == nil {
+		host.Port = port
+	}
+	if jump := values["proxyjump"]; jump != "none" {
+		host.ProxyJump = jump
+	}
+	if identity, exists := values["identityfile"]; exists && identity != "none" {
+		localUser := ""
+		if u, err := user.Current(); err == nil {
+			localUser = u.Username
+		}
+		host.IdentityFile = expandTokens(p.expandHome(identity), map[byte]string{
+			'd': p.home,
+			'h': host.HostName,
+			'n': alias,
+			'r': host.User,
+			'u': localUser,
+		})
+	}
+	return host
+}
+
+// expandHome replaces a leading ~ with the home directory.
+func (p *configParser) expandHome(file string) string {
+	if file == "~" || strings.HasPrefix(file, "~/") {
+		return p.home + file[1:]
+	}
+	return file
+}
+
+// matchHost reports whether an alias matches a Host line. A matching negated
+// pattern rules the alias out.
+func matchHost(alias string, patterns []string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		if ok, _ := path.Match(strings.TrimPrefix(pattern, "!"), alias); ok {
+			if negated {
+				return false
+			}
+			matched = true
+		}
+	}
+	return matched
+}
+
+// expandTokens replaces %-tokens, unknown tokens are kept.
+func expandTokens(s string, tokens map[byte]string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		if s[i] == '%' {
+			b.WriteByte('%')
+		} else if value, exists := tokens[s[i]]; exists {
+			b.WriteString(value)
+		} else {
+			b.WriteByte('%')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// splitConfigLine splits a config line into its lower-cased keyword and its
+// arguments. The keyword may be separated by an equals sign and arguments may
+// be double quoted.
+func splitConfigLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil, nil
+	}
+	keyword := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+
+	var args []string
+	for rest != "" {
+		if rest[0] == '#' {
+			break
+		}
+		if rest[0] == '"' {
+			closing := strings.IndexByte(rest[1:], '"')
+			if closing < 0 {
+				return "", nil, errors.New("unterminated quote")
+			}
+			args = append(args, rest[1:closing+1])
+			rest = rest[closing+2:]
+		} else {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			args = append(args, rest[:end])
+			rest = rest[end:]
+		}
+		rest = strings.TrimLeft(rest, " \t")
+	}
+	return keyword, args, nil
+}
@@ -0,0 +1,223 @@
+package ssh
+
+import (
+	"sync"
+	"time"
+)
+
+// Defaults of NewPool.
+var (
+	DefaultKeepAlive   = 30 * time.Second
+	DefaultIdleTimeout = 5 * time.Minute
+)
+
+// Pool shares connections between operations on the same server. Sessions are
+// multiplexed over a pooled connection, which is kept alive with keepalive
+// requests and closed once idle for too long. Dead connections are dropped
+// and redialed on next use.
+type Pool struct {
+	keepAlive   time.Duration
+	idleTimeout time.Duration
+	conns       map[string]*pooledConn
+	dials       int
+	onDrop      func(key string)
+	mu          sync.Mutex
+}
+
+// PoolStats reports the state of a pool.
+type PoolStats struct {
+	Open  int `json:"open"`   // pooled connections
+	InUse int `json:"in_use"` // pooled connections with active users
+	Dials int `json:"dials"`  // connections dialed since the pool was created
+}
+
+// pooledConn is a connection shared by the users of one key.
+type pooledConn struct {
+	client   *Client
+	users    int
+	lastUsed time.Time
+	done     chan struct{} // closed when the connection is dropped
+}
+
+// NewPool returns a pool that sends keepalives every keepAlive, and closes
+// connections unused for idleTimeout.
+func NewPool(keepAlive time.Duration, idleTimeout time.Duration) *Pool {
+	return &Pool{
+		keepAlive:   keepAlive,
+		idleTimeout: idleTimeout,
+		conns:       make(map[string]*pooledConn),
+	}
+}
+
+// OnDrop registers fn to be called with the key of every pooled connection
+// that dies, either closed by the server or failing its keepalives. Idle and
+// closed connections are not reported. fn must not block.
+func (p *Pool) OnDrop(fn func(key string)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.onDrop = fn
+}
+
+// Get returns the pooled connection of key, dialing it with config if there is
+// none. release must be called once the caller is done with the client, which
+// must not be closed.
+func (p *Pool) Get(key string, config *Config) (*Client, func(), error) {
+	p.mu.Lock()
+	conn, exists := p.conns[key]
+	if exists {
+		conn.users++
+		p.mu.Unlock()
+		return conn.client, p.releaser(key, conn), nil
+	}
+	p.mu.Unlock()
+
+	// Dial without holding the lock, a concurrent dial of the same key is
+	// dropped in favour of the first one pooled
+	client, err := NewConn(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	p.dials++
+	if existing, exists := p.conns[key]; exists {
+		existing.users++
+		p.mu.Unlock()
+		client.Close()
+		return existing.client, p.releaser(key, existing), nil
+	}
+	conn = &pooledConn{client: client, users: 1, lastUsed: time.Now(), done: make(chan struct{})}
+	p.conns[key] = conn
+	p.mu.Unlock()
+
+	go p.watch(key, conn)
+	return client, p.releaser(key, conn), nil
+}
+
+// releaser returns the release func of a user of conn.
+func (p *Pool) releaser(key string, conn *pooledConn) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			conn.users--
+			conn.lastUsed = time.Now()
+		})
+	}
+}
+
+// watch keeps a pooled connection alive until it dies or idles out.
+func (p *Pool) watch(key string, conn *pooledConn) {
+	// A connection closed by the server is dropped right away
+	go func() {
+		conn.client.Wait()
+		if p.drop(key, conn) {
+			p.dropped(key)
+		}
+	}()
+
+	ticker := time.NewTicker(p.keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-conn.done:
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		idle := conn.users == 0 && time.Since(conn.lastUsed) >= p.idleTimeout
+		p.mu.Unlock()
+
+		if idle {
+			p.drop(key, conn)
+			return
+		}
+		if !p.alive(conn.client) {
+			if p.drop(key, conn) {
+				p.dropped(key)
+			}
+			return
+		}
+	}
+}
+
+// alive sends a keepalive request and reports whether it was answered in time.
+func (p *Pool) alive(client *Client) bool {
+	reply := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		reply <- err
+	}()
+
+	select {
+	case err := <-reply:
+		// Servers reject the unknown request, which still proves the connection
+		return err == nil
+	case <-time.After(p.keepAlive):
+		return false
+	}
+}
+
+// drop removes a connection from the pool and closes it, and reports whether
+// it was still open. Users still holding it see their sessions fail.
+func (p *Pool) drop(key string, conn *pooledConn) bool {
+	p.mu.Lock()
+	if p.conns[key] == conn {
+		delete(p.conns, key)
+	}
+	select {
+	case <-conn.done:
+		p.mu.Unlock()
+		return false
+	default:
+		close(conn.done)
+	}
+	p.mu.Unlock()
+
+	conn.client.Close()
+	return true
+}
+
+// dropped reports a connection that died to the OnDrop func.
+func (p *Pool) dropped(key string) {
+	p.mu.Lock()
+	onDrop := p.onDrop
+	p.mu.Unlock()
+
+	if onDrop != nil {
+		onDrop(key)
+	}
+}
+
+// Stats reports the connections of the pool.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := PoolStats{Open: len(p.conns), Dials: p.dials}
+	for _, conn := range p.conns {
+		if conn.users > 0 {
+			stats.InUse++
+		}
+	}
+	return stats
+}
+
+// Close closes all pooled connections.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	conns := make(map[string]*pooledConn, len(p.conns))
+	for key, conn := range p.conns {
+		conns[key] = conn
+	}
+	p.mu.Unlock()
+
+	for key, conn := range conns {
+		p.drop(key, conn)
+	}
+}
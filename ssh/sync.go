@@ -0,0 +1,306 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/pkg/sftp"
+)
+
+// SyncOptions configures Client.Sync.
+type SyncOptions struct {
+
+	// Compare the contents of files with equal size instead of their mtime.
+	Checksum bool
+
+	// Delete remote files that no longer exist locally.
+	Delete bool
+
+	// Patterns of paths to skip, matched with path.Match against the slash
+	// separated path relative to the synced directory and against the base name.
+	// Ignored remote files are never deleted, nor the directories holding them.
+	Ignore []string
+}
+
+// SyncResult reports the changes made by Client.Sync.
+type SyncResult struct {
+	Uploaded  []string `json:"uploaded,omitempty"`
+	Deleted   []string `json:"deleted,omitempty"`
+	Unchanged int      `json:"unchanged"`
+	Bytes     int64    `json:"bytes"`
+}
+
+// ignored reports whether a relative path matches one of the ignore patterns.
+func (o SyncOptions) ignored(rel string) bool {
+	for _, pattern := range o.Ignore {
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Sync makes remoteDir match localDir, uploading only the files that changed.
+// A file changed if its size or mtime differ, or with opts.Checksum its contents.
+// Uploaded files keep their mode and mtime.
+func (c Client) Sync(localDir string, remoteDir string, opts SyncOptions) (*SyncResult, error) {
+	ftp, done, err := c.sftpSession()
+	if err != nil {
+		return nil, errors.Wrap(err, "sftp")
+	}
+	defer done()
+
+	local, err := localTree(localDir, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "read local dir")
+	}
+	remote, holding, err := remoteTree(ftp, remoteDir, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "read remote dir")
+	}
+
+	result := &SyncResult{}
+
+	if err := ftp.MkdirAll(remoteDir); err != nil {
+		return nil, errors.Wrap(err, "create remote dir")
+	}
+
+	// Parents sort before their children
+	paths := make([]string, 0, len(local))
+	for rel := range local {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	// Remote directories removed to be replaced by a file, or the other way round
+	var replaced []string
+
+	for _, rel := range paths {
+		info := local[rel]
+		localPath := filepath.Join(localDir, filepath.FromSlash(rel))
+		remotePath := path.Join(remoteDir, rel)
+		existing, exists := remote[rel]
+
+		// A directory replaced by a file or the other way round
+		if exists && existing.IsDir() != info.IsDir() {
+			if err := ftp.RemoveAll(remotePath); err != nil {
+				return result, errors.Wrapf(err, "remove %s", remotePath)
+			}
+			replaced = append(replaced, rel)
+			exists = false
+		}
+
+		if info.IsDir() {
+			if !exists {
+				if err := ftp.Mkdir(remotePath); err != nil {
+					return result, errors.Wrapf(err, "create %s", remotePath)
+				}
+			}
+			if !exists || existing.Mode().Perm() != info.Mode().Perm() {
+				if err := ftp.Chmod(remotePath, info.Mode().Perm()); err != nil {
+					return result, errors.Wrapf(err, "chmod %s", remotePath)
+				}
+			}
+			continue
+		}
+
+		changed := !exists || existing.Size() != info.Size()
+		if !changed && opts.Checksum {
+			if changed, err = contentsDiffer(ftp, localPath, remotePath); err != nil {
+				return result, err
+			}
+		} else if !changed {
+			changed = existing.ModTime().Unix() != info.ModTime().Unix()
+		}
+
+		if !changed {
+			if existing.Mode().Perm() != info.Mode().Perm() {
+				if err := ftp.Chmod(remotePath, info.Mode().Perm()); err != nil {
+					return result, errors.Wrapf(err, "chmod %s", remotePath)
+				}
+			}
+			result.Unchanged++
+			continue
+		}
+
+		if err := uploadFile(ftp, localPath, remotePath, info); err != nil {
+			return result, err
+		}
+		result.Uploaded = append(result.Uploaded, rel)
+		result.Bytes += info.Size()
+	}
+
+	if opts.Delete {
+		// Children sort after their parents, so delete in reverse
+		var stale []string
+		for rel := range remote {
+			if _, exists := local[rel]; exists || below(rel, replaced) {
+				continue
+			}
+			// Directories holding ignored entries are kept with them
+			if remote[rel].IsDir() && holding[rel] {
+				continue
+			}
+			stale = append(stale, rel)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(stale)))
+
+		for _, rel := range stale {
+			remotePath := path.Join(remoteDir, rel)
+			if remote[rel].IsDir() {
+				err = ftp.RemoveDirectory(remotePath)
+			} else {
+				err = ftp.Remove(remotePath)
+			}
+			if err != nil {
+				return result, errors.Wrapf(err, "delete %s", remotePath)
+			}
+			result.Deleted = append(result.Deleted, rel)
+		}
+	}
+
+	return result, nil
+}
+
+// localTree returns the regular files and directories below dir by relative path.
+func localTree(dir string, opts SyncOptions) (map[string]fs.FileInfo, error) {
+	tree := make(map[string]fs.FileInfo)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == dir {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if opts.ignored(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		tree[rel] = info
+		return nil
+	})
+	return tree, err
+}
+
+// below reports whether a relative path is below one of the parent paths.
+func below(rel string, parents []string) bool {
+	for _, parent := range parents {
+		if strings.HasPrefix(rel, parent+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteTree returns the files and directories below a remote dir by relative
+// path, and the directories holding ignored entries. A missing dir is empty.
+func remoteTree(ftp *sftp.Client, dir string, opts SyncOptions) (map[string]fs.FileInfo, map[string]bool, error) {
+	tree := make(map[string]fs.FileInfo)
+	holding := make(map[string]bool)
+	if _, err := ftp.Stat(dir); os.IsNotExist(err) {
+		return tree, holding, nil
+	}
+
+	walker := ftp.Walk(dir)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			return nil, nil, err
+		}
+		if walker.Path() == dir {
+			continue
+		}
+
+		rel := strings.TrimPrefix(walker.Path(), strings.TrimSuffix(dir, "/")+"/")
+		if opts.ignored(rel) {
+			if walker.Stat().IsDir() {
+				walker.SkipDir()
+			}
+			for parent := path.Dir(rel); parent != "."; parent = path.Dir(parent) {
+				holding[parent] = true
+			}
+			continue
+		}
+		tree[rel] = walker.Stat()
+	}
+	return tree, holding, nil
+}
+
+// contentsDiffer compares the SHA-256 of a local and a remote file.
+func contentsDiffer(ftp *sftp.Client, localPath string, remotePath string) (bool, error) {
+	localSum, err := fileChecksum(os.Open(localPath))
+	if err != nil {
+		return false, errors.Wrapf(err, "checksum %s", localPath)
+	}
+	remoteSum, err := fileChecksum(ftp.Open(remotePath))
+	if err != nil {
+		return false, errors.Wrapf(err, "checksum %s", remotePath)
+	}
+	return !bytes.Equal(localSum, remoteSum), nil
+}
+
+// fileChecksum returns the SHA-256 of an opened file.
+func fileChecksum[F io.ReadCloser](f F, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// uploadFile copies a local file to a remote path, keeping its mode and mtime.
+func uploadFile(ftp *sftp.Client, localPath string, remotePath string, info fs.FileInfo) error {
+	src, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := ftp.OpenFile(remotePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return errors.Wrapf(err, "create %s", remotePath)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return errors.Wrapf(err, "upload %s", remotePath)
+	}
+	if err := dst.Close(); err != nil {
+		return errors.Wrapf(err, "upload %s", remotePath)
+	}
+
+	if err := ftp.Chmod(remotePath, info.Mode().Perm()); err != nil {
+		return errors.Wrapf(err, "chmod %s", remotePath)
+	}
+	return errors.Wrapf(ftp.Chtimes(remotePath, info.ModTime(), info.ModTime()), "chtimes %s", remotePath)
+}
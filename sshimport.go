@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/diiyw/ed/api/handlers"
+	"github.com/diiyw/ed/ssh"
+)
+
+// importSSHConfig imports hosts of an OpenSSH config file into the SSH
+// configurations of configFile. Without hosts, the importable hosts are listed.
+func importSSHConfig(configFile string, sshConfigFile string, hosts string) error {
+	config, err := handlers.LoadConfig(configFile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	parsed, err := ssh.ReadConfigFile(sshConfigFile)
+	if err != nil {
+		return fmt.Errorf("failed to read SSH config: %w", err)
+	}
+	candidates := handlers.ImportHosts(config, parsed)
+
+	if hosts == "" {
+		for _, candidate := range candidates {
+			cfg := candidate.Config
+			line := fmt.Sprintf("%-20s %s@%s:%d", cfg.Name, cfg.User, cfg.Host, cfg.Port)
+			if cfg.Jump != "" {
+				line += " via " + cfg.Jump
+			}
+			if candidate.Conflict {
+				line += " (exists)"
+			}
+			fmt.Println(line)
+			for _, warning := range candidate.Warnings {
+				fmt.Println("    warning:", warning)
+			}
+		}
+		fmt.Println("Pick the hosts to import with -hosts name1,name2")
+		return nil
+	}
+
+	result := handlers.ApplyImport(config, candidates, strings.Split(hosts, ","))
+	if len(result.Imported) > 0 {
+		if err := handlers.SaveConfig(configFile, config); err != nil {
+			return fmt.Errorf("failed to save config: %w", err)
+		}
+	}
+
+	fmt.Printf("Imported %d SSH configurations: %s\n", len(result.Imported), strings.Join(result.Imported, ", "))
+	for _, name := range result.Conflicts {
+		fmt.Printf("Skipped %s: an SSH configuration with this name exists\n", name)
+	}
+	for name, reason := range result.Skipped {
+		fmt.Printf("Skipped %s: %s\n", name, reason)
+	}
+	return nil
+}
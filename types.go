@@ -5,35 +5,64 @@ import (
 	"os"
 	"time"
 
+	"github.com/diiyw/ed/api/deploy"
+	"github.com/diiyw/ed/api/handlers"
+	"github.com/diiyw/ed/api/notify"
 	"github.com/diiyw/ed/ssh"
 )
 
 // SSHConfig represents an SSH server configuration
 type SSHConfig struct {
-	Name     string `json:"name"`
-	Host     string `json:"host"`
-	Port     int    `json:"port"`
-	User     string `json:"user"`
-	AuthType string `json:"auth_type"` // "password", "key", "agent"
-	Password string `json:"password,omitempty"`
-	KeyFile  string `json:"key_file,omitempty"`
-	KeyPass  string `json:"key_pass,omitempty"`
+	Name     string            `json:"name"`
+	Host     string            `json:"host"`
+	Port     int               `json:"port"`
+	User     string            `json:"user"`
+	AuthType string            `json:"auth_type"` // "password", "keyboard-interactive", "key", "inline-key", "certificate", "agent"
+	Password string            `json:"password,omitempty"`
+	KeyFile  string            `json:"key_file,omitempty"`
+	KeyPass  string            `json:"key_pass,omitempty"`
+	Tags     []string          `json:"tags,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Sudo     *deploy.Sudo      `json:"sudo,omitempty"`
+	Jump     string            `json:"jump,omitempty"`
+
+	PrivateKey  string   `json:"private_key,omitempty"`
+	CertFile    string   `json:"cert_file,omitempty"`
+	AuthMethods []string `json:"auth_methods,omitempty"`
 }
 
 // Project represents a deployable project
 type Project struct {
-	Name              string    `json:"name"`
-	BuildInstructions string    `json:"build_instructions"`
-	DeployScript      string    `json:"deploy_script"`
-	DeployServers     []string  `json:"deploy_servers"` // names of SSH configs
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
+	Name              string                   `json:"name"`
+	BuildInstructions string                   `json:"build_instructions"`
+	DeployScript      string                   `json:"deploy_script"`
+	DeployServers     []string                 `json:"deploy_servers"` // names of SSH configs
+	Webhook           *handlers.WebhookConfig  `json:"webhook,omitempty"`
+	Approval          *handlers.ApprovalConfig `json:"approval,omitempty"`
+	Canary            *handlers.CanaryConfig   `json:"canary,omitempty"`
+	TTY               *handlers.TTYConfig      `json:"tty,omitempty"`
+	Steps             []deploy.Step            `json:"steps,omitempty"`
+	Templates         []deploy.Template        `json:"templates,omitempty"`
+	Handlers          map[string]string        `json:"handlers,omitempty"`
+	Freezes           []handlers.FreezeWindow  `json:"freezes,omitempty"`
+	Environments      []handlers.Environment   `json:"environments,omitempty"`
+	Notify            []notify.Subscription    `json:"notify,omitempty"`
+	CreatedAt         time.Time                `json:"created_at"`
+	UpdatedAt         time.Time                `json:"updated_at"`
 }
 
 // Config holds all application data
 type Config struct {
-	SSHConfigs []SSHConfig `json:"ssh_configs"`
-	Projects   []Project   `json:"projects"`
+	SSHConfigs           []SSHConfig             `json:"ssh_configs"`
+	Projects             []Project               `json:"projects"`
+	Notifications        []notify.ChannelConfig  `json:"notifications,omitempty"`
+	Freezes              []handlers.FreezeWindow `json:"freezes,omitempty"`
+	FreezeOverrideUsers  []string                `json:"freeze_override_users,omitempty"`
+	FreezeOverrideTokens map[string]string       `json:"freeze_override_tokens,omitempty"`
+	Workers              int                     `json:"workers,omitempty"`
+	Logs                 *handlers.LogConfig     `json:"logs,omitempty"`
+	KnownHosts           string                  `json:"known_hosts,omitempty"`
+	KeysDir              string                  `json:"keys_dir,omitempty"`
 }
 
 // LoadConfig loads configuration from JSON file
@@ -63,18 +92,18 @@ func SaveConfig(filename string, config *Config) error {
 	return os.WriteFile(filename, data, 0644)
 }
 
-// GetAuthMethod returns the SSH auth method for this config
+// GetAuthMethod returns the SSH auth methods for this config
 func (sc *SSHConfig) GetAuthMethod() (ssh.Auth, error) {
-	switch sc.AuthType {
-	case "password":
-		return ssh.Password(sc.Password), nil
-	case "key":
-		return ssh.Key(sc.KeyFile, sc.KeyPass)
-	case "agent":
-		return ssh.UseAgent()
-	default:
-		return nil, nil
+	config := deploy.SSHConfig{
+		AuthType:    sc.AuthType,
+		Password:    sc.Password,
+		KeyFile:     sc.KeyFile,
+		KeyPass:     sc.KeyPass,
+		PrivateKey:  sc.PrivateKey,
+		CertFile:    sc.CertFile,
+		AuthMethods: sc.AuthMethods,
 	}
+	return config.GetAuthMethod()
 }
 
 // APIResponse represents a standard API response